@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requireAdmin guards admin endpoints with the ADMIN_TOKEN bearer token.
+// Admin endpoints are disabled entirely when ADMIN_TOKEN is not set.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := os.Getenv("ADMIN_TOKEN")
+		if token == "" {
+			http.Error(w, "admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleAdminModels serves POST /admin/models/{name}/enable and /disable
+func handleAdminModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/admin/models/")
+	slash := strings.LastIndex(path, "/")
+	if slash == -1 {
+		http.NotFound(w, r)
+		return
+	}
+	name, action := path[:slash], path[slash+1:]
+
+	var enabled bool
+	switch action {
+	case "enable":
+		enabled = true
+	case "disable":
+		enabled = false
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	// The running config and config.json change under one lock, so two
+	// updates or an update and a reload can't leave them disagreeing
+	configWriteMux.Lock()
+	model, err := setModelEnabled(name, enabled)
+	if err != nil {
+		configWriteMux.Unlock()
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	err = persistModelEnabled(name, enabled)
+	configWriteMux.Unlock()
+
+	if err != nil {
+		log.Printf("Error persisting enabled state for %s: %v\n", name, err)
+		http.Error(w, "model updated but could not be saved", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Admin set model %s enabled=%v\n", name, enabled)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.Redacted())
+}
+
+// setModelEnabled flips the enabled flag of every instance of a model in the
+// running config, the same ones persistModelEnabled changes on disk, and
+// returns the first. Games already in progress keep their own copy of the
+// model config. The caller holds configWriteMux.
+func setModelEnabled(name string, enabled bool) (ModelConfig, error) {
+	next := *currentConfig()
+	next.Models = append([]ModelConfig(nil), next.Models...)
+	found := -1
+	for i := range next.Models {
+		if next.Models[i].Name == name {
+			next.Models[i].Enabled = &enabled
+			if found == -1 {
+				found = i
+			}
+		}
+	}
+	if found == -1 {
+		return ModelConfig{}, fmt.Errorf("unknown model: %s", name)
+	}
+	runtimeConfig.Store(&next)
+	return next.Models[found], nil
+}
+
+// persistModelEnabled writes the enabled flag of every instance of a model back
+// to config.json. The file is
+// re-read rather than re-serializing the running config so API keys pulled
+// from the environment never end up on disk. The caller holds configWriteMux.
+func persistModelEnabled(name string, enabled bool) error {
+	path := dataDir + "config.json"
+
+	var fileConfig Config
+	file, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(file, &fileConfig); err != nil {
+			return err
+		}
+	} else {
+		// Running on the built-in defaults, which carry no secrets
+		fileConfig = *currentConfig()
+		fileConfig.Models = append([]ModelConfig(nil), fileConfig.Models...)
+	}
+
+	for i := range fileConfig.Models {
+		if fileConfig.Models[i].Name == name {
+			fileConfig.Models[i].Enabled = &enabled
+		}
+	}
+
+	data, err := json.MarshalIndent(fileConfig, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// handleAdminRescore serves POST /admin/leaderboard/rescore, recomputing every
+// score from an older scoring version with the current calculateScore. Entries
+// from before activity durations were recorded are rescored with the duration
+// they stored.
+func handleAdminRescore(w http.ResponseWriter, r *http.Request, rm *room) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rescored := 0
+	rescore := func(entry *LeaderboardEntry) {
+		if entry.ScoringVersion == scoringVersion {
+			return
+		}
+		entry.Score = calculateScore(GameResult{
+			PlayerWins:   entry.PlayerWon,
+			CorrectCount: entry.CorrectCount,
+			TotalModels:  entry.TotalModels,
+			Difficulty:   entry.Difficulty,
+			Duration:     entry.Duration,
+		})
+		entry.ScoringVersion = scoringVersion
+		rescored++
+	}
+
+	rm.leaderboardMux.Lock()
+	for i := range rm.leaderboard {
+		rescore(&rm.leaderboard[i])
+	}
+	sort.SliceStable(rm.leaderboard, func(i, j int) bool {
+		return rm.leaderboard[i].Score > rm.leaderboard[j].Score
+	})
+	rm.saveLeaderboard()
+	rm.leaderboardMux.Unlock()
+
+	rm.archiveMux.Lock()
+	archived := rm.readArchiveLocked()
+	for i := range archived {
+		rescore(&archived[i])
+	}
+	err := rm.writeArchiveLocked(archived)
+	rm.archiveMux.Unlock()
+	if err != nil {
+		log.Println("Error rewriting leaderboard archive:", err)
+		http.Error(w, "could not rescore archive", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Rescored %d leaderboard entries to scoring version %d\n", rescored, scoringVersion)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{
+		"rescored":       rescored,
+		"scoringVersion": scoringVersion,
+	})
+}
+
+// handleAdminLeaderboard serves GET /admin/leaderboard, every entry including
+// soft-deleted ones. ?hidden=true or ?hidden=false filters by hidden state.
+func handleAdminLeaderboard(w http.ResponseWriter, r *http.Request, rm *room) {
+	filter := r.URL.Query().Get("hidden")
+
+	entries := []LeaderboardEntry{}
+	for _, entry := range rm.allLeaderboardEntries() {
+		if filter == "" || filter == strconv.FormatBool(entry.Hidden) {
+			entries = append(entries, entry)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// handleAdminLeaderboardEntry serves POST /admin/leaderboard/{id}/hide and
+// /restore. Hiding keeps the entry and its game data so it can be restored
+// at its original rank.
+func handleAdminLeaderboardEntry(w http.ResponseWriter, r *http.Request, rm *room) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/admin/leaderboard/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	id, action := parts[0], parts[1]
+
+	var update func(entry *LeaderboardEntry)
+	switch action {
+	case "hide":
+		var body struct {
+			Reason string `json:"reason"`
+			Actor  string `json:"actor"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Actor == "" {
+			body.Actor = "admin"
+		}
+		update = func(entry *LeaderboardEntry) {
+			hideEntry(entry, body.Reason, body.Actor)
+		}
+	case "restore":
+		update = func(entry *LeaderboardEntry) {
+			entry.Hidden = false
+			entry.HiddenReason = ""
+			entry.HiddenBy = ""
+			entry.HiddenAt = nil
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if !rm.updateLeaderboardEntry(id, update) {
+		http.Error(w, "unknown leaderboard entry", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("Admin %s leaderboard entry %s\n", action, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hideEntry soft-deletes a leaderboard entry, recording why and by whom
+func hideEntry(entry *LeaderboardEntry, reason string, actor string) {
+	now := time.Now()
+	entry.Hidden = true
+	entry.HiddenReason = reason
+	entry.HiddenBy = actor
+	entry.HiddenAt = &now
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestToggleModelInstances disables a model served by two instances, and
+// checks the running config and config.json agree on which models changed
+func TestToggleModelInstances(t *testing.T) {
+	useConfig(t, Config{Models: []ModelConfig{
+		{Name: "Twin", InstanceLabel: "east", Provider: "mock", Model: "always-correct"},
+		{Name: "Other", Provider: "mock", Model: "always-wrong"},
+		{Name: "Twin", InstanceLabel: "west", Provider: "mock", Model: "always-correct"},
+	}})
+
+	w := httptest.NewRecorder()
+	handleAdminModels(w, httptest.NewRequest(http.MethodPost, "/admin/models/Twin/disable", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("disable answered %d: %s", w.Code, w.Body)
+	}
+
+	var saved Config
+	file, err := os.ReadFile(dataDir + "config.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(file, &saved); err != nil {
+		t.Fatal(err)
+	}
+	for i, model := range currentConfig().Models {
+		want := model.Name != "Twin"
+		if model.IsEnabled() != want {
+			t.Errorf("running config: %s enabled=%v, want %v", model.StatsKey(), model.IsEnabled(), want)
+		}
+		if saved.Models[i].IsEnabled() != want {
+			t.Errorf("config.json: %s enabled=%v, want %v", model.StatsKey(), saved.Models[i].IsEnabled(), want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	handleAdminModels(w, httptest.NewRequest(http.MethodPost, "/admin/models/Missing/disable", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("disabling an unknown model answered %d, want 404", w.Code)
+	}
+}
+
+// TestHideAndRestoreEntry hides the third-best game, checks no public
+// endpoint shows it while admins still can, then restores it and checks it
+// is back in third place, all surviving a reload from disk
+func TestHideAndRestoreEntry(t *testing.T) {
+	model := ModelConfig{Name: "Right", Provider: "mock", Model: "always-correct"}
+	useConfig(t, Config{Models: []ModelConfig{model}})
+	rm := rooms[defaultRoom]
+
+	// Each game is slower than the last, so the first scores best
+	players := []string{"ann", "bob", "cat", "dan", "eve"}
+	for i, player := range players {
+		game := newTestGame(t, "piano", model)
+		game.Username = player
+		rm.addToLeaderboard(game, GameResult{
+			PlayerWins:   true,
+			TotalModels:  1,
+			Difficulty:   "easy",
+			Duration:     60 + float64(i)*10,
+			RoundsPlayed: 1,
+			Timestamp:    time.Now(),
+			Username:     player,
+		})
+	}
+	hidden := rm.leaderboard[2]
+	if hidden.Username != "cat" {
+		t.Fatalf("third place is %s, want cat", hidden.Username)
+	}
+
+	get := func(handler func(http.ResponseWriter, *http.Request, *room), path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, path, nil), rm)
+		return w
+	}
+	publicBoard := func() []string {
+		var entries []LeaderboardEntry
+		json.NewDecoder(get(handleGetLeaderboard, "/leaderboard").Body).Decode(&entries)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Username)
+		}
+		return names
+	}
+	adminBoard := func(filter string) []LeaderboardEntry {
+		var entries []LeaderboardEntry
+		json.NewDecoder(get(handleAdminLeaderboard, "/admin/leaderboard?hidden="+filter).Body).Decode(&entries)
+		return entries
+	}
+	post := func(path, body string) {
+		w := httptest.NewRecorder()
+		handleAdminLeaderboardEntry(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)), rm)
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("%s answered %d: %s", path, w.Code, w.Body)
+		}
+	}
+	reload := func() {
+		rm.leaderboardMux.Lock()
+		rm.loadLeaderboard()
+		rm.leaderboardMux.Unlock()
+	}
+
+	post("/admin/leaderboard/"+hidden.ID+"/hide", `{"reason": "profanity", "actor": "filter"}`)
+	reload()
+
+	if got := strings.Join(publicBoard(), ","); got != "ann,bob,dan,eve" {
+		t.Errorf("public leaderboard while hidden: %s", got)
+	}
+	var history []LeaderboardEntry
+	json.NewDecoder(get(handleUserGames, "/users/cat/games").Body).Decode(&history)
+	if len(history) != 0 {
+		t.Errorf("hidden game in the player's public history: %+v", history)
+	}
+	embedded := func() embedGame {
+		var game embedGame
+		json.NewDecoder(get(handleEmbedGame, "/embed/games/"+hidden.ID+"?format=json").Body).Decode(&game)
+		return game
+	}
+	if game := embedded(); game.Available || game.Username != "" {
+		t.Errorf("hidden game embedded as %+v, want unavailable", game)
+	}
+	if rank := rm.rankToday(rm.leaderboard[3].Score, time.Now()); rank != 3 {
+		t.Errorf("dan ranks #%d today while cat is hidden, want #3", rank)
+	}
+
+	flagged := adminBoard("true")
+	if len(flagged) != 1 || flagged[0].ID != hidden.ID || flagged[0].HiddenReason != "profanity" ||
+		flagged[0].HiddenBy != "filter" || flagged[0].HiddenAt == nil {
+		t.Errorf("admin list of hidden entries: %+v", flagged)
+	}
+	if flagged[0].Score != hidden.Score || len(flagged[0].Models) != len(hidden.Models) {
+		t.Errorf("hiding changed the game record: %+v, was %+v", flagged[0], hidden)
+	}
+	if visible := adminBoard("false"); len(visible) != len(players)-1 {
+		t.Errorf("admin list of visible entries has %d, want %d", len(visible), len(players)-1)
+	}
+
+	post("/admin/leaderboard/"+hidden.ID+"/restore", "")
+	reload()
+
+	if got := strings.Join(publicBoard(), ","); got != "ann,bob,cat,dan,eve" {
+		t.Errorf("public leaderboard after restoring: %s", got)
+	}
+	restored := rm.leaderboard[2]
+	if restored.ID != hidden.ID || restored.Hidden || restored.HiddenReason != "" || restored.HiddenBy != "" || restored.HiddenAt != nil {
+		t.Errorf("restored entry: %+v", restored)
+	}
+	if game := embedded(); !game.Available || game.Username != "cat" {
+		t.Errorf("restored game embedded as %+v", game)
+	}
+	if rank := rm.rankToday(rm.leaderboard[3].Score, time.Now()); rank != 4 {
+		t.Errorf("dan ranks #%d today after cat is restored, want #4", rank)
+	}
+	if len(adminBoard("true")) != 0 {
+		t.Error("restored entry still listed as hidden")
+	}
+}
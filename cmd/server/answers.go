@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"github.com/tahcohcat/turingroulette/internal/answers"
+)
+
+// acceptedAnswers is the submission's answers with the single Answer of older
+// clients folded in, trimmed and without blanks or repeats
+func (s RiddleSubmission) acceptedAnswers() []string {
+	var accepted []string
+	seen := make(map[string]bool)
+	for _, answer := range append([]string{s.Answer}, s.Answers...) {
+		answer = strings.TrimSpace(answer)
+		if answer == "" || seen[strings.ToLower(answer)] {
+			continue
+		}
+		seen[strings.ToLower(answer)] = true
+		accepted = append(accepted, answer)
+	}
+	return accepted
+}
+
+// maxSynonyms caps the synonym groups a riddle can give
+const maxSynonyms = 10
+
+// checkSynonyms checks a submission's synonyms and returns them trimmed. Each
+// entry is a group of single words that mean the same for this riddle,
+// separated by "=", such as "couch=sofa".
+func checkSynonyms(entries []string, lang language.Tag) ([]string, error) {
+	if len(entries) > maxSynonyms {
+		return nil, fmt.Errorf("at most %d synonym groups are allowed", maxSynonyms)
+	}
+	var synonyms []string
+	for _, entry := range entries {
+		words := strings.Split(entry, "=")
+		for i, word := range words {
+			words[i] = strings.TrimSpace(word)
+			if len(answers.Words(words[i], lang)) != 1 {
+				return nil, fmt.Errorf("%q: each synonym must be a single word", entry)
+			}
+		}
+		if len(words) < 2 {
+			return nil, fmt.Errorf("%q: separate synonyms with \"=\"", entry)
+		}
+		synonyms = append(synonyms, strings.Join(words, "="))
+	}
+	return synonyms, nil
+}
+
+// AnswerMatchingConfig turns the rules of answer matching on and off. The
+// top-level answerMatching applies to every game, and entries under matching,
+// keyed by difficulty, override it field by field. Unset fields keep the
+// built-in defaults, which have every rule on but phonetic.
+type AnswerMatchingConfig struct {
+	// Typos forgiven, as a fraction of the answer's length: the default 0.2
+	// allows one edit in a five-letter answer and two in a ten-letter one.
+	// 0 accepts exact matches only.
+	FuzzyThreshold *float64 `json:"fuzzyThreshold"`
+	FoldPlurals    *bool    `json:"foldPlurals"`    // Match "footstep" with "footsteps"
+	Numbers        *bool    `json:"numbers"`        // Match "eight" with "8"
+	Compounds      *bool    `json:"compounds"`      // Match "fire-fly" with "firefly"
+	PartialGuesses *bool    `json:"partialGuesses"` // Accept a guess that is part of a longer answer, "piano" for "grand piano"
+	ExtractAnswers *bool    `json:"extractAnswers"` // Check only the answer picked out of a wordy reply
+	Phonetic       *bool    `json:"phonetic"`       // Accept a one-word guess that sounds like the answer, "nite" for "night"; off by default
+	// A matching guess with more than this fraction of its words taken from
+	// the riddle is checked for quoting it back, see answers.Checker.Restates. Defaults
+	// to 0.6; 0 turns the check off.
+	RestatedThreshold *float64 `json:"restatedThreshold"`
+}
+
+// MatchingRules are the answer-matching rules a game is played with
+type MatchingRules struct {
+	Profile           string  `json:"profile"` // The difficulty whose matching entry applied, or "default"
+	FuzzyThreshold    float64 `json:"fuzzyThreshold"`
+	FoldPlurals       bool    `json:"foldPlurals"`
+	Numbers           bool    `json:"numbers"`
+	Compounds         bool    `json:"compounds"`
+	PartialGuesses    bool    `json:"partialGuesses"`
+	ExtractAnswers    bool    `json:"extractAnswers"`
+	Phonetic          bool    `json:"phonetic"`
+	RestatedThreshold float64 `json:"restatedThreshold"`
+}
+
+// matchingFor resolves the rules for a difficulty in the current config
+func matchingFor(difficulty string) MatchingRules {
+	return resolveMatching(currentConfig(), difficulty)
+}
+
+// resolveMatching resolves the rules for a difficulty from its matching
+// entry, then answerMatching, then the built-in defaults
+func resolveMatching(config *Config, difficulty string) MatchingRules {
+	base := config.AnswerMatching
+	profile, ok := config.Matching[difficulty]
+	rules := MatchingRules{Profile: "default"}
+	if ok {
+		rules.Profile = difficulty
+	}
+
+	flag := func(fallback bool, values ...*bool) bool {
+		for _, v := range values {
+			if v != nil {
+				return *v
+			}
+		}
+		return fallback
+	}
+	fraction := func(fallback float64, values ...*float64) float64 {
+		for _, v := range values {
+			if v != nil {
+				return *v
+			}
+		}
+		return fallback
+	}
+	rules.FuzzyThreshold = fraction(answers.DefaultFuzzyThreshold, profile.FuzzyThreshold, base.FuzzyThreshold)
+	rules.RestatedThreshold = fraction(answers.DefaultRestatedThreshold, profile.RestatedThreshold, base.RestatedThreshold)
+	rules.FoldPlurals = flag(true, profile.FoldPlurals, base.FoldPlurals)
+	rules.Numbers = flag(true, profile.Numbers, base.Numbers)
+	rules.Compounds = flag(true, profile.Compounds, base.Compounds)
+	rules.PartialGuesses = flag(true, profile.PartialGuesses, base.PartialGuesses)
+	rules.ExtractAnswers = flag(true, profile.ExtractAnswers, base.ExtractAnswers)
+	rules.Phonetic = flag(false, profile.Phonetic, base.Phonetic)
+	return rules
+}
+
+// checker builds the answer checker for the rules, with phrases from
+// phrases.json
+func (r MatchingRules) checker(phrases map[string]answers.Phrases) *answers.Checker {
+	return answers.New(
+		answers.WithPhrases(phrases),
+		answers.WithFuzzyThreshold(r.FuzzyThreshold),
+		answers.WithPluralFolding(r.FoldPlurals),
+		answers.WithNumbers(r.Numbers),
+		answers.WithCompounds(r.Compounds),
+		answers.WithPartialGuesses(r.PartialGuesses),
+		answers.WithPhonetic(r.Phonetic),
+		answers.WithRestatedThreshold(r.RestatedThreshold),
+	)
+}
+
+// buildAnswerCheckers builds a checker for each difficulty with a matching
+// entry, and one for the rest, when the config is loaded
+func buildAnswerCheckers(config *Config) {
+	config.defaultChecker = resolveMatching(config, "").checker(config.guessPhrases)
+	config.answerCheckers = make(map[string]*answers.Checker)
+	for difficulty := range config.Matching {
+		config.answerCheckers[difficulty] = resolveMatching(config, difficulty).checker(config.guessPhrases)
+	}
+}
+
+// answerChecker is the checker for games of a difficulty
+func answerChecker(difficulty string) *answers.Checker {
+	config := currentConfig()
+	if checker, ok := config.answerCheckers[difficulty]; ok {
+		return checker
+	}
+	if config.defaultChecker != nil {
+		return config.defaultChecker
+	}
+	return resolveMatching(config, difficulty).checker(config.guessPhrases)
+}
+
+// loadPhrases reads the optional phrases.json, the phrases stripped from
+// around guesses keyed by language. Languages it leaves out keep the
+// built-in phrases.
+func loadPhrases() map[string]answers.Phrases {
+	file, err := os.ReadFile(dataDir + "phrases.json")
+	if err != nil {
+		return nil
+	}
+	var phrases map[string]answers.Phrases
+	if err := json.Unmarshal(file, &phrases); err != nil {
+		log.Fatal("Error parsing phrases.json: ", err)
+	}
+	log.Printf("Loaded guess phrases for %d languages\n", len(phrases))
+	return phrases
+}
+
+// answerRiddle is what the game's guesses are checked against
+func (g *GameState) answerRiddle() *answers.Riddle {
+	return &answers.Riddle{
+		Text:     g.Riddle,
+		Answers:  g.Answers,
+		Pattern:  g.answerPattern,
+		Synonyms: g.Synonyms,
+		Language: g.language,
+		Exact:    g.ExactMatch,
+	}
+}
+
+// AnswerMatch is the verdict on a guess, by matching or by the answer judge
+type AnswerMatch struct {
+	answers.Match
+	Judged bool // Accepted by the answer judge rather than by matching
+}
+
+// checkAnswer matches a guess against the game's answers under the rules
+// for its difficulty
+func checkAnswer(guess string, game *GameState) AnswerMatch {
+	return AnswerMatch{Match: answerChecker(game.Difficulty).Check(guess, game.answerRiddle())}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LeaderboardConfig controls the size of the served leaderboard and how long
+// entries trimmed from it are kept in the archive
+type LeaderboardConfig struct {
+	MaxEntries           int `json:"maxEntries"`           // Size of the served top list, defaults to 100
+	ArchiveRetentionDays int `json:"archiveRetentionDays"` // 0 keeps archived entries forever
+}
+
+func (rm *room) archivePath() string {
+	return rm.dir + "leaderboard_archive.jsonl"
+}
+
+func leaderboardCap() int {
+	if max := currentConfig().Leaderboard.MaxEntries; max > 0 {
+		return max
+	}
+	return 100
+}
+
+// archiveEntries appends entries trimmed from the top list to the archive,
+// one JSON object per line so archiving never rewrites existing history
+func (rm *room) archiveEntries(entries []LeaderboardEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	rm.archiveMux.Lock()
+	defer rm.archiveMux.Unlock()
+
+	file, err := os.OpenFile(rm.archivePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Println("Error opening leaderboard archive:", err)
+		return
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			log.Println("Error archiving leaderboard entry:", err)
+			return
+		}
+	}
+}
+
+// loadArchive reads every archived entry, skipping lines that fail to parse
+func (rm *room) loadArchive() []LeaderboardEntry {
+	rm.archiveMux.Lock()
+	defer rm.archiveMux.Unlock()
+	return rm.readArchiveLocked()
+}
+
+func (rm *room) readArchiveLocked() []LeaderboardEntry {
+	file, err := os.Open(rm.archivePath())
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var entries []LeaderboardEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry LeaderboardEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Println("Skipping unreadable archive line:", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// pruneArchive drops archived entries older than the configured retention
+func (rm *room) pruneArchive() {
+	days := currentConfig().Leaderboard.ArchiveRetentionDays
+	if days <= 0 {
+		return
+	}
+
+	rm.archiveMux.Lock()
+	defer rm.archiveMux.Unlock()
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	entries := rm.readArchiveLocked()
+	var kept []LeaderboardEntry
+	for _, entry := range entries {
+		if entry.Timestamp.After(cutoff) {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) == len(entries) {
+		return
+	}
+
+	if err := rm.writeArchiveLocked(kept); err != nil {
+		log.Println("Error pruning leaderboard archive:", err)
+		return
+	}
+	log.Printf("Pruned %d archived leaderboard entries older than %d days\n", len(entries)-len(kept), days)
+}
+
+func (rm *room) writeArchiveLocked(entries []LeaderboardEntry) error {
+	var data []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	return os.WriteFile(rm.archivePath(), data, 0644)
+}
+
+// updateLeaderboardEntry applies update to the entry with the given ID,
+// wherever it lives, and persists it. It reports whether the entry was found.
+func (rm *room) updateLeaderboardEntry(id string, update func(entry *LeaderboardEntry)) bool {
+	rm.leaderboardMux.Lock()
+	for i := range rm.leaderboard {
+		if rm.leaderboard[i].ID == id {
+			update(&rm.leaderboard[i])
+			rm.saveLeaderboard()
+			rm.leaderboardMux.Unlock()
+			return true
+		}
+	}
+	rm.leaderboardMux.Unlock()
+
+	rm.archiveMux.Lock()
+	defer rm.archiveMux.Unlock()
+
+	archived := rm.readArchiveLocked()
+	for i := range archived {
+		if archived[i].ID == id {
+			update(&archived[i])
+			if err := rm.writeArchiveLocked(archived); err != nil {
+				log.Println("Error rewriting leaderboard archive:", err)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// allLeaderboardEntries returns the served top list followed by the archive,
+// for history queries that must not be limited to the top scores
+func (rm *room) allLeaderboardEntries() []LeaderboardEntry {
+	rm.leaderboardMux.Lock()
+	entries := append([]LeaderboardEntry(nil), rm.leaderboard...)
+	rm.leaderboardMux.Unlock()
+
+	return append(entries, rm.loadArchive()...)
+}
+
+// handleUserGames serves GET /users/{username}/games, a player's full game
+// history across the top list and the archive, newest first
+func handleUserGames(w http.ResponseWriter, r *http.Request, rm *room) {
+	path := strings.TrimPrefix(r.URL.Path, "/users/")
+	username, ok := strings.CutSuffix(path, "/games")
+	if !ok || username == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	games := []LeaderboardEntry{}
+	for _, entry := range rm.allLeaderboardEntries() {
+		if entry.Username == username && !entry.Hidden {
+			games = append(games, entry)
+		}
+	}
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].Timestamp.After(games[j].Timestamp)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(games)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestArchivedGamesInHistory plays more games than the top list holds and
+// checks that a player's 150th-best game, trimmed into the archive, is still
+// in their personal history
+func TestArchivedGamesInHistory(t *testing.T) {
+	model := ModelConfig{Name: "Right", Provider: "mock", Model: "always-correct"}
+	useConfig(t, Config{Models: []ModelConfig{model}})
+	rm := rooms[defaultRoom]
+
+	// Each game is slower than the last, so scores fall and the last game is
+	// the player's 150th best
+	const played = 150
+	start := time.Now().Add(-played * time.Minute)
+	for i := 1; i <= played; i++ {
+		game := newTestGame(t, "piano", model)
+		game.Username = "alice"
+		rm.addToLeaderboard(game, GameResult{
+			CorrectCount: 1,
+			TotalModels:  1,
+			Difficulty:   "easy",
+			Duration:     60 + float64(i)*10,
+			RoundsPlayed: 1,
+			Timestamp:    start.Add(time.Duration(i) * time.Minute),
+			Username:     "alice",
+		})
+	}
+
+	var worst LeaderboardEntry
+	for _, entry := range rm.loadArchive() {
+		if worst.ID == "" || entry.Duration > worst.Duration {
+			worst = entry
+		}
+	}
+	if worst.Duration != 60+played*10 {
+		t.Fatalf("slowest archived game took %v, want the last game played", worst.Duration)
+	}
+	if n := len(rm.leaderboard); n != leaderboardCap() {
+		t.Errorf("top list holds %d games, want %d", n, leaderboardCap())
+	}
+	for _, entry := range rm.leaderboard {
+		if entry.ID == worst.ID {
+			t.Fatal("the 150th-best game is still on the top list")
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handleUserGames(w, httptest.NewRequest(http.MethodGet, "/users/alice/games", nil), rm)
+	var history []LeaderboardEntry
+	if err := json.NewDecoder(w.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != played {
+		t.Errorf("history has %d games, want all %d", len(history), played)
+	}
+	found := false
+	for _, entry := range history {
+		found = found || entry.ID == worst.ID
+	}
+	if !found {
+		t.Error("the archived 150th-best game is missing from the player's history")
+	}
+	if len(history) > 0 && !history[0].Timestamp.After(history[len(history)-1].Timestamp) {
+		t.Error("history is not newest first")
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tahcohcat/turingroulette/internal/answers"
+)
+
+// BenchmarkReport is what a benchmark run leaves behind: how each model did
+// on the riddle set, and the difficulty calibration of the set
+type BenchmarkReport struct {
+	Riddles     string            `json:"riddles"` // The riddle set file
+	StartedAt   time.Time         `json:"startedAt"`
+	FinishedAt  time.Time         `json:"finishedAt"`
+	Games       int               `json:"games"`
+	Skipped     []BenchmarkSkip   `json:"skipped,omitempty"`
+	Models      []BenchmarkModel  `json:"models"` // Best solve rate first
+	Calibration CalibrationReport `json:"calibration"`
+}
+
+// BenchmarkSkip is a riddle from the set that couldn't be played
+type BenchmarkSkip struct {
+	Index  int    `json:"index"` // Position in the riddle set, from 0
+	Riddle string `json:"riddle"`
+	Reason string `json:"reason"`
+}
+
+// BenchmarkModel is one model's summary over the benchmark's games
+type BenchmarkModel struct {
+	Name                 string         `json:"name"`
+	Provider             string         `json:"provider"`
+	InstanceLabel        string         `json:"instanceLabel"`
+	Games                int            `json:"games"`
+	Solved               int            `json:"solved"`
+	SolveRate            float64        `json:"solveRate"` // Percentage of games solved
+	AvgGuessesToCorrect  float64        `json:"avgGuessesToCorrect"`
+	AvgResponseTime      float64        `json:"avgResponseTime"`
+	AvgFirstTokenLatency *float64       `json:"avgFirstTokenLatency,omitempty"`
+	Timeouts             map[string]int `json:"timeouts,omitempty"` // By tier, as in ModelStats.TimeoutsByTier
+	Truncations          int            `json:"truncations"`
+	TokensUsed           TokensUsed     `json:"tokensUsed"`
+}
+
+// runBenchmark plays every riddle in the set at path against every enabled
+// model, or the riddle's own models, and writes report.json and report.md to
+// outDir. The games are recorded in outDir too, as a room of their own, so a
+// benchmark never touches the real stats or leaderboard.
+func runBenchmark(path, outDir string) (BenchmarkReport, error) {
+	report := BenchmarkReport{Riddles: path, StartedAt: time.Now()}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, err
+	}
+	var riddles []RiddleSubmission
+	if err := json.Unmarshal(data, &riddles); err != nil {
+		return report, fmt.Errorf("reading riddle set %s: %w", path, err)
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return report, err
+	}
+
+	rm := &room{name: "benchmark", dir: strings.TrimSuffix(outDir, "/") + "/"}
+	rm.loadStats()
+	rm.loadLeaderboard()
+	for i, submission := range riddles {
+		game, err := newBenchmarkGame(submission, rm)
+		if err != nil {
+			log.Printf("Benchmark: skipping riddle %d: %v\n", i, err)
+			report.Skipped = append(report.Skipped, BenchmarkSkip{Index: i, Riddle: submission.Riddle, Reason: err.Error()})
+			continue
+		}
+		log.Printf("Benchmark: riddle %d of %d against %d models\n", i+1, len(riddles), len(game.SelectedModels))
+		playRound(context.Background(), newClient(nil, nil), game)
+	}
+
+	rm.statsMux.Lock()
+	report.Games = rm.stats.TotalGames
+	for _, stats := range rm.stats.ByModel {
+		report.Models = append(report.Models, BenchmarkModel{
+			Name:                 stats.Name,
+			Provider:             stats.Provider,
+			InstanceLabel:        stats.InstanceLabel,
+			Games:                stats.GamesPlayed,
+			Solved:               stats.TimesCorrect,
+			SolveRate:            stats.Accuracy,
+			AvgGuessesToCorrect:  stats.AvgGuessesToCorrect,
+			AvgResponseTime:      stats.AvgResponseTime,
+			AvgFirstTokenLatency: stats.AvgFirstTokenLatency,
+			Timeouts:             stats.TimeoutsByTier,
+			Truncations:          stats.Truncations,
+			TokensUsed:           stats.TokensUsed,
+		})
+	}
+	rm.statsMux.Unlock()
+	sort.Slice(report.Models, func(i, j int) bool {
+		a, b := report.Models[i], report.Models[j]
+		if a.SolveRate != b.SolveRate {
+			return a.SolveRate > b.SolveRate
+		}
+		return a.Name+"@"+a.InstanceLabel < b.Name+"@"+b.InstanceLabel
+	})
+	report.Calibration = buildCalibrationReport(rm.allLeaderboardEntries())
+	report.FinishedAt = time.Now()
+
+	data, _ = json.MarshalIndent(report, "", "  ")
+	if err := os.WriteFile(filepath.Join(outDir, "report.json"), data, 0644); err != nil {
+		return report, err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "report.md"), []byte(report.markdown()), 0644); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// newBenchmarkGame sets up a riddle from the set the way a submitted one is,
+// with no player watching and no commentary
+func newBenchmarkGame(submission RiddleSubmission, rm *room) (*GameState, error) {
+	accepted := submission.acceptedAnswers()
+	if len(accepted) == 0 {
+		return nil, fmt.Errorf("no answer")
+	}
+	pattern, err := answers.CompilePattern(submission.AnswerPattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid answer pattern: %w", err)
+	}
+	lang, err := parseLanguage(submission.Language)
+	if err != nil {
+		return nil, fmt.Errorf("invalid language: %w", err)
+	}
+	synonyms, err := checkSynonyms(submission.Synonyms, lang)
+	if err != nil {
+		return nil, fmt.Errorf("invalid synonyms: %w", err)
+	}
+
+	config := currentConfig()
+	chosen := submission.Models
+	if len(chosen) == 0 {
+		for _, model := range config.Models {
+			if model.IsEnabled() {
+				chosen = append(chosen, model.Name)
+			}
+		}
+	}
+	selected, _ := selectModels(config, chosen)
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("none of its models are enabled")
+	}
+
+	states := make(map[string]ModelState, len(selected))
+	for _, model := range selected {
+		states[model.Name] = ModelState{}
+	}
+	username := submission.Username
+	if username == "" {
+		username = "benchmark"
+	}
+	return &GameState{
+		Riddle:         submission.Riddle,
+		Answer:         accepted[0],
+		Answers:        accepted,
+		AnswerPattern:  submission.AnswerPattern,
+		Synonyms:       synonyms,
+		Language:       lang.String(),
+		ExactMatch:     submission.ExactMatch,
+		SharedGuesses:  submission.SharedGuesses,
+		Clues:          submission.Clues,
+		Difficulty:     submission.Difficulty,
+		ModelStates:    states,
+		StartTime:      time.Now(),
+		Username:       username,
+		SelectedModels: selected,
+		Tags:           submission.Tags,
+		Room:           rm.name,
+		ChosenModels:   len(submission.Models) > 0,
+		room:           rm,
+		answerPattern:  pattern,
+		language:       lang,
+	}, nil
+}
+
+// markdown renders the report as report.md, for pasting into an issue or PR
+func (r BenchmarkReport) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Benchmark: %s\n\n", filepath.Base(r.Riddles))
+	fmt.Fprintf(&b, "%d games, %s to %s (%s)\n\n", r.Games, r.StartedAt.Format(time.RFC3339), r.FinishedAt.Format(time.RFC3339), r.FinishedAt.Sub(r.StartedAt).Round(time.Second))
+
+	b.WriteString("| Model | Instance | Solved | Solve rate | Guesses to solve | Response time | First token | Timeouts | Tokens |\n")
+	b.WriteString("|---|---|---|---|---|---|---|---|---|\n")
+	for _, m := range r.Models {
+		firstToken := "unknown"
+		if m.AvgFirstTokenLatency != nil {
+			firstToken = fmt.Sprintf("%.2fs", *m.AvgFirstTokenLatency)
+		}
+		timeouts := 0
+		for _, count := range m.Timeouts {
+			timeouts += count
+		}
+		fmt.Fprintf(&b, "| %s | %s | %d/%d | %.1f%% | %.2f | %.2fs | %s | %d | %d |\n",
+			m.Name, m.InstanceLabel, m.Solved, m.Games, m.SolveRate, m.AvgGuessesToCorrect, m.AvgResponseTime, firstToken, timeouts, m.TokensUsed.TotalTokens)
+	}
+
+	if len(r.Calibration.Buckets) > 0 {
+		b.WriteString("\n## Difficulty calibration\n\n")
+		b.WriteString("| Claimed | Games | Player win rate | Model solve rate | Rounds to solve |\n")
+		b.WriteString("|---|---|---|---|---|\n")
+		var difficulties []string
+		for difficulty := range r.Calibration.Buckets {
+			difficulties = append(difficulties, difficulty)
+		}
+		sort.Strings(difficulties)
+		for _, difficulty := range difficulties {
+			bucket := r.Calibration.Buckets[difficulty]
+			fmt.Fprintf(&b, "| %s | %d | %.1f%% | %.1f%% | %.2f |\n", difficulty, bucket.Games, bucket.PlayerWinRate, bucket.ModelSolveRate, bucket.AvgRoundsToSolve)
+		}
+	}
+
+	if len(r.Skipped) > 0 {
+		b.WriteString("\n## Skipped\n\n")
+		for _, skip := range r.Skipped {
+			fmt.Fprintf(&b, "- %d %q: %s\n", skip.Index, skip.Riddle, skip.Reason)
+		}
+	}
+	return b.String()
+}
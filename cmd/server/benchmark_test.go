@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBenchmarkReport runs a small riddle set against mock models and checks
+// the report it leaves, and that the real room's stats are left alone
+func TestBenchmarkReport(t *testing.T) {
+	useConfig(t, Config{Models: []ModelConfig{
+		{Name: "Right", Provider: "mock", Model: "always-correct"},
+		{Name: "Wrong", Provider: "mock", Model: "always-wrong"},
+	}})
+	riddles := []RiddleSubmission{
+		{Riddle: "What has keys but can't open locks?", Answers: []string{"piano"}, Difficulty: "easy"},
+		{Riddle: "What has hands but can't clap?", Answers: []string{"clock"}, Clues: []string{"It ticks"}, Difficulty: "hard"},
+		{Riddle: "What runs but never walks?", Answers: []string{"river"}, Difficulty: "medium", Models: []string{"Right"}},
+		{Riddle: "No answer given", Difficulty: "easy"},
+	}
+	data, _ := json.Marshal(riddles)
+	set := filepath.Join(t.TempDir(), "riddles.json")
+	if err := os.WriteFile(set, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(t.TempDir(), "run")
+
+	if _, err := runBenchmark(set, outDir); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(outDir, "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report BenchmarkReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Games != 3 {
+		t.Errorf("%d games in the report, want 3", report.Games)
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].Index != 3 {
+		t.Errorf("skipped %+v, want the riddle without an answer", report.Skipped)
+	}
+	if len(report.Models) != 2 {
+		t.Fatalf("%d models in the report, want 2", len(report.Models))
+	}
+	right, wrong := report.Models[0], report.Models[1]
+	if right.Name != "Right" || right.Games != 3 || right.Solved != 3 || right.SolveRate != 100 {
+		t.Errorf("first model %+v, want Right solving all 3 games", right)
+	}
+	if wrong.Name != "Wrong" || wrong.Games != 2 || wrong.Solved != 0 {
+		t.Errorf("second model %+v, want Wrong solving none of its 2 games", wrong)
+	}
+	if report.Calibration.Games != 3 || report.Calibration.Buckets["hard"].Games != 1 {
+		t.Errorf("calibration %+v, want the 3 games bucketed by difficulty", report.Calibration)
+	}
+
+	markdown, err := os.ReadFile(filepath.Join(outDir, "report.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"| Right |", "3/3", "| Wrong |", "0/2", "No answer given"} {
+		if !strings.Contains(string(markdown), want) {
+			t.Errorf("report.md is missing %q:\n%s", want, markdown)
+		}
+	}
+
+	if games := rooms[defaultRoom].stats.TotalGames; games != 0 {
+		t.Errorf("the benchmark recorded %d games in the default room", games)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// CircuitBreakerConfig sets when a model that keeps failing is skipped. Zero
+// values fall back to the defaults below.
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failureThreshold"` // Consecutive failed rounds that open the breaker; negative disables it
+	CooldownSeconds  int `json:"cooldownSeconds"`  // How long the model is skipped before a probe call
+}
+
+var defaultCircuitBreaker = CircuitBreakerConfig{
+	FailureThreshold: 5,
+	CooldownSeconds:  60,
+}
+
+// ErrModelUnavailable is the round's error for a model skipped by its breaker
+var ErrModelUnavailable = errors.New("model unavailable after repeated failures")
+
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "halfOpen"
+)
+
+// circuitBreaker tracks one model's consecutive failures. Once open it turns
+// calls away until the cooldown ends, then lets a single probe through: a
+// success closes it again and a failure restarts the cooldown.
+type circuitBreaker struct {
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	OpenedAt            time.Time `json:"openedAt"`
+	RetryAt             time.Time `json:"retryAt"` // When the next probe may go out
+	LastError           string    `json:"lastError,omitempty"`
+}
+
+var (
+	breakersMux sync.Mutex
+	breakers    = make(map[string]*circuitBreaker)
+)
+
+func circuitBreakerConfig() CircuitBreakerConfig {
+	c := currentConfig().CircuitBreaker
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = defaultCircuitBreaker.FailureThreshold
+	}
+	if c.CooldownSeconds <= 0 {
+		c.CooldownSeconds = defaultCircuitBreaker.CooldownSeconds
+	}
+	return c
+}
+
+// allowCall reports whether the model may be called now. After the cooldown
+// the first caller gets through as the probe; others are turned away until
+// it reports back.
+func allowCall(model string) bool {
+	breakersMux.Lock()
+	defer breakersMux.Unlock()
+
+	b := breakers[model]
+	if b == nil {
+		return true
+	}
+	switch b.State {
+	case breakerOpen:
+		if time.Now().Before(b.RetryAt) {
+			return false
+		}
+		b.State = breakerHalfOpen
+		log.Printf("Circuit breaker for %s half-open, probing\n", model)
+		return true
+	case breakerHalfOpen:
+		return false
+	}
+	return true
+}
+
+// recordCall feeds a call's outcome to the model's breaker
+func recordCall(model string, err error) {
+	config := circuitBreakerConfig()
+	if config.FailureThreshold < 0 {
+		return
+	}
+
+	breakersMux.Lock()
+	defer breakersMux.Unlock()
+
+	// A filtered response still came from a working provider
+	if errors.Is(err, providers.ErrContentFiltered) {
+		err = nil
+	}
+
+	b := breakers[model]
+	if errors.Is(err, ErrRateLimited) {
+		// Never reached the provider, so it says nothing either way. A probe
+		// that didn't go out leaves the way open for the next one.
+		if b != nil && b.State == breakerHalfOpen {
+			b.State = breakerOpen
+		}
+		return
+	}
+	if err == nil {
+		if b != nil && b.State != breakerClosed {
+			log.Printf("Circuit breaker for %s closed\n", model)
+		}
+		delete(breakers, model)
+		return
+	}
+
+	if b == nil {
+		b = &circuitBreaker{State: breakerClosed}
+		breakers[model] = b
+	}
+	b.ConsecutiveFailures++
+	b.LastError = err.Error()
+	if b.State == breakerHalfOpen || b.ConsecutiveFailures >= config.FailureThreshold {
+		b.State = breakerOpen
+		b.OpenedAt = time.Now()
+		b.RetryAt = b.OpenedAt.Add(time.Duration(config.CooldownSeconds) * time.Second)
+		log.Printf("Circuit breaker for %s open after %d failures, retrying at %s: %v\n", model, b.ConsecutiveFailures, b.RetryAt.Format(time.RFC3339), err)
+	}
+}
+
+// handleAdminBreakers serves GET /admin/breakers: the breakers of models that
+// have failed since their last success, keyed by model name
+func handleAdminBreakers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	breakersMux.Lock()
+	snapshot := make(map[string]circuitBreaker, len(breakers))
+	for model, b := range breakers {
+		snapshot[model] = *b
+	}
+	breakersMux.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot)
+}
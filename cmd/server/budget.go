@@ -0,0 +1,45 @@
+package main
+
+import "log"
+
+const (
+	// keptClues is how many of the latest clues a prompt keeps however far
+	// over its context budget it is
+	keptClues = 2
+	// summarizedGuesses is how many of the latest wrong guesses a prompt
+	// names once the rest are summarized to fit
+	summarizedGuesses = 3
+)
+
+// fitPrompt renders a prompt template, cutting the prompt down until its
+// estimated tokens fit in budget: the oldest clues go first, down to the
+// latest keptClues, then the wrong guesses are summarized as a count and the
+// latest few, then the examples go, last first. It reports whether anything
+// was cut.
+func fitPrompt(name string, data PromptData, modelCfg ModelConfig, budget int) (string, bool) {
+	prompt := renderPromptData(name, data, modelCfg)
+	cut := false
+	for estimateTokens(prompt) > budget {
+		switch {
+		case len(data.Clues) > keptClues:
+			data.Clues = data.Clues[1:]
+			data.CluesOmitted++
+		case data.IncorrectTotal == 0 && len(data.Incorrect) > summarizedGuesses:
+			data.IncorrectTotal = len(data.Incorrect)
+			data.Incorrect = data.Incorrect[len(data.Incorrect)-summarizedGuesses:]
+		case len(data.Examples) > 0:
+			data.Examples = data.Examples[:len(data.Examples)-1]
+		default:
+			log.Printf("Prompt for %s is still over its context budget of %d tokens with nothing left to cut\n",
+				modelCfg.Name, modelCfg.ContextBudget)
+			return prompt, cut
+		}
+		cut = true
+		prompt = renderPromptData(name, data, modelCfg)
+	}
+	if cut {
+		log.Printf("Prompt for %s cut to fit its context budget of %d tokens: %d clues left out, %d wrong guesses summarized\n",
+			modelCfg.Name, modelCfg.ContextBudget, data.CluesOmitted, data.IncorrectTotal)
+	}
+	return prompt, cut
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// CachedResponse is a provider response saved for replay in development, see
+// Config.CacheResponses
+type CachedResponse struct {
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	Prompt   string    `json:"prompt"`
+	Response string    `json:"response"`
+	CachedAt time.Time `json:"cachedAt"`
+}
+
+func responseCacheDir() string {
+	return dataDir + "cache/"
+}
+
+// cacheKey is everything that shapes a provider's request, so a response is
+// only reused for the same request. Name, credentials and where the request
+// goes are left out: they don't change what the model is asked.
+type cacheKey struct {
+	Provider          string                    `json:"provider"`
+	Model             string                    `json:"model"`
+	Prompt            string                    `json:"prompt"`
+	Messages          []ChatMessage             `json:"messages,omitempty"`
+	SystemPrompt      string                    `json:"systemPrompt,omitempty"`
+	Temperature       *float64                  `json:"temperature,omitempty"`
+	MaxTokens         *int                      `json:"maxTokens,omitempty"`
+	Reasoning         bool                      `json:"reasoning,omitempty"`
+	ReasoningEffort   string                    `json:"reasoningEffort,omitempty"`
+	Thinking          *providers.ThinkingConfig `json:"thinking,omitempty"`
+	StructuredAnswers bool                      `json:"structuredAnswers,omitempty"`
+	ReasoningMode     bool                      `json:"reasoningMode,omitempty"`
+	LegacyGenerate    bool                      `json:"legacyGenerate,omitempty"`
+	Streaming         bool                      `json:"streaming,omitempty"`
+	Options           map[string]interface{}    `json:"options,omitempty"`
+}
+
+// responseCachePath names the cache file for a request to a provider's model:
+// the prompt, the conversation and the model's request options
+func responseCachePath(modelCfg ModelConfig, prompt string, messages []ChatMessage) string {
+	// Maps are encoded with sorted keys, so equal requests hash the same
+	key, _ := json.Marshal(cacheKey{
+		Provider:          modelCfg.Provider,
+		Model:             modelCfg.Model,
+		Prompt:            prompt,
+		Messages:          messages,
+		SystemPrompt:      modelCfg.SystemPrompt,
+		Temperature:       modelCfg.Temperature,
+		MaxTokens:         modelCfg.MaxTokens,
+		Reasoning:         modelCfg.Reasoning,
+		ReasoningEffort:   modelCfg.ReasoningEffort,
+		Thinking:          modelCfg.Thinking,
+		StructuredAnswers: modelCfg.StructuredAnswers,
+		ReasoningMode:     modelCfg.ReasoningMode,
+		LegacyGenerate:    modelCfg.LegacyGenerate,
+		Streaming:         modelCfg.Streaming,
+		Options:           modelCfg.Options,
+	})
+	sum := sha256.Sum256(key)
+	return responseCacheDir() + hex.EncodeToString(sum[:]) + ".json"
+}
+
+// cachedResponse returns the saved response to the same request, if there is one
+func cachedResponse(modelCfg ModelConfig, prompt string, messages []ChatMessage) (string, bool) {
+	file, err := os.ReadFile(responseCachePath(modelCfg, prompt, messages))
+	if err != nil {
+		return "", false
+	}
+	var cached CachedResponse
+	if err := json.Unmarshal(file, &cached); err != nil {
+		log.Printf("Ignoring unreadable cached response for %s: %v\n", modelCfg.Name, err)
+		return "", false
+	}
+	return cached.Response, true
+}
+
+// cacheResponse saves a successful response for cachedResponse to find
+func cacheResponse(modelCfg ModelConfig, prompt string, messages []ChatMessage, response string) {
+	data, _ := json.MarshalIndent(CachedResponse{
+		Provider: modelCfg.Provider,
+		Model:    modelCfg.Model,
+		Prompt:   prompt,
+		Response: response,
+		CachedAt: time.Now(),
+	}, "", "  ")
+
+	os.MkdirAll(responseCacheDir(), 0755)
+	if err := os.WriteFile(responseCachePath(modelCfg, prompt, messages), data, 0644); err != nil {
+		log.Printf("Error caching response for %s: %v\n", modelCfg.Name, err)
+	}
+}
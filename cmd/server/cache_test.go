@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// TestCacheKeyCoversRequest checks that anything changing what a model is
+// asked changes the cache key, and nothing else does
+func TestCacheKeyCoversRequest(t *testing.T) {
+	dataDir = t.TempDir() + "/"
+	temperature, otherTemperature := 0.2, 0.9
+	maxTokens, otherMaxTokens := 64, 256
+	base := ModelConfig{
+		Name:        "GPT",
+		Provider:    "openai",
+		Model:       "gpt-4o",
+		Temperature: &temperature,
+		MaxTokens:   &maxTokens,
+		Options:     map[string]interface{}{"top_p": 0.5, "seed": 1},
+	}
+	prompt := "What has keys but can't open locks?"
+	messages := []ChatMessage{{Role: "user", Content: prompt}}
+	key := responseCachePath(base, prompt, messages)
+
+	tests := []struct {
+		name    string
+		change  func(m *ModelConfig, prompt *string, messages *[]ChatMessage)
+		sameKey bool
+	}{
+		{"prompt", func(m *ModelConfig, p *string, _ *[]ChatMessage) { *p += " Think carefully." }, false},
+		{"messages", func(_ *ModelConfig, _ *string, msgs *[]ChatMessage) {
+			*msgs = append([]ChatMessage{{Role: "assistant", Content: "a door"}}, *msgs...)
+		}, false},
+		{"no messages", func(_ *ModelConfig, _ *string, msgs *[]ChatMessage) { *msgs = nil }, false},
+		{"model", func(m *ModelConfig, _ *string, _ *[]ChatMessage) { m.Model = "gpt-4o-mini" }, false},
+		{"system prompt", func(m *ModelConfig, _ *string, _ *[]ChatMessage) { m.SystemPrompt = "You are a pirate." }, false},
+		{"temperature", func(m *ModelConfig, _ *string, _ *[]ChatMessage) { m.Temperature = &otherTemperature }, false},
+		{"default temperature", func(m *ModelConfig, _ *string, _ *[]ChatMessage) { m.Temperature = nil }, false},
+		{"max tokens", func(m *ModelConfig, _ *string, _ *[]ChatMessage) { m.MaxTokens = &otherMaxTokens }, false},
+		{"reasoning effort", func(m *ModelConfig, _ *string, _ *[]ChatMessage) { m.ReasoningEffort = "high" }, false},
+		{"thinking", func(m *ModelConfig, _ *string, _ *[]ChatMessage) {
+			m.Thinking = &providers.ThinkingConfig{BudgetTokens: 1024}
+		}, false},
+		{"structured answers", func(m *ModelConfig, _ *string, _ *[]ChatMessage) { m.StructuredAnswers = true }, false},
+		{"options", func(m *ModelConfig, _ *string, _ *[]ChatMessage) {
+			m.Options = map[string]interface{}{"top_p": 0.5, "seed": 2}
+		}, false},
+		{"options reordered", func(m *ModelConfig, _ *string, _ *[]ChatMessage) {
+			m.Options = map[string]interface{}{"seed": 1, "top_p": 0.5}
+		}, true},
+		{"name", func(m *ModelConfig, _ *string, _ *[]ChatMessage) { m.Name = "Renamed" }, true},
+		{"api key", func(m *ModelConfig, _ *string, _ *[]ChatMessage) { m.APIKey = "sk-other" }, true},
+		{"same temperature", func(m *ModelConfig, _ *string, _ *[]ChatMessage) {
+			same := temperature
+			m.Temperature = &same
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, p := base, prompt
+			msgs := append([]ChatMessage(nil), messages...)
+			tt.change(&model, &p, &msgs)
+			if got := responseCachePath(model, p, msgs) == key; got != tt.sameKey {
+				t.Errorf("same cache key = %v, want %v", got, tt.sameKey)
+			}
+		})
+	}
+}
+
+// TestCachedResponseMatchesRequest saves a response and checks it is only
+// served back to the same request
+func TestCachedResponseMatchesRequest(t *testing.T) {
+	dataDir = t.TempDir() + "/"
+	cold, hot := 0.0, 1.5
+	model := ModelConfig{Name: "GPT", Provider: "openai", Model: "gpt-4o", Temperature: &cold}
+	prompt := "What has keys but can't open locks?"
+
+	cacheResponse(model, prompt, nil, "piano")
+	if response, hit := cachedResponse(model, prompt, nil); !hit || response != "piano" {
+		t.Errorf("cachedResponse = %q, %v; want the saved response", response, hit)
+	}
+	model.Temperature = &hot
+	if response, hit := cachedResponse(model, prompt, nil); hit {
+		t.Errorf("a request at another temperature was answered from the cache with %q", response)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// CalibrationReport compares the difficulty players claim for their riddles
+// with how the games actually went
+type CalibrationReport struct {
+	Buckets map[string]CalibrationBucket `json:"buckets"` // Keyed by claimed difficulty
+	// Matrix counts games by claimed difficulty, then by the difficulty
+	// assessed from the game's model solve rate
+	Matrix map[string]map[string]int `json:"matrix"`
+	Games  int                       `json:"games"`
+}
+
+type CalibrationBucket struct {
+	Games            int     `json:"games"`
+	PlayerWinRate    float64 `json:"playerWinRate"`    // Percentage of games the player won
+	ModelSolveRate   float64 `json:"modelSolveRate"`   // Percentage of model attempts that solved the riddle
+	AvgRoundsToSolve float64 `json:"avgRoundsToSolve"` // Over models that solved it
+	AvgRoundsPlayed  float64 `json:"avgRoundsPlayed"`
+}
+
+// calibrationCache holds the last report per room, valid until the room
+// records another game
+type calibrationCache struct {
+	mu         sync.Mutex
+	report     *CalibrationReport
+	generation int
+}
+
+// assessDifficulty grades a game by the share of models that solved it
+func assessDifficulty(solveRate float64) string {
+	switch {
+	case solveRate >= 2.0/3:
+		return "easy"
+	case solveRate >= 1.0/3:
+		return "medium"
+	default:
+		return "hard"
+	}
+}
+
+// buildCalibrationReport computes the calibration report over a set of game
+// records. It is independent of where the records come from so the same
+// report can be produced for any riddle set.
+func buildCalibrationReport(entries []LeaderboardEntry) CalibrationReport {
+	report := CalibrationReport{
+		Buckets: make(map[string]CalibrationBucket),
+		Matrix:  make(map[string]map[string]int),
+	}
+
+	type totals struct {
+		games, wins, attempts, solves, solveRounds, rounds int
+	}
+	byDifficulty := make(map[string]*totals)
+
+	for _, entry := range entries {
+		t := byDifficulty[entry.Difficulty]
+		if t == nil {
+			t = &totals{}
+			byDifficulty[entry.Difficulty] = t
+		}
+
+		t.games++
+		t.rounds += entry.RoundsPlayed
+		if entry.PlayerWon {
+			t.wins++
+		}
+
+		solves := 0
+		for _, model := range entry.Models {
+			t.attempts++
+			if model.Correct {
+				solves++
+				t.solveRounds += model.Round
+			}
+		}
+		t.solves += solves
+
+		models := len(entry.Models)
+		if models == 0 {
+			models = entry.TotalModels
+		}
+		if models > 0 {
+			assessed := assessDifficulty(float64(entry.CorrectCount) / float64(models))
+			if report.Matrix[entry.Difficulty] == nil {
+				report.Matrix[entry.Difficulty] = make(map[string]int)
+			}
+			report.Matrix[entry.Difficulty][assessed]++
+		}
+		report.Games++
+	}
+
+	for difficulty, t := range byDifficulty {
+		bucket := CalibrationBucket{
+			Games:           t.games,
+			PlayerWinRate:   float64(t.wins) / float64(t.games) * 100,
+			AvgRoundsPlayed: float64(t.rounds) / float64(t.games),
+		}
+		if t.attempts > 0 {
+			bucket.ModelSolveRate = float64(t.solves) / float64(t.attempts) * 100
+		}
+		if t.solves > 0 {
+			bucket.AvgRoundsToSolve = float64(t.solveRounds) / float64(t.solves)
+		}
+		report.Buckets[difficulty] = bucket
+	}
+
+	return report
+}
+
+// calibrationReport returns the room's report, rebuilding it only when games
+// have been recorded since it was last computed
+func (rm *room) calibrationReport() CalibrationReport {
+	rm.leaderboardMux.Lock()
+	generation := rm.gamesRecorded
+	rm.leaderboardMux.Unlock()
+
+	rm.calibration.mu.Lock()
+	defer rm.calibration.mu.Unlock()
+
+	if rm.calibration.report == nil || rm.calibration.generation != generation {
+		report := buildCalibrationReport(rm.allLeaderboardEntries())
+		rm.calibration.report = &report
+		rm.calibration.generation = generation
+	}
+	return *rm.calibration.report
+}
+
+// handleDifficultyCalibration serves GET /stats/difficulty-calibration
+func handleDifficultyCalibration(w http.ResponseWriter, r *http.Request, rm *room) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rm.calibrationReport())
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+const defaultMaxActiveGames = 200
+
+// Rough per-entry overheads for estimating game memory; the estimate only
+// needs to track growth, not match the allocator exactly
+const (
+	gameBaseBytes   = 512
+	modelStateBytes = 256
+	perGuessBytes   = 64
+)
+
+func maxActiveGames() int {
+	if max := currentConfig().MaxActiveGames; max > 0 {
+		return max
+	}
+	return defaultMaxActiveGames
+}
+
+// approxBytes estimates the memory a game holds: its riddle and clues plus
+// every model's guess history, which grows each round
+func (g *GameState) approxBytes() int {
+	size := gameBaseBytes + len(g.Riddle) + len(g.Answer) + len(g.Username)
+	for _, answer := range g.Answers {
+		size += len(answer)
+	}
+	for _, clue := range g.Clues {
+		size += len(clue)
+	}
+	for _, state := range g.ModelStates {
+		size += modelStateBytes + len(state.Guess)
+		for _, guess := range state.AllGuesses {
+			size += perGuessBytes + len(guess)
+		}
+	}
+	return size
+}
+
+// GameCapacity is a snapshot of active games against the global ceiling
+type GameCapacity struct {
+	ActiveGames    int  `json:"activeGames"`
+	MaxActiveGames int  `json:"maxActiveGames"`
+	AtCapacity     bool `json:"atCapacity"`
+	ApproxBytes    int  `json:"approxGameMemoryBytes"`
+}
+
+// gameCapacityLocked reports current usage. Callers hold gamesMux.
+func gameCapacityLocked() GameCapacity {
+	capacity := GameCapacity{
+		ActiveGames:    len(games),
+		MaxActiveGames: maxActiveGames(),
+	}
+	capacity.AtCapacity = capacity.ActiveGames >= capacity.MaxActiveGames
+	for _, game := range games {
+		capacity.ApproxBytes += game.approxBytes()
+	}
+	return capacity
+}
+
+// callSlots is a semaphore capping provider calls in flight across all games,
+// nil while unlimited. A new one replaces it when MaxConcurrentModelCalls
+// changes; calls give their slot back to the one they took it from.
+var (
+	callSlotsMux sync.Mutex
+	callSlots    chan struct{}
+)
+
+func callSlotSemaphore() chan struct{} {
+	max := currentConfig().MaxConcurrentModelCalls
+
+	callSlotsMux.Lock()
+	defer callSlotsMux.Unlock()
+	switch {
+	case max <= 0:
+		callSlots = nil
+	case cap(callSlots) != max:
+		callSlots = make(chan struct{}, max)
+	}
+	return callSlots
+}
+
+// ModelCallCapacity is a snapshot of provider calls in flight against the
+// concurrency cap
+type ModelCallCapacity struct {
+	InFlight int `json:"inFlight"`
+	Max      int `json:"max"` // 0 when unlimited, in which case InFlight isn't tracked
+}
+
+func modelCallCapacity() ModelCallCapacity {
+	slots := callSlotSemaphore()
+	return ModelCallCapacity{InFlight: len(slots), Max: cap(slots)}
+}
+
+// handleStatus serves GET /status, the server's live load
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	gamesMux.Lock()
+	capacity := gameCapacityLocked()
+	gamesMux.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"games":      capacity,
+		"modelCalls": modelCallCapacity(),
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// TestActiveGameCeilingSoak keeps more players than the ceiling allows
+// starting games for several seconds. Active games must never pass the
+// ceiling, the memory estimate must stay bounded while games cycle through,
+// and both must return to nothing once the players leave.
+func TestActiveGameCeilingSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak test")
+	}
+	const ceiling, players = 4, 10
+	useConfig(t, Config{
+		Models: []ModelConfig{
+			{Name: "Slow", Provider: "mock", Model: "slow", Options: map[string]interface{}{"delayMs": 300.0}},
+			{Name: "Wrong", Provider: "mock", Model: "always-wrong"},
+		},
+		MaxActiveGames: ceiling,
+	})
+	url := serveGames(t)
+	submission := RiddleSubmission{
+		Riddle:     "What has keys but can't open locks?",
+		Answers:    []string{"piano"},
+		Difficulty: "easy",
+		Username:   "soak",
+	}
+
+	// Sample the gauge the status endpoint serves while games come and go
+	var maxActive, maxBytes int
+	stopSampling := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		for {
+			gamesMux.Lock()
+			capacity := gameCapacityLocked()
+			gamesMux.Unlock()
+			maxActive = max(maxActive, capacity.ActiveGames)
+			maxBytes = max(maxBytes, capacity.ApproxBytes)
+			select {
+			case <-stopSampling:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+
+	var finished, rejected atomic.Int64
+	var heapAfterWarmup uint64
+	deadline := time.Now().Add(8 * time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < players; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				conn.WriteJSON(submission)
+				conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+				for {
+					var message map[string]interface{}
+					if err := conn.ReadJSON(&message); err != nil {
+						t.Errorf("game never finished: %v", err)
+						break
+					}
+					if message["type"] == "gameFinished" {
+						finished.Add(1)
+						break
+					}
+					if message["type"] == "error" && message["model"] == nil {
+						rejected.Add(1)
+						time.Sleep(50 * time.Millisecond)
+						break
+					}
+				}
+				conn.Close()
+			}
+		}()
+	}
+	time.Sleep(2 * time.Second)
+	heapAfterWarmup = heapInUse()
+	wg.Wait()
+
+	// Games are dropped from the map as their handlers see the close
+	for start := time.Now(); ; time.Sleep(20 * time.Millisecond) {
+		gamesMux.Lock()
+		capacity := gameCapacityLocked()
+		gamesMux.Unlock()
+		if capacity.ActiveGames == 0 {
+			if capacity.ApproxBytes != 0 {
+				t.Errorf("memory estimate %d bytes with no games", capacity.ApproxBytes)
+			}
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("%d games still active after every player left", capacity.ActiveGames)
+		}
+	}
+	close(stopSampling)
+	<-sampled
+
+	t.Logf("%d games finished, %d rejected, at most %d active using ~%d bytes", finished.Load(), rejected.Load(), maxActive, maxBytes)
+	if maxActive > ceiling {
+		t.Errorf("%d games active at once, ceiling is %d", maxActive, ceiling)
+	}
+	if maxActive < ceiling || rejected.Load() == 0 {
+		t.Errorf("never reached the ceiling (at most %d active, %d rejected)", maxActive, rejected.Load())
+	}
+	if finished.Load() < 2*ceiling {
+		t.Errorf("only %d games finished, want games to cycle through the ceiling", finished.Load())
+	}
+	// Two models and a single round: well under 4KB a game
+	if maxBytes > ceiling*4096 {
+		t.Errorf("memory estimate reached %d bytes for %d games", maxBytes, ceiling)
+	}
+	if grown := int64(heapInUse()) - int64(heapAfterWarmup); grown > 16<<20 {
+		t.Errorf("heap grew by %d bytes while games cycled at the ceiling", grown)
+	}
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapInuse
+}
@@ -0,0 +1,187 @@
+//go:build chaos
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// Chaos testing injects provider faults so retry, timeout and error handling
+// can be exercised without a real outage. It only exists in binaries built
+// with -tags chaos and additionally requires TURINGROULETTE_CHAOS=1.
+
+// chaosProfile holds the probability (0-1) of each fault for one provider
+type chaosProfile struct {
+	Timeout     float64 `json:"timeout"`     // Never send the request, so the connect deadline expires
+	RateLimit   float64 `json:"rateLimit"`   // Answer with an HTTP 429
+	ServerError float64 `json:"serverError"` // Answer with an HTTP 500
+	Stall       float64 `json:"stall"`       // Pass the start of the real response, then hang
+	Garbage     float64 `json:"garbage"`     // Replace the real response with an unparseable one
+}
+
+var chaosProfiles = make(map[string]chaosProfile)
+var chaosMux sync.Mutex
+
+func chaosEnabled() bool {
+	return os.Getenv("TURINGROULETTE_CHAOS") == "1"
+}
+
+func registerChaosRoutes(mux *http.ServeMux) {
+	if !chaosEnabled() {
+		return
+	}
+	log.Println("WARNING: chaos testing enabled, provider faults will be injected")
+	mux.HandleFunc("/admin/chaos", requireAdmin(handleAdminChaos))
+}
+
+// handleAdminChaos serves GET and PUT /admin/chaos with profiles keyed by provider
+func handleAdminChaos(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var profiles map[string]chaosProfile
+		if err := json.NewDecoder(r.Body).Decode(&profiles); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		chaosMux.Lock()
+		chaosProfiles = profiles
+		chaosMux.Unlock()
+		log.Printf("Chaos profiles updated: %+v\n", profiles)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chaosMux.Lock()
+	defer chaosMux.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chaosProfiles)
+}
+
+// chaosStallAfter is how much of a real response a stall lets through first,
+// enough for a few streamed tokens
+const chaosStallAfter = 256
+
+// pickChaosFault rolls the provider's profile and returns the fault to inject
+// into the call's HTTP requests, or nil to leave them alone. Providers that
+// make no HTTP requests, such as the mock, are never affected.
+func pickChaosFault(provider string) providers.Interceptor {
+	if !chaosEnabled() {
+		return nil
+	}
+
+	chaosMux.Lock()
+	profile, ok := chaosProfiles[provider]
+	chaosMux.Unlock()
+	if !ok {
+		return nil
+	}
+
+	roll := rand.Float64()
+	faults := []struct {
+		probability float64
+		fault       providers.Interceptor
+	}{
+		{profile.Timeout, chaosTimeout},
+		{profile.RateLimit, chaosStatus(http.StatusTooManyRequests)},
+		{profile.ServerError, chaosStatus(http.StatusInternalServerError)},
+		{profile.Stall, chaosStall},
+		{profile.Garbage, chaosGarbage},
+	}
+	for _, f := range faults {
+		if roll < f.probability {
+			return f.fault
+		}
+		roll -= f.probability
+	}
+	return nil
+}
+
+// chaosTimeout never sends the request, like a host that doesn't answer, so
+// the call runs into its connect deadline
+func chaosTimeout(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, context.Cause(req.Context())
+}
+
+// chaosStatus answers in the provider's place with an HTTP error, so it is
+// parsed and retried like a real one
+func chaosStatus(status int) providers.Interceptor {
+	return func(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+		body := fmt.Sprintf(`{"error": {"message": "chaos: injected %s"}}`, http.StatusText(status))
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode: status,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	}
+}
+
+// chaosStall sends the request and lets the first part of the response
+// through, then goes quiet until the provider gives up on it
+func chaosStall(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	resp, err := send(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+	resp.Body = &stalledBody{body: resp.Body, left: chaosStallAfter, closed: make(chan struct{})}
+	return resp, nil
+}
+
+// stalledBody reads through to body until left bytes have been read, then
+// blocks until it is closed
+type stalledBody struct {
+	body      io.ReadCloser
+	left      int
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (b *stalledBody) Read(p []byte) (int, error) {
+	if b.left > 0 {
+		if len(p) > b.left {
+			p = p[:b.left]
+		}
+		n, err := b.body.Read(p)
+		b.left -= n
+		return n, err
+	}
+	<-b.closed
+	return 0, net.ErrClosed
+}
+
+func (b *stalledBody) Close() error {
+	b.closeOnce.Do(func() { close(b.closed) })
+	return b.body.Close()
+}
+
+// chaosGarbage sends the request and swaps a successful response's body for
+// one no provider can parse
+func chaosGarbage(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	resp, err := send(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(strings.NewReader("data: {\"choices\":[\x00\n\n{\"choices\":[\x00\n"))
+	resp.ContentLength = -1
+	return resp, nil
+}
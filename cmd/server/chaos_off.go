@@ -0,0 +1,17 @@
+//go:build !chaos
+
+package main
+
+import (
+	"net/http"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// Chaos testing is compiled out of normal builds; see chaos.go
+
+func registerChaosRoutes(mux *http.ServeMux) {}
+
+func pickChaosFault(provider string) providers.Interceptor {
+	return nil
+}
@@ -0,0 +1,150 @@
+//go:build chaos
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// answeringServer speaks the OpenAI streaming protocol and always guesses
+// "piano", a word at a time, in a reply long enough to stall partway through
+func answeringServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, word := range strings.Fields("Thinking it over, the answer you are looking for is a piano") {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", word+" ")
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestChaosGames plays a game under each fault profile and checks what
+// players rely on whatever the providers do: every model's card is settled
+// each round, the game finishes, and the stats it leaves are consistent
+func TestChaosGames(t *testing.T) {
+	t.Setenv("TURINGROULETTE_CHAOS", "1")
+	server := answeringServer(t)
+
+	var models []ModelConfig
+	for _, name := range []string{"Alpha", "Beta", "Gamma"} {
+		models = append(models, ModelConfig{Name: name, Provider: "openai-compatible", Model: "test", Endpoint: server.URL, IdleTimeoutSeconds: 1})
+	}
+	useConfig(t, Config{
+		Models:         models,
+		Timeouts:       map[string]TimeoutConfig{"default": {ConnectSeconds: 0.5, FirstTokenSeconds: 2, TotalSeconds: 5}},
+		Retries:        map[string]RetryConfig{"default": {MaxAttempts: 2, BaseDelayMs: 10}},
+		CircuitBreaker: CircuitBreakerConfig{FailureThreshold: -1},
+	})
+	url := serveGames(t)
+
+	tests := []struct {
+		name     string
+		profile  chaosProfile
+		category string // Every model's error category, when the fault always strikes
+	}{
+		{"none", chaosProfile{}, ""},
+		{"timeout", chaosProfile{Timeout: 1}, "timeout:connect"},
+		{"rateLimit", chaosProfile{RateLimit: 1}, "provider"},
+		{"serverError", chaosProfile{ServerError: 1}, "provider"},
+		{"stall", chaosProfile{Stall: 1}, "stalled"},
+		{"garbage", chaosProfile{Garbage: 1}, "provider"},
+		{"mixed", chaosProfile{Timeout: 0.1, RateLimit: 0.1, ServerError: 0.1, Stall: 0.1, Garbage: 0.1}, "*"},
+	}
+	played := 0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chaosMux.Lock()
+			chaosProfiles = map[string]chaosProfile{"openai-compatible": tt.profile}
+			chaosMux.Unlock()
+
+			messages := playGame(t, url, "", RiddleSubmission{
+				Riddle:     "What has keys but can't open locks?",
+				Answers:    []string{"piano"},
+				Clues:      []string{"It makes music"},
+				Difficulty: "easy",
+				Username:   "chaos",
+			})
+			played++
+
+			// Every model still guessing has to be settled before its round's
+			// result; one that was right earlier sits the later rounds out
+			settled := make(map[string]bool)
+			correct := make(map[string]bool)
+			dropped := make(map[string]bool)
+			for _, message := range messages {
+				switch message["type"] {
+				case "gameStart":
+					replaced, _ := message["replaced"].(map[string]interface{})
+					for name := range replaced {
+						dropped[name] = true
+					}
+				case "roundStart":
+					settled = make(map[string]bool)
+				case "gameResult", "gameFinished":
+					for name, state := range message["modelStates"].(map[string]interface{}) {
+						if !correct[name] && !settled[name] {
+							t.Errorf("a round ended without a result or error for %s", name)
+						}
+						if state.(map[string]interface{})["correct"] == true {
+							correct[name] = true
+						}
+					}
+				default:
+					if done, _ := message["done"].(bool); done {
+						settled[message["model"].(string)] = true
+					}
+				}
+			}
+
+			finished := messages[len(messages)-1]
+			states := finished["modelStates"].(map[string]interface{})
+			// A model that failed in the first round may be dropped, but never
+			// silently
+			for _, model := range models {
+				if _, ok := states[model.Name]; !ok && !dropped[model.Name] {
+					t.Errorf("%s left the game without being dropped", model.Name)
+				}
+			}
+			for name, s := range states {
+				state := s.(map[string]interface{})
+				category, _ := state["errorCategory"].(string)
+				switch {
+				case tt.category == "":
+					if category != "" || state["correct"] != true {
+						t.Errorf("%s: error %q without any faults, correct=%v", name, category, state["correct"])
+					}
+				case tt.category != "*" && category != tt.category:
+					t.Errorf("%s: error category %q, want %q", name, category, tt.category)
+				}
+			}
+
+			// Stats are saved just after gameFinished is sent
+			rm := rooms[defaultRoom]
+			var gamesPlayed int
+			for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+				rm.statsMux.Lock()
+				gamesPlayed = rm.stats.TotalGames
+				rm.statsMux.Unlock()
+				if gamesPlayed == played || time.Now().After(deadline) {
+					break
+				}
+			}
+			if gamesPlayed != played {
+				t.Errorf("stats count %d games, want %d", gamesPlayed, played)
+			}
+			if found, _ := checkData(false); found > 0 {
+				t.Errorf("data check found %d problems", found)
+			}
+		})
+	}
+}
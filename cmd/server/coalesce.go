@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTokenFlushInterval = 75 * time.Millisecond
+
+// tokenFlushInterval is how long streamed tokens are held so they go out as
+// fewer, larger guess messages, or 0 to send each token as it arrives
+func tokenFlushInterval() time.Duration {
+	ms := currentConfig().TokenFlushMs
+	switch {
+	case ms < 0:
+		return 0
+	case ms == 0:
+		return defaultTokenFlushInterval
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// tokenCoalescer buffers a model's streamed tokens and sends them as one
+// guess message per interval. A partial word at the end of the buffer waits
+// for the next flush so words aren't split across messages.
+type tokenCoalescer struct {
+	mu       sync.Mutex
+	c        *client
+	model    string
+	interval time.Duration
+	buf      strings.Builder
+	timer    *time.Timer
+}
+
+func newTokenCoalescer(c *client, model string) *tokenCoalescer {
+	return &tokenCoalescer{c: c, model: model, interval: tokenFlushInterval()}
+}
+
+func (tc *tokenCoalescer) add(token string) {
+	if tc.interval == 0 {
+		tc.c.WriteJSON(StreamMessage{Model: tc.model, Content: token, Type: "guess"})
+		return
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.buf.WriteString(token)
+	if tc.timer == nil {
+		tc.timer = time.AfterFunc(tc.interval, tc.tick)
+	}
+}
+
+// tick sends the buffered whole words and waits another interval for the rest
+func (tc *tokenCoalescer) tick() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.timer = nil
+
+	pending := tc.buf.String()
+	end := strings.LastIndexAny(pending, " \n\t") + 1
+	if end == 0 {
+		// A single long word is sent as is rather than held back
+		end = len(pending)
+	}
+	tc.send(pending[:end])
+	tc.buf.Reset()
+	tc.buf.WriteString(pending[end:])
+	if tc.buf.Len() > 0 {
+		tc.timer = time.AfterFunc(tc.interval, tc.tick)
+	}
+}
+
+// flush sends everything still buffered. It must be called once the stream
+// ends, before the model's result, so no guess arrives after it.
+func (tc *tokenCoalescer) flush() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.timer != nil {
+		tc.timer.Stop()
+		tc.timer = nil
+	}
+	tc.send(tc.buf.String())
+	tc.buf.Reset()
+}
+
+func (tc *tokenCoalescer) send(content string) {
+	if content != "" {
+		tc.c.WriteJSON(StreamMessage{Model: tc.model, Content: content, Type: "guess"})
+	}
+}
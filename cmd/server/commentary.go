@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const commentaryTimeout = 10 * time.Second
+
+// CommentaryStats tracks the commentary model separately from the competing
+// models so it never affects accuracy or leaderboards
+type CommentaryStats struct {
+	Model             string  `json:"model"`
+	Calls             int     `json:"calls"`
+	Failures          int     `json:"failures"`
+	TotalResponseTime float64 `json:"totalResponseTime"`
+	EstimatedTokens   int     `json:"estimatedTokens"` // Prompt plus output, at roughly four characters per token
+}
+
+// commentaryModel returns the configured commentary model, if any
+func commentaryModel() (ModelConfig, bool) {
+	name := currentConfig().CommentaryModel
+	if name == "" {
+		return ModelConfig{}, false
+	}
+	for _, model := range currentConfig().Models {
+		if model.Name == name {
+			return model, true
+		}
+	}
+	log.Printf("Commentary model %s is not configured\n", name)
+	return ModelConfig{}, false
+}
+
+// startCommentary asks the commentary model to react to the round that just
+// finished. The summary is built before returning so the game can move on;
+// the call itself runs in the background and is dropped if it runs long.
+func startCommentary(c *client, game *GameState) {
+	if !game.Commentary {
+		return
+	}
+	modelCfg, ok := commentaryModel()
+	if !ok {
+		return
+	}
+
+	gamesMux.Lock()
+	summary := buildRoundSummary(game)
+	gamesMux.Unlock()
+
+	round := game.CurrentRound
+	rm := game.room
+	go func() {
+		prompt := "You are a lively game-show commentator for a riddle game where a human tries to stump AI models. " +
+			"In exactly two sentences, give color commentary on this round. Do not guess the riddle's answer.\n\n" + summary
+
+		ctx, cancel := context.WithTimeout(context.Background(), commentaryTimeout)
+		defer cancel()
+
+		startTime := time.Now()
+		response, simulated, err := callProvider(ctx, c.withStreamType("commentary"), modelCfg, prompt, nil)
+		responseTime := time.Since(startTime).Seconds()
+		if err == nil && simulated {
+			simulateStream(ctx, c.withStreamType("commentary"), modelCfg.Name, response)
+		}
+		rm.recordCommentary(modelCfg.Name, len(prompt)+len(response), responseTime, err)
+
+		if err != nil {
+			log.Printf("Commentary for round %d failed: %v\n", round, err)
+			return
+		}
+		c.WriteJSON(StreamMessage{
+			Model:   modelCfg.Name,
+			Content: strings.TrimSpace(response),
+			Done:    true,
+			Type:    "commentary",
+		})
+	}()
+}
+
+// buildRoundSummary describes the round for the commentator. It must never
+// contain the answer: correct guesses are reported without their text, and any
+// other guess that mentions the answer is redacted. Callers hold gamesMux.
+func buildRoundSummary(game *GameState) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Riddle (%s): %s\n", game.Difficulty, game.Riddle)
+	fmt.Fprintf(&b, "Round %d of %d.\n", game.CurrentRound+1, len(game.Clues)+1)
+
+	names := make([]string, 0, len(game.ModelStates))
+	for name := range game.ModelStates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		state := game.ModelStates[name]
+		switch {
+		case state.Correct && state.Round == game.CurrentRound+1:
+			fmt.Fprintf(&b, "- %s solved it this round.\n", name)
+		case state.Correct:
+			fmt.Fprintf(&b, "- %s already solved it in round %d.\n", name, state.Round)
+		case state.Guess == "":
+			fmt.Fprintf(&b, "- %s had no answer and is still stumped.\n", name)
+		default:
+			fmt.Fprintf(&b, "- %s guessed %q and is still stumped.\n", name, redactAnswers(state.Guess, game.Answers))
+		}
+	}
+	return redactAnswers(b.String(), game.Answers)
+}
+
+// redactAnswers blanks out every occurrence of any accepted answer in text,
+// ignoring case. The answers are matched in one pass, longest first, so one
+// answer inside another ("pi" in "piano") can't leave the rest showing.
+func redactAnswers(text string, answers []string) string {
+	var quoted []string
+	for _, answer := range answers {
+		if answer = strings.TrimSpace(answer); answer != "" {
+			quoted = append(quoted, regexp.QuoteMeta(answer))
+		}
+	}
+	if len(quoted) == 0 {
+		return text
+	}
+	sort.SliceStable(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	re := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+	return re.ReplaceAllString(text, "[hidden]")
+}
+
+func (rm *room) recordCommentary(model string, chars int, responseTime float64, err error) {
+	rm.statsMux.Lock()
+	defer rm.statsMux.Unlock()
+
+	if rm.stats.Commentary == nil || rm.stats.Commentary.Model != model {
+		rm.stats.Commentary = &CommentaryStats{Model: model}
+	}
+	rm.stats.Commentary.Calls++
+	if err != nil {
+		rm.stats.Commentary.Failures++
+	}
+	rm.stats.Commentary.TotalResponseTime += responseTime
+	rm.stats.Commentary.EstimatedTokens += chars / 4
+	rm.saveStats()
+}
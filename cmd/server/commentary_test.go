@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestRedactAnswers checks every accepted answer is hidden whatever its case
+// or the characters in it, and nothing else is
+func TestRedactAnswers(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		answers []string
+		want    string
+	}{
+		{"exact", "is it a piano?", []string{"piano"}, "is it a [hidden]?"},
+		{"upper case", "A PIANO!", []string{"piano"}, "A [hidden]!"},
+		{"title case", "Piano, surely", []string{"piano"}, "[hidden], surely"},
+		{"answer in capitals", "a piano", []string{"PIANO"}, "a [hidden]"},
+		{"every occurrence", "piano or PIANO or pIaNo", []string{"piano"}, "[hidden] or [hidden] or [hidden]"},
+		{"inside a word", "pianos", []string{"piano"}, "[hidden]s"},
+		{"any answer", "a map or an atlas", []string{"map", "atlas"}, "a [hidden] or an [hidden]"},
+		{"not mentioned", "a guitar", []string{"piano"}, "a guitar"},
+		{"padded answer", "a piano", []string{"  piano "}, "a [hidden]"},
+		{"blank answer", "a piano", []string{"", "  "}, "a piano"},
+		{"dot", "a.b or axb", []string{"a.b"}, "[hidden] or axb"},
+		{"plus", "c++ or c", []string{"c++"}, "[hidden] or c"},
+		{"parentheses", "f(x) or fx", []string{"f(x)"}, "[hidden] or fx"},
+		{"brackets", "[x] or x", []string{"[x]"}, "[hidden] or x"},
+		{"dollar", "$5 or 5", []string{"$5"}, "[hidden] or 5"},
+		{"star and question", "a*b? or aab", []string{"a*b?"}, "[hidden] or aab"},
+		{"alternation", "a|b or a", []string{"a|b"}, "[hidden] or a"},
+		{"backslash", `C:\ or C:`, []string{`C:\`}, "[hidden] or C:"},
+		{"caret", "^_^ or _", []string{"^_^"}, "[hidden] or _"},
+		{"braces", "x{2} or xx", []string{"x{2}"}, "[hidden] or xx"},
+		{"accented case", "ÉCLAIR or éclair", []string{"éclair"}, "[hidden] or [hidden]"},
+		{"greek case", "ΣΟΦΙΑ", []string{"σοφια"}, "[hidden]"},
+		{"longer answer first", "a piano", []string{"pi", "piano"}, "a [hidden]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactAnswers(tt.text, tt.answers); got != tt.want {
+				t.Errorf("redactAnswers(%q, %q) = %q, want %q", tt.text, tt.answers, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRoundSummaryHidesAnswer builds the commentator's summary of a round in
+// which the answer turns up in a wrong guess, a correct one and the riddle
+func TestRoundSummaryHidesAnswer(t *testing.T) {
+	game := newTestGame(t, "Grand Piano")
+	game.Answers = []string{"Grand Piano", "c++"}
+	game.Riddle = "Not an upright, but a grand piano: what am I?"
+	game.Clues = []string{"It has 88 keys"}
+	game.ModelStates = map[string]ModelState{
+		"Right":   {Correct: true, Round: 1, Guess: "a grand piano"},
+		"Close":   {Guess: "GRAND PIANO bench"},
+		"Coder":   {Guess: "C++"},
+		"Stumped": {},
+	}
+
+	summary := buildRoundSummary(game)
+	for _, answer := range []string{"grand piano", "c++"} {
+		if strings.Contains(strings.ToLower(summary), answer) {
+			t.Errorf("summary mentions %q:\n%s", answer, summary)
+		}
+	}
+	for _, want := range []string{"Right solved it this round", `Close guessed "[hidden] bench"`, "Stumped had no answer"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary is missing %q:\n%s", want, summary)
+		}
+	}
+}
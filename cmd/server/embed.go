@@ -0,0 +1,221 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//go:embed templates/embed_game.html
+var embedTemplates embed.FS
+
+var embedGameTemplate = template.Must(template.ParseFS(embedTemplates, "templates/embed_game.html"))
+
+const (
+	embedTeaserLength = 140
+	embedWidth        = 480
+	embedHeight       = 200
+	embedCacheSeconds = 300
+)
+
+// embedGame is everything the widget shows. It is built from the leaderboard
+// entry without final guesses so an embed can never reveal the answer.
+type embedGame struct {
+	OEmbedURL string `json:"-"`
+
+	ID         string   `json:"id"`
+	Available  bool     `json:"available"`
+	Teaser     string   `json:"teaser,omitempty"`
+	Username   string   `json:"username,omitempty"`
+	Difficulty string   `json:"difficulty,omitempty"`
+	Score      int      `json:"score,omitempty"`
+	PlayerWon  bool     `json:"playerWon,omitempty"`
+	Failed     []string `json:"failedModels,omitempty"`
+}
+
+func newEmbedGame(id string, entry *LeaderboardEntry) embedGame {
+	game := embedGame{ID: id}
+	if entry == nil || entry.Hidden {
+		return game
+	}
+
+	game.Available = true
+	game.Teaser = teaser(entry.Riddle, embedTeaserLength)
+	game.Username = entry.Username
+	game.Difficulty = entry.Difficulty
+	game.Score = entry.Score
+	game.PlayerWon = entry.PlayerWon
+	for _, model := range entry.Models {
+		if !model.Correct {
+			game.Failed = append(game.Failed, model.Name)
+		}
+	}
+	return game
+}
+
+// teaser shortens text to at most n runes, cutting at a word boundary
+func teaser(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	cut := string([]rune(text)[:n])
+	if space := strings.LastIndex(cut, " "); space > n/2 {
+		cut = cut[:space]
+	}
+	return cut + "…"
+}
+
+func (rm *room) findLeaderboardEntry(id string) *LeaderboardEntry {
+	for _, entry := range rm.allLeaderboardEntries() {
+		if entry.ID == id {
+			return &entry
+		}
+	}
+	return nil
+}
+
+// handleEmbedGame serves GET /embed/games/{id}, an iframe-able summary of one
+// leaderboard entry. ?format=json returns the same data as JSON. Hidden and
+// unknown entries render a neutral "entry unavailable" rather than a 404 so
+// embeds already posted elsewhere degrade quietly.
+func handleEmbedGame(w http.ResponseWriter, r *http.Request, rm *room) {
+	id := strings.TrimPrefix(r.URL.Path, "/embed/games/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	game := newEmbedGame(id, rm.findLeaderboardEntry(id))
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", embedCacheSeconds))
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(game)
+		return
+	}
+
+	sharedPath := r.URL.Path
+	if rm.name != defaultRoom {
+		sharedPath = "/rooms/" + rm.name + sharedPath
+	}
+	base := requestBaseURL(r)
+	game.OEmbedURL = base + "/oembed?url=" + url.QueryEscape(base+sharedPath)
+
+	// Embeds are meant to be framed anywhere, so don't inherit a frame policy
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	if err := embedGameTemplate.Execute(w, game); err != nil {
+		http.Error(w, "could not render embed", http.StatusInternalServerError)
+	}
+}
+
+// handleOEmbed serves GET /oembed?url=..., the oEmbed discovery endpoint for
+// links to /embed/games/{id} and /rooms/{room}/embed/games/{id}
+func handleOEmbed(w http.ResponseWriter, r *http.Request) {
+	target, err := url.Parse(r.URL.Query().Get("url"))
+	if err != nil {
+		http.Error(w, "invalid url", http.StatusBadRequest)
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" && format != "json" {
+		http.Error(w, "only json is supported", http.StatusNotImplemented)
+		return
+	}
+
+	path := target.Path
+	rm := rooms[defaultRoom]
+	if rest, ok := strings.CutPrefix(path, "/rooms/"); ok {
+		name, sub, _ := strings.Cut(rest, "/")
+		if rm, ok = rooms[name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		path = "/" + sub
+	}
+	id, ok := strings.CutPrefix(path, "/embed/games/")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	title := "Turing Roulette: entry unavailable"
+	if game := newEmbedGame(id, rm.findLeaderboardEntry(id)); game.Available {
+		title = fmt.Sprintf("Turing Roulette: %s riddle by %s", game.Difficulty, game.Username)
+	}
+
+	// The iframe always points back at this server, whatever host the url named
+	src := requestBaseURL(r) + target.Path
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", embedCacheSeconds))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"version":       "1.0",
+		"type":          "rich",
+		"provider_name": "Turing Roulette",
+		"title":         title,
+		"width":         embedWidth,
+		"height":        embedHeight,
+		"html": fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" frameborder="0"></iframe>`,
+			template.HTMLEscapeString(src), embedWidth, embedHeight),
+	})
+}
+
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// ipRateLimiter allows each client IP a fixed number of requests per window
+type ipRateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu      sync.Mutex
+	started time.Time
+	counts  map[string]int
+}
+
+func newIPRateLimiter(limit int, window time.Duration) *ipRateLimiter {
+	return &ipRateLimiter{limit: limit, window: window, counts: make(map[string]int)}
+}
+
+func (l *ipRateLimiter) allow(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if time.Since(l.started) > l.window {
+		l.started = time.Now()
+		l.counts = make(map[string]int)
+	}
+	l.counts[ip]++
+	return l.counts[ip] <= l.limit
+}
+
+var embedLimiter = newIPRateLimiter(60, time.Minute)
+
+// withRateLimit rejects clients that exceed the limiter with 429
+func withRateLimit(limiter *ipRateLimiter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if !limiter.allow(ip) {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(limiter.window.Seconds())))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
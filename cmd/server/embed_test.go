@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// TestEmbedEscapesPlayerText plays a game whose riddle, clues, username and
+// difficulty are all attempts at markup, and checks none of it reaches the
+// game's embed as markup, and the clues and answer not at all
+func TestEmbedEscapesPlayerText(t *testing.T) {
+	useConfig(t, Config{Models: []ModelConfig{
+		{Name: "Right", Provider: "mock", Model: "always-correct"},
+		{Name: "Wrong", Provider: "mock", Model: "always-wrong"},
+	}})
+	playGame(t, serveGames(t), "", RiddleSubmission{
+		Riddle:     `What am I? <script>alert("riddle")</script><img src=x onerror=alert(1)>`,
+		Answers:    []string{"piano"},
+		Clues:      []string{`<script>alert("clue")</script>`},
+		Difficulty: `easy<iframe src=//evil.test>`,
+		Username:   `"><script>alert('user')</script>`,
+	})
+	rm := rooms[defaultRoom]
+	waitForRecorded(t, rm, 1)
+	rm.leaderboardMux.Lock()
+	id := rm.leaderboard[0].ID
+	rm.leaderboardMux.Unlock()
+
+	w := httptest.NewRecorder()
+	handleEmbedGame(w, httptest.NewRequest(http.MethodGet, "/embed/games/"+id, nil), rm)
+	page := w.Body.String()
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, page)
+	}
+
+	for _, markup := range []string{"<script", "<img", "<iframe", `"><`, "piano", "clue"} {
+		if strings.Contains(page, markup) {
+			t.Errorf("embed contains %q:\n%s", markup, page)
+		}
+	}
+	for _, escaped := range []string{
+		"&lt;script&gt;alert(&#34;riddle&#34;)&lt;/script&gt;&lt;img src=x onerror=alert(1)&gt;",
+		"&#34;&gt;&lt;script&gt;alert(&#39;user&#39;)&lt;/script&gt;",
+		"easy&lt;iframe src=//evil.test&gt;",
+	} {
+		if !strings.Contains(page, escaped) {
+			t.Errorf("embed is missing the escaped text %q:\n%s", escaped, page)
+		}
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "default-src 'none'") {
+		t.Errorf("Content-Security-Policy %q lets scripts run", csp)
+	}
+}
+
+// TestOEmbedEscapesURL checks a shared link can't add markup to the iframe
+// the oEmbed response gives chat apps
+func TestOEmbedEscapesURL(t *testing.T) {
+	useConfig(t, Config{Models: []ModelConfig{{Name: "Mock", Provider: "mock", Model: "always-correct"}}})
+	rooms[defaultRoom].leaderboard = []LeaderboardEntry{{ID: "game", Riddle: "A riddle", Difficulty: "easy", Username: `<script>x</script>`}}
+
+	target := `http://example.com/embed/games/game"><img src=x onerror=alert(1)>`
+	w := httptest.NewRecorder()
+	handleOEmbed(w, httptest.NewRequest(http.MethodGet, "/oembed?url="+url.QueryEscape(target), nil))
+	var oembed map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&oembed); err != nil {
+		t.Fatal(err)
+	}
+	if html, _ := oembed["html"].(string); strings.Count(html, "<") != 2 || strings.Count(html, `"`) != 8 {
+		t.Errorf("oEmbed html breaks out of the iframe: %s", html)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxCandidateWords is the longest reply checked as it is, and the longest
+// phrase taken from a longer one as its answer
+const maxCandidateWords = 4
+
+// Phrases that introduce the answer in a wordier reply, strongest first. The
+// last match of the first pattern that matches at all is taken, so "it's not
+// a cat, it's a dog" gives "a dog".
+var answerMarkerPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(?:the answer is|answer is|answer would be|answer:)\s*:?\s*`),
+	regexp.MustCompile(`(?i)\b(?:i think it's|i believe it's|it must be|it's|it is)\s+`),
+}
+
+// sentenceEnd cuts a phrase off where its sentence or clause ends
+var sentenceEnd = regexp.MustCompile(`[.!?;\n]|,\s|\s(?:because|since|as|which|and it|that)\s`)
+
+// extractAnswer picks the answer out of a reply that says more than the
+// answer, such as "Based on the clues, the answer is a candle. It melts as it
+// burns." It returns the reply unchanged if it is already short, or if no
+// answer can be picked out of it.
+func extractAnswer(reply string) string {
+	if len(strings.Fields(reply)) <= maxCandidateWords {
+		return reply
+	}
+
+	for _, pattern := range answerMarkerPatterns {
+		markers := pattern.FindAllStringIndex(reply, -1)
+		if len(markers) == 0 {
+			continue
+		}
+		phrase := reply[markers[len(markers)-1][1]:]
+		if end := sentenceEnd.FindStringIndex(phrase); end != nil {
+			phrase = phrase[:end[0]]
+		}
+		phrase = strings.Trim(phrase, guessTrimChars)
+		if words := len(strings.Fields(phrase)); words > 0 && words <= maxCandidateWords {
+			return phrase
+		}
+	}
+
+	// Failing a marker, a short last line or sentence is usually the answer
+	// after the model's reasoning
+	pieces := strings.FieldsFunc(reply, func(r rune) bool {
+		return r == '\n' || r == '.' || r == '!' || r == '?'
+	})
+	for i := len(pieces) - 1; i >= 0; i-- {
+		last := strings.Trim(pieces[i], guessTrimChars)
+		if last == "" {
+			continue
+		}
+		if len(strings.Fields(last)) <= maxCandidateWords {
+			return last
+		}
+		break
+	}
+	return reply
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// replaceFailedModels swaps each model whose call errored this round for the
+// first configured fallback that is enabled and not yet part of the game, and
+// tells the client about the new lineup. A failed model with no
+// fallback left is dropped, so it doesn't count towards the game's models,
+// unless every model failed. Only meant for round 0, before any model has a
+// history. Returns the substitutes, which still have to play the round.
+func replaceFailedModels(c *client, game *GameState) []ModelConfig {
+	gamesMux.Lock()
+	defer gamesMux.Unlock()
+
+	var failed []string
+	for _, model := range game.SelectedModels {
+		if game.ModelStates[model.Name].Outcome == providers.OutcomeErrored {
+			failed = append(failed, model.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	if game.triedModels == nil {
+		game.triedModels = make(map[string]bool)
+	}
+	for _, model := range game.SelectedModels {
+		game.triedModels[model.Name] = true
+	}
+
+	var substitutes []ModelConfig
+	var unreplaced []string
+	replaced := make(map[string]string)
+	for _, name := range failed {
+		fallback, ok := pickFallback(game.triedModels)
+		if !ok {
+			unreplaced = append(unreplaced, name)
+			continue
+		}
+
+		log.Printf("Replacing %s with fallback %s\n", name, fallback.Name)
+		game.triedModels[fallback.Name] = true
+		for i, model := range game.SelectedModels {
+			if model.Name == name {
+				game.SelectedModels[i] = fallback
+			}
+		}
+		delete(game.ModelStates, name)
+		game.ModelStates[fallback.Name] = ModelState{}
+		replaced[name] = fallback.Name
+		substitutes = append(substitutes, fallback)
+	}
+
+	// With every model failed and nothing to replace them, the lineup stays as
+	// it is rather than leaving a game with no models
+	if len(unreplaced) < len(game.SelectedModels) {
+		for _, name := range unreplaced {
+			log.Printf("No fallback for %s, dropping it from the game\n", name)
+			game.SelectedModels = withoutModel(game.SelectedModels, name)
+			delete(game.ModelStates, name)
+			replaced[name] = ""
+		}
+	}
+
+	if len(replaced) > 0 {
+		c.WriteJSON(map[string]interface{}{
+			"type":           "gameStart",
+			"selectedModels": redactedModels(game.SelectedModels),
+			"replaced":       replaced, // Failed model name to its substitute, "" when dropped
+		})
+	}
+	return substitutes
+}
+
+// pickFallback returns a random enabled instance of the first configured
+// fallback not in tried
+func pickFallback(tried map[string]bool) (ModelConfig, bool) {
+	config := currentConfig()
+	for _, name := range config.Fallbacks {
+		if tried[name] {
+			continue
+		}
+		var instances []ModelConfig
+		for _, model := range config.Models {
+			if model.Name == name && model.IsEnabled() {
+				instances = append(instances, model)
+			}
+		}
+		if len(instances) > 0 {
+			return instances[rand.Intn(len(instances))], true
+		}
+	}
+	return ModelConfig{}, false
+}
+
+func withoutModel(models []ModelConfig, name string) []ModelConfig {
+	var kept []ModelConfig
+	for _, model := range models {
+		if model.Name != name {
+			kept = append(kept, model)
+		}
+	}
+	return kept
+}
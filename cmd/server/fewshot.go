@@ -0,0 +1,70 @@
+package main
+
+import "unicode/utf8"
+
+const defaultFewShotTokens = 300
+
+// fewShotTokenBudget is the most tokens the examples before a riddle may take
+func fewShotTokenBudget() int {
+	if tokens := currentConfig().FewShotTokens; tokens > 0 {
+		return tokens
+	}
+	return defaultFewShotTokens
+}
+
+// estimateTokens is a rough token count for text, at about four characters a
+// token, for budgets that don't need a real tokenizer
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// fewShotExamples are the solved riddles shown to a model before the game's:
+// its own fewShot if it has one, else the global list. An example that gives
+// away the game's answer is skipped, and examples stop once the next would
+// go over the token budget.
+func fewShotExamples(game *GameState, modelCfg ModelConfig) []FewShotExample {
+	examples := currentConfig().FewShot
+	if modelCfg.FewShot != nil {
+		examples = modelCfg.FewShot
+	}
+	if len(examples) == 0 {
+		return nil
+	}
+
+	checker, riddle := answerChecker(game.Difficulty), game.answerRiddle()
+	budget := fewShotTokenBudget()
+	var chosen []FewShotExample
+	for _, example := range examples {
+		if checker.Check(example.Answer, riddle).Correct || checker.Check(example.Riddle, riddle).Correct {
+			continue
+		}
+		tokens := estimateTokens(example.Riddle) + estimateTokens(example.Answer)
+		if tokens > budget {
+			break
+		}
+		budget -= tokens
+		chosen = append(chosen, example)
+	}
+	return chosen
+}
+
+// fewShotTurns are the examples as earlier turns of a conversation, each
+// riddle asked the way the game's is and answered
+func fewShotTurns(game *GameState, modelCfg ModelConfig) []ChatMessage {
+	var turns []ChatMessage
+	for _, example := range fewShotExamples(game, modelCfg) {
+		data := PromptData{
+			Riddle:       example.Riddle,
+			Difficulty:   game.Difficulty,
+			MaxWords:     maxAnswerWords,
+			Round:        1,
+			Provider:     modelCfg.Provider,
+			Language:     game.Language,
+			LanguageName: languageName(game),
+		}
+		turns = append(turns,
+			ChatMessage{Role: "user", Content: renderPromptData(chatTemplate, data, modelCfg)},
+			ChatMessage{Role: "assistant", Content: example.Answer})
+	}
+	return turns
+}
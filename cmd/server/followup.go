@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+const (
+	defaultFollowUpWordLimit = 6
+	followUpInstruction      = "Reply with only the answer, one or two words."
+)
+
+// followUpWordLimit is the longest reply taken as an answer as is, or 0 if
+// long replies are never followed up
+func followUpWordLimit() int {
+	limit := currentConfig().FollowUpWordLimit
+	switch {
+	case limit < 0:
+		return 0
+	case limit == 0:
+		return defaultFollowUpWordLimit
+	}
+	return limit
+}
+
+// needsFollowUp reports whether a reply rambles on too long to be checked as
+// an answer
+func needsFollowUp(reply string) bool {
+	limit := followUpWordLimit()
+	return limit > 0 && len(strings.Fields(reply)) > limit
+}
+
+// askForAnswerOnly makes the one follow-up call a round allows, asking a model
+// whose reply was too long for just the answer. It returns the follow-up's
+// text, the conversation it was asked in for chat providers, and how long the
+// provider took.
+func askForAnswerOnly(ctx context.Context, c *client, modelCfg ModelConfig, timeouts TimeoutConfig, prompt string, messages []ChatMessage, reply, answer string, tokensUsed *TokensUsed) (string, []ChatMessage, float64, error) {
+	release, err := acquireCallSlot(ctx, c, modelCfg)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	// Tells the client the guess streamed so far is being replaced
+	c.WriteJSON(StreamMessage{Model: modelCfg.Name, Content: "followUp", Type: "status"})
+
+	if len(messages) > 0 {
+		messages = append(append([]ChatMessage{}, messages...),
+			ChatMessage{Role: "assistant", Content: reply},
+			ChatMessage{Role: "user", Content: followUpInstruction})
+	} else {
+		prompt = fmt.Sprintf("%s\n\nYou replied: %s\n\n%s", prompt, reply, followUpInstruction)
+	}
+
+	start := time.Now()
+	callCtx, stopDeadlines := withTieredDeadlines(ctx, modelCfg, timeouts)
+	response, simulated, err := callProvider(withAnswer(withTokenCount(callCtx, tokensUsed), answer), c, modelCfg, prompt, messages)
+	stopDeadlines()
+	release()
+	elapsed := time.Since(start).Seconds()
+
+	if errors.Is(err, providers.ErrResponseTruncated) {
+		err = nil
+	}
+	if err != nil {
+		return "", nil, elapsed, err
+	}
+	if simulated {
+		simulateStream(ctx, c, modelCfg.Name, response)
+	}
+	return response, messages, elapsed, nil
+}
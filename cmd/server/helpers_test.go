@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// useConfig starts the server over from config in a fresh data directory,
+// loading it and the rooms the way main does at startup
+func useConfig(t *testing.T, config Config) {
+	t.Helper()
+	dataDir = t.TempDir() + "/"
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dataDir+"config.json", data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loadConfig()
+	rooms = make(map[string]*room)
+	loadRooms()
+}
+
+// newTestGame is a one-round game of answer against models, set up the way
+// a submitted riddle is
+func newTestGame(t *testing.T, answer string, models ...ModelConfig) *GameState {
+	t.Helper()
+	lang, err := parseLanguage("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make(map[string]ModelState)
+	for _, model := range models {
+		states[model.Name] = ModelState{}
+	}
+	return &GameState{
+		Riddle:         "What has keys but can't open locks?",
+		Answer:         answer,
+		Answers:        []string{answer},
+		Language:       lang.String(),
+		Difficulty:     "easy",
+		ModelStates:    states,
+		StartTime:      time.Now(),
+		Username:       "tester",
+		SelectedModels: models,
+		Room:           defaultRoom,
+		room:           rooms[defaultRoom],
+		language:       lang,
+	}
+}
+
+// serveGames serves the default room's game socket and returns its ws:// URL.
+// Games finish recording their results before the test ends.
+func serveGames(t *testing.T) string {
+	t.Helper()
+	var handlers sync.WaitGroup
+	play := withOriginCapabilities(withRoom(handleWebSocket))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.Add(1)
+		defer handlers.Done()
+		play(w, r)
+	}))
+	t.Cleanup(func() {
+		server.Close()
+		handlers.Wait()
+	})
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+// dial opens the socket at url as a page from origin would
+func dial(t *testing.T, url, origin string) *websocket.Conn {
+	t.Helper()
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("dialing %s from %q: %v", url, origin, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// playGame submits a riddle to the game socket at url from origin and returns
+// every message sent back, up to and including gameFinished
+func playGame(t *testing.T, url, origin string, submission RiddleSubmission) []map[string]interface{} {
+	t.Helper()
+	conn := dial(t, url, origin)
+	if err := conn.WriteJSON(submission); err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []map[string]interface{}
+	conn.SetReadDeadline(time.Now().Add(time.Minute))
+	for {
+		var message map[string]interface{}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("game never finished: %v", err)
+		}
+		messages = append(messages, message)
+		switch message["type"] {
+		case "gameFinished":
+			return messages
+		case "error":
+			if message["model"] == nil {
+				t.Fatalf("game rejected: %v", message["message"])
+			}
+		}
+	}
+}
+
+// waitForRecorded blocks until rm has recorded games finished games. A game
+// sends gameFinished before saving its stats and leaderboard entry.
+func waitForRecorded(t *testing.T, rm *room, games int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		rm.leaderboardMux.Lock()
+		recorded := rm.gamesRecorded
+		rm.leaderboardMux.Unlock()
+		if recorded >= games {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d games recorded, want %d", recorded, games)
+		}
+	}
+}
+
+// fakeClock stands in for time.Now and time.After, and only moves when the
+// test advances it
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeTimer
+	waiting chan struct{} // Signalled whenever a timer is started
+}
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+// newFakeClock starts at the real time, so deadlines taken from real
+// contexts still make sense against it
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Now(), waiting: make(chan struct{}, 100)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeTimer{at: c.now.Add(d), ch: ch})
+	c.waiting <- struct{}{}
+	return ch
+}
+
+// Advance moves the clock on by d, firing every timer that comes due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, timer := range c.waiters {
+		if timer.at.After(c.now) {
+			pending = append(pending, timer)
+		} else {
+			timer.ch <- c.now
+		}
+	}
+	c.waiters = pending
+}
+
+// Waiting blocks until someone starts a timer and returns how long it is
+// set for, failing the test if nobody does
+func (c *fakeClock) Waiting(t *testing.T) time.Duration {
+	t.Helper()
+	select {
+	case <-c.waiting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("nothing started waiting on the clock")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.waiters[len(c.waiters)-1].at.Sub(c.now)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+)
+
+// dataIssue is one broken invariant found in the data directory
+type dataIssue struct {
+	What     string
+	Repaired bool
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+// checkData validates every room's stats, leaderboard and archive, logging
+// each problem. With repair set, derivable values are recomputed and saved.
+// It returns how many problems could not be repaired.
+func checkData(repair bool) (found int, unrepaired int) {
+	names := make([]string, 0, len(rooms))
+	for name := range rooms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		issues := rooms[name].checkData(repair)
+		for _, issue := range issues {
+			if issue.Repaired {
+				log.Printf("Data check [%s]: %s (repaired)\n", name, issue.What)
+			} else {
+				log.Printf("Data check [%s]: %s\n", name, issue.What)
+				unrepaired++
+			}
+		}
+		found += len(issues)
+	}
+	return found, unrepaired
+}
+
+func (rm *room) checkData(repair bool) []dataIssue {
+	rm.leaderboardMux.Lock()
+	defer rm.leaderboardMux.Unlock()
+	rm.archiveMux.Lock()
+	defer rm.archiveMux.Unlock()
+	rm.statsMux.Lock()
+	defer rm.statsMux.Unlock()
+
+	var issues []dataIssue
+	seen := make(map[string]bool)
+	checkEntries := func(file string, entries []LeaderboardEntry) bool {
+		changed := false
+		for i := range entries {
+			entryIssues := entries[i].checkInvariants(repair)
+			if seen[entries[i].ID] && entries[i].ID != "" {
+				entryIssues = append(entryIssues, dataIssue{What: "duplicate id", Repaired: repair})
+				if repair {
+					entries[i].ID = newID()
+				}
+			}
+			seen[entries[i].ID] = true
+
+			for _, issue := range entryIssues {
+				issue.What = file + " entry " + entries[i].ID + ": " + issue.What
+				issues = append(issues, issue)
+				changed = changed || issue.Repaired
+			}
+		}
+		return changed
+	}
+
+	if checkEntries("leaderboard.json", rm.leaderboard) {
+		rm.saveLeaderboard()
+	}
+	archived := rm.readArchiveLocked()
+	if checkEntries("leaderboard_archive.jsonl", archived) {
+		if err := rm.writeArchiveLocked(archived); err != nil {
+			log.Printf("Error rewriting archive for room %s: %v\n", rm.name, err)
+		}
+	}
+
+	statsIssues := rm.checkStats(repair, append(append([]LeaderboardEntry{}, rm.leaderboard...), archived...))
+	for _, issue := range statsIssues {
+		issue.What = "stats.json: " + issue.What
+		issues = append(issues, issue)
+	}
+	return issues
+}
+
+// checkStats validates the room's stats. When the game totals are broken they
+// are rebuilt from the leaderboard and archive, but only if those still hold
+// every game played, i.e. nothing has aged out of the archive yet.
+func (rm *room) checkStats(repair bool, records []LeaderboardEntry) []dataIssue {
+	var rebuilt []dataIssue
+	if repair && hasUnrepaired(rm.stats.checkInvariants(false)) {
+		if len(records) >= rm.stats.TotalGames && len(records) >= rm.stats.Wins+rm.stats.Losses {
+			rm.stats.rebuildTotals(records)
+			rebuilt = []dataIssue{{What: fmt.Sprintf("game totals rebuilt from %d game records", len(records)), Repaired: true}}
+		} else {
+			log.Printf("Room %s: game records cover %d games, too few to rebuild stats totals\n", rm.name, len(records))
+		}
+	}
+
+	issues := append(rebuilt, rm.stats.checkInvariants(repair)...)
+	if repair {
+		rm.saveStats()
+	}
+	return issues
+}
+
+// rebuildTotals recomputes the game counts and durations from game records
+func (s *Stats) rebuildTotals(records []LeaderboardEntry) {
+	s.TotalGames = len(records)
+	s.Wins, s.Losses = 0, 0
+	s.TotalDuration = 0
+	s.ByDifficulty = make(map[string]int)
+	for _, record := range records {
+		if record.PlayerWon {
+			s.Wins++
+		} else {
+			s.Losses++
+		}
+		s.ByDifficulty[record.Difficulty]++
+		s.TotalDuration += record.Duration
+	}
+}
+
+func hasUnrepaired(issues []dataIssue) bool {
+	for _, issue := range issues {
+		if !issue.Repaired {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"testing"
+	"time"
+)
+
+// TestLegacyLatencyStats checks model stats from before queue wait and first
+// token latency were measured keep those averages unknown, rather than having
+// them repaired to 0 or diluted by the unmeasured games
+func TestLegacyLatencyStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     string
+		issues     int
+		unrepaired int
+		queueWait  *float64 // After repair
+	}{
+		{"never measured", `{"gamesPlayed": 10, "timesCorrect": 5, "accuracy": 50}`, 0, 0, nil},
+		{"stored as 0", `{"gamesPlayed": 10, "timesCorrect": 5, "accuracy": 50, "avgQueueWait": 0, "avgFirstTokenLatency": 0}`, 2, 0, nil},
+		{"measured since", `{"gamesPlayed": 10, "timesCorrect": 5, "accuracy": 50, "latencyGames": 4, "totalQueueWait": 2, "avgQueueWait": 0.5, "totalFirstTokenLatency": 4, "avgFirstTokenLatency": 1}`, 0, 0, ptr(0.5)},
+		{"average over every game", `{"gamesPlayed": 10, "timesCorrect": 5, "accuracy": 50, "latencyGames": 4, "totalQueueWait": 2, "avgQueueWait": 0.2, "totalFirstTokenLatency": 4, "avgFirstTokenLatency": 1}`, 1, 0, ptr(0.5)},
+		{"measured but unknown", `{"gamesPlayed": 10, "timesCorrect": 5, "accuracy": 50, "latencyGames": 4, "totalQueueWait": 2, "totalFirstTokenLatency": 4, "avgFirstTokenLatency": 1}`, 1, 0, ptr(0.5)},
+		{"totals without games", `{"gamesPlayed": 10, "timesCorrect": 5, "accuracy": 50, "totalQueueWait": 2}`, 1, 1, nil},
+		{"more measured than played", `{"gamesPlayed": 3, "timesCorrect": 0, "latencyGames": 4, "totalQueueWait": 2}`, 1, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stats ModelStats
+			if err := json.Unmarshal([]byte(tt.stored), &stats); err != nil {
+				t.Fatal(err)
+			}
+			issues := stats.checkInvariants(true)
+			if len(issues) != tt.issues {
+				t.Errorf("%d issues, want %d: %v", len(issues), tt.issues, issues)
+			}
+			unrepaired := 0
+			for _, issue := range issues {
+				if !issue.Repaired {
+					unrepaired++
+				}
+			}
+			if unrepaired != tt.unrepaired {
+				t.Errorf("%d issues left unrepaired, want %d", unrepaired, tt.unrepaired)
+			}
+			if tt.unrepaired > 0 {
+				return
+			}
+			switch {
+			case tt.queueWait == nil && stats.AvgQueueWait != nil:
+				t.Errorf("avgQueueWait repaired to %v, want unknown", *stats.AvgQueueWait)
+			case tt.queueWait != nil && (stats.AvgQueueWait == nil || !closeTo(*stats.AvgQueueWait, *tt.queueWait)):
+				t.Errorf("avgQueueWait %v after repair, want %v", stats.AvgQueueWait, *tt.queueWait)
+			}
+			if again := stats.checkInvariants(false); len(again) > 0 {
+				t.Errorf("still inconsistent after repair: %v", again)
+			}
+		})
+	}
+}
+
+// TestLatencyMeasuredAfterLegacyGames plays a game for a model with legacy
+// stats and checks its averages cover only the measured game
+func TestLatencyMeasuredAfterLegacyGames(t *testing.T) {
+	model := ModelConfig{Name: "Mock", Provider: "mock", Model: "always-correct"}
+	useConfig(t, Config{Models: []ModelConfig{model}})
+	model = currentConfig().Models[0]
+	rm := rooms[defaultRoom]
+	rm.stats.ByModel = map[string]ModelStats{
+		model.StatsKey(): {Name: model.Name, Provider: model.Provider, InstanceLabel: model.Instance(), GamesPlayed: 9, TimesCorrect: 9, Accuracy: 100},
+	}
+
+	game := newTestGame(t, "piano", model)
+	game.ModelStates[model.Name] = ModelState{Correct: true, QueueWait: 0.3, FirstTokenLatency: 0.8}
+	rm.updateModelStats(game)
+
+	stats := rm.stats.ByModel[model.StatsKey()]
+	if stats.GamesPlayed != 10 || stats.LatencyGames != 1 {
+		t.Errorf("%d games played and %d measured, want 10 and 1", stats.GamesPlayed, stats.LatencyGames)
+	}
+	if stats.AvgQueueWait == nil || !closeTo(*stats.AvgQueueWait, 0.3) {
+		t.Errorf("avgQueueWait %v, want 0.3 from the one measured game", stats.AvgQueueWait)
+	}
+	if stats.AvgFirstTokenLatency == nil || !closeTo(*stats.AvgFirstTokenLatency, 0.8) {
+		t.Errorf("avgFirstTokenLatency %v, want 0.8 from the one measured game", stats.AvgFirstTokenLatency)
+	}
+}
+
+func ptr(v float64) *float64 { return &v }
+
+// playRecordedGames records games the way a finished game is, some of them
+// trimmed into the archive, and returns them
+func playRecordedGames(t *testing.T, rm *room, model ModelConfig) []GameResult {
+	t.Helper()
+	var results []GameResult
+	for i, difficulty := range []string{"easy", "medium", "hard", "easy", "medium"} {
+		game := newTestGame(t, "piano", model)
+		game.Difficulty = difficulty
+		won := i%2 == 0
+		game.ModelStates[model.Name] = ModelState{Correct: !won, GuessCount: 1, GuessesToCorrect: 1, ResponseTime: 0.5}
+		result := GameResult{
+			PlayerWins:   won,
+			TotalModels:  1,
+			Difficulty:   difficulty,
+			Duration:     float64(10 * (i + 1)),
+			RoundsPlayed: 1,
+			Timestamp:    time.Now(),
+		}
+		if !won {
+			result.CorrectCount = 1
+		}
+		rm.updateStats(result)
+		rm.updateModelStats(game)
+		rm.addToLeaderboard(game, result)
+		results = append(results, result)
+	}
+
+	rm.leaderboardMux.Lock()
+	rm.archiveEntries(rm.leaderboard[3:])
+	rm.leaderboard = rm.leaderboard[:3]
+	rm.leaderboardMux.Unlock()
+	return results
+}
+
+// TestRepairRebuildsStats corrupts stats and leaderboard entries the way an
+// unclean shutdown can, repairs them, and checks the totals match the game
+// records again, in memory and on disk
+func TestRepairRebuildsStats(t *testing.T) {
+	model := ModelConfig{Name: "Mock", Provider: "mock", Model: "always-correct"}
+	useConfig(t, Config{Models: []ModelConfig{model}})
+	model = currentConfig().Models[0]
+	rm := rooms[defaultRoom]
+	playRecordedGames(t, rm, model)
+	if found, _ := checkData(false); found > 0 {
+		t.Fatalf("%d problems before anything was corrupted", found)
+	}
+
+	rm.statsMux.Lock()
+	want := rm.stats
+	want.ByDifficulty = maps.Clone(rm.stats.ByDifficulty)
+	rm.stats.TotalGames, rm.stats.Wins, rm.stats.Losses = 4, 1, 1
+	rm.stats.WinRate, rm.stats.AverageDuration = 90, 1
+	rm.stats.ByDifficulty = map[string]int{"easy": 4}
+	modelStats := rm.stats.ByModel[model.StatsKey()]
+	wantModel := modelStats
+	modelStats.Accuracy, modelStats.AvgResponseTime = 250, 7
+	rm.stats.ByModel[model.StatsKey()] = modelStats
+	rm.statsMux.Unlock()
+
+	rm.leaderboardMux.Lock()
+	wantScore := rm.leaderboard[0].Score
+	rm.leaderboard[0].Score += 500
+	rm.leaderboard[1].CorrectCount = 1 - rm.leaderboard[1].CorrectCount
+	rm.leaderboardMux.Unlock()
+
+	found, unrepaired := checkData(true)
+	if found == 0 || unrepaired > 0 {
+		t.Fatalf("repair found %d problems and left %d, want some found and all repaired", found, unrepaired)
+	}
+	if found, _ := checkData(false); found > 0 {
+		t.Errorf("%d problems left after repair", found)
+	}
+
+	check := func(where string, stats Stats) {
+		t.Helper()
+		if stats.TotalGames != want.TotalGames || stats.Wins != want.Wins || stats.Losses != want.Losses ||
+			!closeTo(stats.WinRate, want.WinRate) || !closeTo(stats.TotalDuration, want.TotalDuration) || !closeTo(stats.AverageDuration, want.AverageDuration) {
+			t.Errorf("%s: %d games, %d wins, %d losses, win rate %.2f, duration %.2f (%.2f average); want %d, %d, %d, %.2f, %.2f (%.2f)", where,
+				stats.TotalGames, stats.Wins, stats.Losses, stats.WinRate, stats.TotalDuration, stats.AverageDuration,
+				want.TotalGames, want.Wins, want.Losses, want.WinRate, want.TotalDuration, want.AverageDuration)
+		}
+		if !maps.Equal(stats.ByDifficulty, want.ByDifficulty) {
+			t.Errorf("%s: by difficulty %v, want %v", where, stats.ByDifficulty, want.ByDifficulty)
+		}
+		if got := stats.ByModel[model.StatsKey()]; !closeTo(got.Accuracy, wantModel.Accuracy) || !closeTo(got.AvgResponseTime, wantModel.AvgResponseTime) {
+			t.Errorf("%s: model accuracy %.2f and response time %.2f, want %.2f and %.2f", where, got.Accuracy, got.AvgResponseTime, wantModel.Accuracy, wantModel.AvgResponseTime)
+		}
+	}
+	check("repaired", rm.stats)
+	rm.loadStats()
+	check("saved", rm.stats)
+
+	rm.loadLeaderboard()
+	if rm.leaderboard[0].Score != wantScore {
+		t.Errorf("saved score %d, want %d", rm.leaderboard[0].Score, wantScore)
+	}
+	correct := 0
+	for _, m := range rm.leaderboard[1].Models {
+		if m.Correct {
+			correct++
+		}
+	}
+	if rm.leaderboard[1].CorrectCount != correct {
+		t.Errorf("saved correctCount %d, but %d models are marked correct", rm.leaderboard[1].CorrectCount, correct)
+	}
+}
+
+// TestRepairNeedsEveryGame checks game totals aren't rebuilt from records
+// that no longer cover every game played, and are reported instead
+func TestRepairNeedsEveryGame(t *testing.T) {
+	model := ModelConfig{Name: "Mock", Provider: "mock", Model: "always-correct"}
+	useConfig(t, Config{Models: []ModelConfig{model}})
+	rm := rooms[defaultRoom]
+	playRecordedGames(t, rm, currentConfig().Models[0])
+
+	rm.statsMux.Lock()
+	rm.stats.TotalGames, rm.stats.Wins, rm.stats.Losses = 50, 30, 10
+	rm.statsMux.Unlock()
+
+	if _, unrepaired := checkData(true); unrepaired == 0 {
+		t.Error("repair rebuilt 50 games from 5 records")
+	}
+	if rm.stats.TotalGames != 50 {
+		t.Errorf("totalGames %d after repair, want it left at 50", rm.stats.TotalGames)
+	}
+}
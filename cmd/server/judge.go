@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+const defaultJudgeTimeout = 8 * time.Second
+
+// AnswerJudgeConfig names a configured model that rules on guesses string
+// matching rejected, for answers like "the letter M" that no normalization
+// can catch
+type AnswerJudgeConfig struct {
+	Model          string  `json:"model"`          // Configured model name; empty disables the judge
+	TimeoutSeconds float64 `json:"timeoutSeconds"` // How long a ruling may take, defaults to 8
+}
+
+// JudgeRuling is one time the judge was asked about a guess, kept on the
+// model's state so disputed calls can be audited
+type JudgeRuling struct {
+	Round     int    `json:"round"`
+	Judge     string `json:"judge"`
+	Candidate string `json:"candidate"`
+	Verdict   string `json:"verdict"`            // "yes", "no", "close", "unclear" or "error"
+	Response  string `json:"response,omitempty"` // The judge's reply as given
+	Error     string `json:"error,omitempty"`
+}
+
+// answerJudge returns the judge model for a game. There is none if the judge
+// is off, or if it is playing in this game, since it would be ruling on its
+// opponents.
+func answerJudge(game *GameState) (ModelConfig, bool) {
+	name := currentConfig().AnswerJudge.Model
+	if name == "" {
+		return ModelConfig{}, false
+	}
+	for _, model := range game.SelectedModels {
+		if model.Name == name {
+			return ModelConfig{}, false
+		}
+	}
+	for _, model := range currentConfig().Models {
+		if model.Name == name {
+			return model, true
+		}
+	}
+	log.Printf("Answer judge %s is not configured\n", name)
+	return ModelConfig{}, false
+}
+
+// judgeAnswer asks the judge whether a rejected guess is the answer after
+// all. It returns nil if there is no judge for this game. The call goes
+// straight to the provider so nothing of it reaches the client.
+func judgeAnswer(ctx context.Context, game *GameState, guess string) *JudgeRuling {
+	judge, ok := answerJudge(game)
+	if !ok {
+		return nil
+	}
+	ruling := &JudgeRuling{Round: game.CurrentRound + 1, Judge: judge.Name, Candidate: guess}
+
+	provider, ok := providers.Lookup(judge.Provider)
+	if !ok {
+		ruling.Verdict = "error"
+		ruling.Error = fmt.Sprintf("unknown provider: %s", judge.Provider)
+		return ruling
+	}
+
+	timeout := defaultJudgeTimeout
+	if seconds := currentConfig().AnswerJudge.TimeoutSeconds; seconds > 0 {
+		timeout = time.Duration(seconds * float64(time.Second))
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf("Riddle: %s\nExpected answer: %s\nCandidate: %s\n\nIs the candidate the same answer? Reply with only yes, no, or close if it is related but not the same.",
+		game.Riddle, strings.Join(game.Answers, " or "), guess)
+	response, err := provider.Stream(ctx, judge, prompt, func(string) {})
+	if err != nil {
+		log.Printf("Answer judge %s failed: %v\n", judge.Name, err)
+		ruling.Verdict = "error"
+		ruling.Error = err.Error()
+		return ruling
+	}
+
+	ruling.Response = strings.TrimSpace(response)
+	verdict := strings.ToLower(sanitizeResponse(judge.Provider, response))
+	switch {
+	case strings.HasPrefix(verdict, "yes"):
+		ruling.Verdict = "yes"
+	case strings.HasPrefix(verdict, "no"):
+		ruling.Verdict = "no"
+	case strings.HasPrefix(verdict, "close"):
+		ruling.Verdict = "close"
+	default:
+		ruling.Verdict = "unclear"
+	}
+	return ruling
+}
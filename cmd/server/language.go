@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+const defaultLanguage = "en"
+
+// parseLanguage checks a submission's BCP-47 language tag; an empty one is
+// English
+func parseLanguage(tag string) (language.Tag, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		tag = defaultLanguage
+	}
+	return language.Parse(tag)
+}
+
+// baseLanguage is a tag's language without region or script, such as "de"
+// for "de-AT", with an empty or unknown tag taken as English
+func baseLanguage(tag string) string {
+	parsed, err := language.Parse(tag)
+	if err != nil || parsed == language.Und {
+		return defaultLanguage
+	}
+	base, _ := parsed.Base()
+	return base.String()
+}
+
+// languageName is the riddle's language as prompts name it, such as
+// "German". English riddles need no instruction, so it is empty for them.
+func languageName(game *GameState) string {
+	if base, _ := game.language.Base(); game.language == language.Und || base.String() == defaultLanguage {
+		return ""
+	}
+	if name := display.English.Tags().Name(game.language); name != "" {
+		return name
+	}
+	return game.Language
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+const (
+	defaultMaxTokens        = 256
+	defaultMaxResponseChars = 500
+)
+
+// errResponseTooLong cancels a call whose reply ran past maxResponseChars
+var errResponseTooLong = errors.New("response longer than the character limit")
+
+// setDefaultMaxTokens gives every model without its own maxTokens the global
+// one, so /config shows the limit each call is made with. Models with
+// reasoningMode get reasoningTokenFactor times as many, for the reasoning
+// before the answer. OpenAI reasoning models are left alone, since their
+// limit also covers hidden reasoning.
+func setDefaultMaxTokens(config *Config) {
+	limit := config.MaxTokens
+	switch {
+	case limit < 0:
+		return
+	case limit == 0:
+		limit = defaultMaxTokens
+	}
+	for i := range config.Models {
+		if config.Models[i].MaxTokens == nil && !providers.IsOpenAIReasoningModel(config.Models[i]) {
+			maxTokens := limit
+			if config.Models[i].ReasoningMode {
+				maxTokens *= reasoningTokenFactor
+			}
+			config.Models[i].MaxTokens = &maxTokens
+		}
+	}
+}
+
+// maxResponseChars is how much of a model's reply is forwarded and scored
+// before the call is cut off, or 0 for no limit
+func maxResponseChars(modelCfg ModelConfig) int {
+	limit := modelCfg.MaxResponseChars
+	if limit == 0 {
+		limit = currentConfig().MaxResponseChars
+	}
+	switch {
+	case limit < 0:
+		return 0
+	case limit == 0:
+		return defaultMaxResponseChars
+	}
+	return limit
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// liveInterval is how often dashboard and spectator sockets are sent a fresh
+// snapshot. The first is sent as soon as the socket opens.
+var liveInterval = time.Second
+
+// SpectatedGame is a game in progress as spectators see it. The answer is
+// never sent.
+type SpectatedGame struct {
+	Riddle       string                `json:"riddle"`
+	Username     string                `json:"username"`
+	Difficulty   string                `json:"difficulty"`
+	CurrentRound int                   `json:"currentRound"`
+	StartTime    time.Time             `json:"startTime"`
+	ModelStates  map[string]ModelState `json:"modelStates"`
+}
+
+// handleSpectate serves the /ws/spectate socket, which is sent the room's
+// games in progress every liveInterval
+func handleSpectate(w http.ResponseWriter, r *http.Request, rm *room) {
+	caps := capabilitiesFrom(r.Context())
+	if !caps.Has(CapSpectate) {
+		http.Error(w, "origin not allowed to spectate", http.StatusForbidden)
+		return
+	}
+	serveLive(w, r, caps, func(c *client) interface{} {
+		var spectated []SpectatedGame
+		for _, game := range games {
+			if game.room != rm {
+				continue
+			}
+			spectated = append(spectated, SpectatedGame{
+				Riddle:       game.Riddle,
+				Username:     game.Username,
+				Difficulty:   game.Difficulty,
+				CurrentRound: game.CurrentRound,
+				StartTime:    game.StartTime,
+				ModelStates:  c.visibleModelStates(game.ModelStates),
+			})
+		}
+		return map[string]interface{}{
+			"type":  "spectate",
+			"games": spectated,
+		}
+	})
+}
+
+// handleDashboard serves the /ws/dashboard socket, which is sent the server's
+// load and failing models every liveInterval
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	caps := capabilitiesFrom(r.Context())
+	if !caps.Has(CapDashboard) {
+		http.Error(w, "origin not allowed to open the dashboard", http.StatusForbidden)
+		return
+	}
+	serveLive(w, r, caps, func(c *client) interface{} {
+		breakersMux.Lock()
+		snapshot := make(map[string]circuitBreaker, len(breakers))
+		for model, b := range breakers {
+			breaker := *b
+			// The last error is the provider's own message
+			if !c.caps.Has(CapExtended) {
+				breaker.LastError = ""
+			}
+			snapshot[model] = breaker
+		}
+		breakersMux.Unlock()
+
+		return map[string]interface{}{
+			"type":       "dashboard",
+			"games":      gameCapacityLocked(),
+			"modelCalls": modelCallCapacity(),
+			"breakers":   snapshot,
+		}
+	})
+}
+
+// serveLive upgrades the request and sends it what snapshot builds until the
+// socket closes. snapshot is called with gamesMux held, and its result is
+// serialized before the lock is released since it may share game state.
+func serveLive(w http.ResponseWriter, r *http.Request, caps capabilitySet, snapshot func(c *client) interface{}) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("Upgrade error:", err)
+		return
+	}
+	defer conn.Close()
+	c := newClient(conn, caps)
+
+	// Nothing is read from these sockets; reading only notices the close
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(liveInterval)
+	defer ticker.Stop()
+	for {
+		gamesMux.Lock()
+		data, err := json.Marshal(snapshot(c))
+		gamesMux.Unlock()
+		if err != nil {
+			log.Println("Error encoding live update:", err)
+			return
+		}
+
+		c.writeMux.Lock()
+		err = conn.WriteMessage(websocket.TextMessage, data)
+		c.writeMux.Unlock()
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,46 +1,111 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"context"
+	crand "crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
+	"golang.org/x/text/language"
+
+	"github.com/tahcohcat/turingroulette/internal/answers"
+	"github.com/tahcohcat/turingroulette/internal/providers"
 )
 
 type Config struct {
-	Models []ModelConfig `json:"models"`
+	Models             []ModelConfig            `json:"models"`
+	Origins            []OriginConfig           `json:"origins"`
+	SimulatedStreaming SimulatedStreamingConfig `json:"simulatedStreaming"`
+	AutoTagging        AutoTaggingConfig        `json:"autoTagging"`
+	Leaderboard        LeaderboardConfig        `json:"leaderboard"`
+	Rooms              []string                 `json:"rooms"` // Extra rooms besides "default"
+	Moderation         ModerationConfig         `json:"moderation"`
+	MaxActiveGames     int                      `json:"maxActiveGames"` // Games allowed to run at once, defaults to 200
+	Summary            SummaryConfig            `json:"summary"`
+	CommentaryModel    string                   `json:"commentaryModel"` // Configured model name that commentates each round; empty disables
+	Timeouts           map[string]TimeoutConfig `json:"timeouts"` // Keyed by provider, with "default" applying to all
+	Retries            map[string]RetryConfig   `json:"retries"`  // Keyed by provider, with "default" applying to all
+	RateLimits         map[string]RateLimitConfig `json:"rateLimits"` // Keyed by provider, shared by every game
+	CircuitBreaker     CircuitBreakerConfig `json:"circuitBreaker"`
+	Fallbacks          []string `json:"fallbacks"` // Model names, in order, that stand in for a model failing in round 0
+	CacheResponses     bool     `json:"cacheResponses"` // Development only: replay saved responses to repeated prompts
+	RecordDir          string   `json:"recordDir"` // Write every provider call's stream here, see Recording
+	ReplayDir          string   `json:"replayDir"` // Answer from recordings here instead of calling providers
+	LogProviderRequests bool    `json:"logProviderRequests"` // Debug log of every provider request with keys redacted, also LOG_PROVIDER_REQUESTS=1
+	MaxConcurrentModelCalls int `json:"maxConcurrentModelCalls"` // Provider calls in flight across all games; 0 is unlimited
+	FollowUpWordLimit  int      `json:"followUpWordLimit"` // Replies longer than this many words get one follow-up asking for just the answer, defaults to 6; negative disables
+	AnswerMatching     AnswerMatchingConfig `json:"answerMatching"`
+	Matching           map[string]AnswerMatchingConfig `json:"matching"` // Keyed by difficulty, overriding answerMatching for those games
+	AnswerJudge        AnswerJudgeConfig    `json:"answerJudge"`
+	TokenFlushMs       int      `json:"tokenFlushMs"` // How long streamed tokens are gathered into one guess message, defaults to 75; negative sends each token
+	FewShot            []FewShotExample `json:"fewShot"` // Solved riddles shown before the real one, see fewShotExamples
+	FewShotTokens      int      `json:"fewShotTokens"` // Most tokens the examples may take, defaults to 300
+	MaxTokens          int      `json:"maxTokens"` // Models' maxTokens when they don't set one, defaults to 256; negative leaves it to the provider
+	MaxResponseChars   int      `json:"maxResponseChars"` // Characters of a reply scored before the call is cut off, defaults to 500; negative disables
+	MaxChosenModels    int      `json:"maxChosenModels"` // Most models a player may pick for one game, defaults to 6
+	RoundDeadlineSeconds float64 `json:"roundDeadlineSeconds"` // Longest a round waits for its models; 0, the default, waits for every model's own timeout
+	answerCheckers     map[string]*answers.Checker // Built from matching when loaded, see buildAnswerCheckers
+	defaultChecker     *answers.Checker
+	guessPhrases       map[string]answers.Phrases // From phrases.json, see loadPhrases
+	prompts            map[string]*template.Template // Keyed by provider, see loadPrompts
 }
 
-type ModelConfig struct {
-	Name     string `json:"name"`
-	Provider string `json:"provider"` // "openai", "anthropic", "google", "ollama", "huggingface"
-	Model    string `json:"model"`
-	APIKey   string `json:"apiKey"`
-	Endpoint string `json:"endpoint"`
+// SimulatedStreamingConfig controls how responses from providers without a
+// streaming API (older Gemini models, HuggingFace) are replayed to the client
+type SimulatedStreamingConfig struct {
+	Pacing  string `json:"pacing"`  // "word" (default) or "instant"
+	DelayMs int    `json:"delayMs"` // Delay between words, defaults to 30ms
 }
 
+// Model configs and chat turns are defined alongside the providers that use them
+type ModelConfig = providers.ModelConfig
+type ChatMessage = providers.ChatMessage
+type FewShotExample = providers.FewShotExample
+
 type RiddleSubmission struct {
 	Riddle     string   `json:"riddle"`
 	Answer     string   `json:"answer"`
+	Answers    []string `json:"answers"` // Every accepted answer; Answer is folded in for older clients
+	AnswerPattern string `json:"answerPattern"` // Optional regular expression a guess is matched against instead of the answers
+	Synonyms   []string `json:"synonyms"` // Words treated as the same when matching, such as "couch=sofa"
+	Language   string   `json:"language"` // BCP-47 tag of the riddle's language, defaults to "en"
+	ExactMatch bool     `json:"exactMatch"` // Match guesses as written, for answers such as "&" that cleaning would strip
+	SharedGuesses bool  `json:"sharedGuesses"` // Show every model the others' wrong guesses, not just its own
 	Clues      []string `json:"clues"`
 	Difficulty string   `json:"difficulty"` // "easy", "medium", "hard"
 	Username   string   `json:"username"`
+	Tags       []string `json:"tags"` // Optional author-provided genre tags
+	Commentary *bool    `json:"commentary"` // Set false to turn off round commentary for this game
+	Models     []string `json:"models"` // Names of enabled models from /config to play against; three are drawn at random if empty
 }
 
 type GameState struct {
 	Riddle         string                `json:"riddle"`
-	Answer         string                `json:"answer"`
+	Answer         string                `json:"answer"` // The first accepted answer
+	Answers        []string              `json:"answers"`
+	AnswerPattern  string                `json:"answerPattern,omitempty"`
+	Synonyms       []string              `json:"synonyms,omitempty"`
+	Language       string                `json:"language"`
+	ExactMatch     bool                  `json:"exactMatch,omitempty"`
+	SharedGuesses  bool                  `json:"sharedGuesses,omitempty"`
 	Clues          []string              `json:"clues"`
 	Difficulty     string                `json:"difficulty"`
 	CurrentRound   int                   `json:"currentRound"`
@@ -48,6 +113,16 @@ type GameState struct {
 	StartTime      time.Time             `json:"startTime"`
 	Username       string                `json:"username"`
 	SelectedModels []ModelConfig         `json:"selectedModels"`
+	Tags           []string              `json:"tags"`
+	ActivitySeconds float64              `json:"activitySeconds"` // Time spent in rounds, excluding pauses between them
+	Room           string                `json:"room"`
+	Commentary     bool                  `json:"commentary"`
+	ChosenModels   bool                  `json:"chosenModels,omitempty"` // The player picked the models rather than having them drawn at random
+	room           *room
+	answerPattern  *regexp.Regexp // Compiled AnswerPattern, nil if the riddle has none
+	language       language.Tag   // Parsed Language
+	triedModels    map[string]bool // Models that have played round 0, see replaceFailedModels
+	sharedTried    []triedGuess    // Every model's wrong guesses as the round started, see snapshotSharedGuesses
 }
 
 type ModelState struct {
@@ -56,17 +131,74 @@ type ModelState struct {
 	Round         int       `json:"round"` // Which round they got it correct
 	AllGuesses    []string  `json:"allGuesses"` // History of all guesses
 	GuessResults  []bool    `json:"guessResults"` // History of correct/incorrect for each guess
-	ResponseTime  float64   `json:"responseTime"` // Response time in seconds
+	ResponseTime  float64   `json:"responseTime"` // Provider latency in seconds, excluding queue wait
 	ResponseTimes []float64 `json:"responseTimes"` // History of response times for each round
+	ResponseWordCount  int   `json:"responseWordCount"` // Words in this round's reply, before any follow-up
+	ResponseWordCounts []int `json:"responseWordCounts"` // History of reply word counts, for rounds with a reply
 	GuessCount    int       `json:"guessCount"` // Track number of guesses made
 	GuessesToCorrect int    `json:"guessesToCorrect"` // How many guesses needed to get correct
+	Error         string    `json:"error,omitempty"` // Last provider error, only sent to extended origins
+	QueueWait     float64   `json:"queueWait"` // Seconds spent waiting for a call slot this round
+	QueueWaits    []float64 `json:"queueWaits"` // History of queue waits, parallel to ResponseTimes
+	FirstTokenLatency float64 `json:"firstTokenLatency"` // Seconds from request start to first response byte this round
+	ErrorCategory string    `json:"errorCategory,omitempty"` // Kind of failure this round, e.g. "timeout:connect" or "rateLimited"
+	Truncated     bool      `json:"truncated,omitempty"` // This round's guess was cut off by the model's token limit or the response length limit
+	Truncations   int       `json:"truncations,omitempty"` // Rounds this game whose guess was cut off
+	PromptTruncated bool    `json:"promptTruncated,omitempty"` // This round's prompt was cut down to fit the model's contextBudget
+	Timeouts      map[string]int `json:"timeouts,omitempty"` // Timeouts this game by tier
+	Attempts      int       `json:"attempts,omitempty"` // Provider calls made this round, more than 1 when retried
+	TimedOut      bool      `json:"timedOut,omitempty"` // This round's call ran out of time, see ErrorCategory for which deadline
+	Unavailable   bool      `json:"unavailable,omitempty"` // Skipped this round because the model's circuit breaker is open
+	Outcome       string    `json:"outcome,omitempty"` // How this round's call ended: "completed", "truncated", "filtered", "errored", "skipped" when the model declined to guess or "timedOut" when the round ended first
+	Moderation    *ModerationDecision `json:"moderation,omitempty"` // Moderation of this round's guess
+	GuessModeration []*ModerationDecision `json:"guessModeration,omitempty"` // Parallel to AllGuesses; nil entries weren't moderated
+	Messages      []ChatMessage `json:"-"` // Conversation with chat-capable providers, see usesChatHistory
+	TokensUsed    TokensUsed    `json:"tokensUsed"` // This game so far, for providers that report usage
+	Candidate     string        `json:"candidate,omitempty"` // The part of this round's guess that was checked, when it was picked out of a wordier reply
+	MatchedAnswer string        `json:"matchedAnswer,omitempty"` // The accepted answer the model's correct guess matched
+	Fuzzy         bool          `json:"fuzzy,omitempty"` // This round's guess was accepted as close enough to the answer rather than a match
+	Phonetic      bool          `json:"phonetic,omitempty"` // The correct guess was accepted for sounding like the answer
+	JudgeRulings  []JudgeRuling `json:"judgeRulings,omitempty"` // Every time the answer judge was asked about this model's guesses
+	Confidence    *float64      `json:"confidence,omitempty"` // The model's own confidence in this round's answer, with structuredAnswers on
+	FollowedUp    bool          `json:"followedUp,omitempty"` // This round's first reply was too long, so the follow-up's answer was checked instead
+	Restated      bool          `json:"restated,omitempty"` // This round's guess quoted the riddle back rather than answering it
+	NearMisses    int           `json:"nearMisses,omitempty"` // Wrong guesses this game that came close, see AnswerMatch.NearMiss
+}
+
+// TokensUsed counts the tokens providers reported for a model's calls
+type TokensUsed struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+func (t *TokensUsed) add(other TokensUsed) {
+	t.PromptTokens += other.PromptTokens
+	t.CompletionTokens += other.CompletionTokens
+	t.TotalTokens += other.TotalTokens
+}
+
+func (t *TokensUsed) addUsage(usage providers.Usage) {
+	t.add(TokensUsed{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.PromptTokens + usage.CompletionTokens,
+	})
 }
 
 type StreamMessage struct {
 	Model   string `json:"model"`
 	Content string `json:"content"`
 	Done    bool   `json:"done"`
-	Type    string `json:"type"` // "guess" or "result"
+	Type    string `json:"type"` // "guess", "thinking", "status", "result", "nearmiss" or "error"
+	Truncated bool `json:"truncated,omitempty"` // The model hit its token limit or the response length limit before finishing the guess
+	TimedOut  bool `json:"timedOut,omitempty"`  // On a result: the model ran out of time rather than answering wrong
+	Outcome   string `json:"outcome,omitempty"` // On a result or error: how the call ended, as in ModelState
+	Fuzzy     bool   `json:"fuzzy,omitempty"`   // On a result: the guess was close enough to the answer rather than a match
+	Phonetic  bool   `json:"phonetic,omitempty"` // On a result: the guess was accepted for sounding like the answer
+	Judged    bool   `json:"judged,omitempty"`  // On a result: the answer judge accepted a guess matching rejected
+	Restated  bool   `json:"restated,omitempty"` // On a result: the guess only quoted the riddle back, so it counted as no answer
+	Similarity float64 `json:"similarity,omitempty"` // On a nearmiss: how close the guess came, from 0 to 1
 }
 
 type GameResult struct {
@@ -74,10 +206,14 @@ type GameResult struct {
 	CorrectCount int       `json:"correctCount"`
 	TotalModels  int       `json:"totalModels"`
 	Difficulty   string    `json:"difficulty"`
-	Duration     float64   `json:"duration"` // seconds
+	Duration     float64   `json:"duration"` // Round activity in seconds, used for scoring and stats
+	WallDuration float64   `json:"wallDuration"` // Wall-clock seconds from game start, including pauses
 	RoundsPlayed int       `json:"roundsPlayed"`
 	Timestamp    time.Time `json:"timestamp"`
 	Username     string    `json:"username"`
+	Summary      string    `json:"summary"` // Shareable plain-text summary
+	SummaryMarkdown string `json:"summaryMarkdown"`
+	TokensUsed   TokensUsed `json:"tokensUsed"` // All models combined
 }
 
 type Stats struct {
@@ -89,11 +225,15 @@ type Stats struct {
 	AverageDuration float64                 `json:"averageDuration"`
 	TotalDuration   float64                 `json:"totalDuration"`
 	ByModel         map[string]ModelStats   `json:"byModel"`
+	ByTag           map[string]int          `json:"byTag"` // Author and inferred tags combined
+	Commentary      *CommentaryStats        `json:"commentary,omitempty"` // Non-competitive, kept out of ByModel
+	Instances       map[string][]ModelStats `json:"instances,omitempty"` // ByModel grouped by display name, only in /stats responses
 }
 
 type ModelStats struct {
 	Name            string  `json:"name"`
 	Provider        string  `json:"provider"`
+	InstanceLabel   string  `json:"instanceLabel"`
 	GamesPlayed     int     `json:"gamesPlayed"`
 	TimesCorrect    int     `json:"timesCorrect"`
 	Accuracy        float64 `json:"accuracy"`
@@ -101,9 +241,162 @@ type ModelStats struct {
 	TotalResponseTime float64 `json:"totalResponseTime"`
 	AvgGuessesToCorrect float64 `json:"avgGuessesToCorrect"`
 	TotalGuessesToCorrect int   `json:"totalGuessesToCorrect"`
+	AvgQueueWait    *float64 `json:"avgQueueWait,omitempty"` // Over latencyGames, unknown for stats from before it was measured
+	TotalQueueWait  float64 `json:"totalQueueWait"`
+	AvgFirstTokenLatency   *float64 `json:"avgFirstTokenLatency,omitempty"` // Over latencyGames, like avgQueueWait
+	TotalFirstTokenLatency float64 `json:"totalFirstTokenLatency"`
+	LatencyGames    int     `json:"latencyGames"` // Games counted in totalQueueWait and totalFirstTokenLatency; older games were never measured
+	TimeoutsByTier  map[string]int `json:"timeoutsByTier,omitempty"` // "connect", "firstToken", "total" or "round"
+	RoundTimeouts   int     `json:"roundTimeouts"` // Rounds that ended before the model answered, all games
+	TokensUsed      TokensUsed `json:"tokensUsed"` // All games, for providers that report usage
+	NearMisses      int     `json:"nearMisses"` // Wrong guesses that came close, all games
+	Truncations     int     `json:"truncations"` // Guesses cut off by a length limit, all games
+	AvgResponseWordCount float64 `json:"avgResponseWordCount"` // Words per reply, before any follow-up
+	TotalResponseWords   int     `json:"totalResponseWords"`
+	TotalResponses       int     `json:"totalResponses"` // Replies counted in totalResponseWords
+}
+
+// Invariants for the types above live next to them so a new field gets its
+// checks in the same change. Each check reports problems and, when repair is
+// set, fixes whatever can be derived from the record's own fields.
+
+func (s *Stats) checkInvariants(repair bool) []dataIssue {
+	var issues []dataIssue
+	if s.Wins+s.Losses != s.TotalGames {
+		// Needs the game records to fix; see room.checkStats
+		issues = append(issues, dataIssue{What: fmt.Sprintf("wins (%d) + losses (%d) != totalGames (%d)", s.Wins, s.Losses, s.TotalGames)})
+	}
+	if s.TotalGames > 0 {
+		if want := float64(s.Wins) / float64(s.TotalGames) * 100; !closeTo(s.WinRate, want) {
+			issues = append(issues, dataIssue{What: fmt.Sprintf("winRate %.2f, expected %.2f", s.WinRate, want), Repaired: repair})
+			if repair {
+				s.WinRate = want
+			}
+		}
+		if want := s.TotalDuration / float64(s.TotalGames); !closeTo(s.AverageDuration, want) {
+			issues = append(issues, dataIssue{What: fmt.Sprintf("averageDuration %.2f, expected %.2f", s.AverageDuration, want), Repaired: repair})
+			if repair {
+				s.AverageDuration = want
+			}
+		}
+	}
+	byDifficulty := 0
+	for _, count := range s.ByDifficulty {
+		byDifficulty += count
+	}
+	if byDifficulty != s.TotalGames {
+		issues = append(issues, dataIssue{What: fmt.Sprintf("byDifficulty sums to %d, totalGames is %d", byDifficulty, s.TotalGames)})
+	}
+	for key, modelStat := range s.ByModel {
+		for _, issue := range modelStat.checkInvariants(repair) {
+			issue.What = "byModel[" + key + "]: " + issue.What
+			issues = append(issues, issue)
+		}
+		s.ByModel[key] = modelStat
+	}
+	return issues
+}
+
+func (m *ModelStats) checkInvariants(repair bool) []dataIssue {
+	var issues []dataIssue
+	if m.TimesCorrect > m.GamesPlayed || m.TimesCorrect < 0 {
+		issues = append(issues, dataIssue{What: fmt.Sprintf("timesCorrect %d out of range for %d games", m.TimesCorrect, m.GamesPlayed)})
+	}
+	if m.GamesPlayed > 0 {
+		derived := []struct {
+			name  string
+			field *float64
+			want  float64
+		}{
+			{"accuracy", &m.Accuracy, float64(m.TimesCorrect) / float64(m.GamesPlayed) * 100},
+			{"avgResponseTime", &m.AvgResponseTime, m.TotalResponseTime / float64(m.GamesPlayed)},
+		}
+		for _, d := range derived {
+			if !closeTo(*d.field, d.want) {
+				issues = append(issues, dataIssue{What: fmt.Sprintf("%s %.2f, expected %.2f", d.name, *d.field, d.want), Repaired: repair})
+				if repair {
+					*d.field = d.want
+				}
+			}
+		}
+	}
+	issues = append(issues, m.checkLatencyInvariants(repair)...)
+	if m.TotalResponses > 0 {
+		if want := float64(m.TotalResponseWords) / float64(m.TotalResponses); !closeTo(m.AvgResponseWordCount, want) {
+			issues = append(issues, dataIssue{What: fmt.Sprintf("avgResponseWordCount %.2f, expected %.2f", m.AvgResponseWordCount, want), Repaired: repair})
+			if repair {
+				m.AvgResponseWordCount = want
+			}
+		}
+	}
+	if m.TimesCorrect > 0 {
+		if want := float64(m.TotalGuessesToCorrect) / float64(m.TimesCorrect); !closeTo(m.AvgGuessesToCorrect, want) {
+			issues = append(issues, dataIssue{What: fmt.Sprintf("avgGuessesToCorrect %.2f, expected %.2f", m.AvgGuessesToCorrect, want), Repaired: repair})
+			if repair {
+				m.AvgGuessesToCorrect = want
+			}
+		}
+	}
+	if want := m.TimeoutsByTier["round"]; m.RoundTimeouts != want {
+		issues = append(issues, dataIssue{What: fmt.Sprintf("roundTimeouts %d, expected %d", m.RoundTimeouts, want), Repaired: repair})
+		if repair {
+			m.RoundTimeouts = want
+		}
+	}
+	return issues
+}
+
+// checkLatencyInvariants checks the queue wait and first token averages.
+// Games from before they were measured are left out of latencyGames, and
+// with none measured the averages are unknown rather than 0.
+func (m *ModelStats) checkLatencyInvariants(repair bool) []dataIssue {
+	var issues []dataIssue
+	if m.LatencyGames < 0 || m.LatencyGames > m.GamesPlayed {
+		return []dataIssue{{What: fmt.Sprintf("latencyGames %d out of range for %d games", m.LatencyGames, m.GamesPlayed)}}
+	}
+	if m.LatencyGames == 0 && (m.TotalQueueWait != 0 || m.TotalFirstTokenLatency != 0) {
+		// Can't tell how many games the totals cover
+		return []dataIssue{{What: "queue wait or first token totals without latencyGames"}}
+	}
+
+	averages := []struct {
+		name  string
+		field **float64
+		total float64
+	}{
+		{"avgQueueWait", &m.AvgQueueWait, m.TotalQueueWait},
+		{"avgFirstTokenLatency", &m.AvgFirstTokenLatency, m.TotalFirstTokenLatency},
+	}
+	for _, a := range averages {
+		want := latencyAverage(a.total, m.LatencyGames)
+		switch {
+		case want == nil && *a.field != nil:
+			issues = append(issues, dataIssue{What: fmt.Sprintf("%s %.2f with no measured games, expected unknown", a.name, **a.field), Repaired: repair})
+		case want != nil && *a.field == nil:
+			issues = append(issues, dataIssue{What: fmt.Sprintf("%s unknown, expected %.2f", a.name, *want), Repaired: repair})
+		case want != nil && !closeTo(**a.field, *want):
+			issues = append(issues, dataIssue{What: fmt.Sprintf("%s %.2f, expected %.2f", a.name, **a.field, *want), Repaired: repair})
+		default:
+			continue
+		}
+		if repair {
+			*a.field = want
+		}
+	}
+	return issues
+}
+
+// latencyAverage is total spread over the measured games, nil if none were
+func latencyAverage(total float64, games int) *float64 {
+	if games == 0 {
+		return nil
+	}
+	avg := total / float64(games)
+	return &avg
 }
 
 type LeaderboardEntry struct {
+	ID           string                    `json:"id"`
 	Riddle       string                    `json:"riddle"`
 	Difficulty   string                    `json:"difficulty"`
 	Username     string                    `json:"username"`
@@ -111,130 +404,106 @@ type LeaderboardEntry struct {
 	CorrectCount int                       `json:"correctCount"`
 	TotalModels  int                       `json:"totalModels"`
 	Duration     float64                   `json:"duration"`
+	WallDuration float64                   `json:"wallDuration"`
+	RoundsPlayed int                       `json:"roundsPlayed"`
 	Timestamp    time.Time                 `json:"timestamp"`
 	Score        int                       `json:"score"` // Calculated score
+	ScoringVersion int                     `json:"scoringVersion"` // calculateScore version the score was computed with
 	Models       []LeaderboardModelEntry   `json:"models"`
+	Summary      string                    `json:"summary,omitempty"`
+	SummaryMarkdown string                 `json:"summaryMarkdown,omitempty"`
+	Tags         []string                  `json:"tags,omitempty"`         // Provided by the author
+	PatternAnswer bool                     `json:"patternAnswer,omitempty"` // Guesses were matched against the author's answer pattern, not fixed answers
+	Synonyms     []string                  `json:"synonyms,omitempty"` // The author's synonym groups, which may explain an accepted guess
+	ChosenModels bool                      `json:"chosenModels,omitempty"` // The player picked the models rather than having them drawn at random
+	InferredTags []string                  `json:"inferredTags,omitempty"` // Added by auto-tagging
+	Hidden       bool                      `json:"hidden,omitempty"` // Soft-deleted by moderation, excluded from public endpoints
+	HiddenReason string                    `json:"hiddenReason,omitempty"`
+	HiddenBy     string                    `json:"hiddenBy,omitempty"`
+	HiddenAt     *time.Time                `json:"hiddenAt,omitempty"`
+}
+
+func (e *LeaderboardEntry) checkInvariants(repair bool) []dataIssue {
+	var issues []dataIssue
+	if e.ID == "" {
+		issues = append(issues, dataIssue{What: "missing id", Repaired: repair})
+		if repair {
+			e.ID = newID()
+		}
+	}
+	if e.CorrectCount < 0 || e.CorrectCount > e.TotalModels {
+		issues = append(issues, dataIssue{What: fmt.Sprintf("correctCount %d out of range for %d models", e.CorrectCount, e.TotalModels)})
+	}
+	if len(e.Models) > 0 {
+		correct := 0
+		for _, model := range e.Models {
+			if model.Correct {
+				correct++
+			}
+		}
+		if correct != e.CorrectCount {
+			issues = append(issues, dataIssue{What: fmt.Sprintf("correctCount %d, but %d models are marked correct", e.CorrectCount, correct), Repaired: repair})
+			if repair {
+				e.CorrectCount = correct
+			}
+		}
+	}
+	// Older scoring versions are brought up to date by the rescore endpoint
+	if e.ScoringVersion == scoringVersion {
+		want := calculateScore(GameResult{
+			PlayerWins:   e.PlayerWon,
+			CorrectCount: e.CorrectCount,
+			TotalModels:  e.TotalModels,
+			Difficulty:   e.Difficulty,
+			Duration:     e.Duration,
+		})
+		if e.Score != want {
+			issues = append(issues, dataIssue{What: fmt.Sprintf("score %d, expected %d under scoring version %d", e.Score, want, scoringVersion), Repaired: repair})
+			if repair {
+				e.Score = want
+			}
+		}
+	}
+	return issues
 }
 
 type LeaderboardModelEntry struct {
 	Name          string  `json:"name"`
 	Provider      string  `json:"provider"`
+	InstanceLabel string  `json:"instanceLabel"`
 	Correct       bool    `json:"correct"`
+	Round         int     `json:"round,omitempty"` // Round the model solved the riddle in
 	ResponseTime  float64 `json:"responseTime"`
 	FinalGuess    string  `json:"finalGuess"`
-}
-
-// OpenAI structures
-type OpenAIRequest struct {
-	Model    string          `json:"model"`
-	Messages []OpenAIMessage `json:"messages"`
-	Stream   bool            `json:"stream"`
-}
-
-type OpenAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type OpenAIStreamResponse struct {
-	Choices []struct {
-		Delta struct {
-			Content string `json:"content"`
-		} `json:"delta"`
-	} `json:"choices"`
-}
-
-// Anthropic structures
-type AnthropicRequest struct {
-	Model     string             `json:"model"`
-	Messages  []AnthropicMessage `json:"messages"`
-	MaxTokens int                `json:"max_tokens"`
-	Stream    bool               `json:"stream"`
-}
-
-type AnthropicMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
-}
-
-type AnthropicStreamResponse struct {
-	Type  string `json:"type"`
-	Delta struct {
-		Type string `json:"type"`
-		Text string `json:"text"`
-	} `json:"delta"`
-}
-
-// Google Gemini structures
-type GeminiRequest struct {
-	Contents []GeminiContent `json:"contents"`
-}
-
-type GeminiContent struct {
-	Parts []GeminiPart `json:"parts"`
-}
-
-type GeminiPart struct {
-	Text string `json:"text"`
-}
-
-type GeminiResponse struct {
-	Candidates []struct {
-		Content struct {
-			Parts []GeminiPart `json:"parts"`
-		} `json:"content"`
-	} `json:"candidates"`
-}
-
-// Ollama structures
-type OllamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
-}
-
-type OllamaStreamResponse struct {
-	Response string `json:"response"`
-	Done     bool   `json:"done"`
-}
-
-// HuggingFace structures
-type HuggingFaceRequest struct {
-	Inputs     string                 `json:"inputs"`
-	Parameters HuggingFaceParameters  `json:"parameters"`
-	Options    HuggingFaceOptions     `json:"options"`
-}
-
-type HuggingFaceParameters struct {
-	MaxNewTokens int     `json:"max_new_tokens"`
-	Temperature  float64 `json:"temperature"`
-}
-
-type HuggingFaceOptions struct {
-	UseCache     bool `json:"use_cache"`
-	WaitForModel bool `json:"wait_for_model"`
-}
-
-type HuggingFaceResponse struct {
-	GeneratedText string `json:"generated_text"`
+	MatchedAnswer string  `json:"matchedAnswer,omitempty"` // Which accepted answer a correct model matched
+	Phonetic      bool    `json:"phonetic,omitempty"` // The correct guess only sounded like the answer, for auditing
 }
 
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
-		return true
+		_, ok := resolveOriginCapabilities(r.Header.Get("Origin"))
+		return ok
 	},
 }
 
 var games = make(map[*websocket.Conn]*GameState)
 var gamesMux sync.Mutex
-var config Config
-var stats Stats
-var statsMux sync.Mutex
-var leaderboard []LeaderboardEntry
-var leaderboardMux sync.Mutex
+// runtimeConfig holds the current configuration. A Config is never modified
+// once stored: updates build a new value and swap the pointer, so readers that
+// load it once per operation always see a consistent snapshot.
+var runtimeConfig atomic.Pointer[Config]
+var configWriteMux sync.Mutex // Serializes read-modify-write updates
+
+func currentConfig() *Config {
+	return runtimeConfig.Load()
+}
 
 const MAX_GUESSES = 3
 
+// scoringVersion identifies the inputs calculateScore uses. Version 1 scored
+// wall-clock duration; version 2 scores round activity only.
+const scoringVersion = 2
+
 var dataDir string
 
 func init() {
@@ -245,16 +514,73 @@ func init() {
 }
 
 func main() {
+	checkOnly := flag.Bool("check-data", false, "check the data directory for inconsistencies and exit")
+	repair := flag.Bool("repair", false, "recompute derivable values when checking data")
+	strict := flag.Bool("strict", false, "refuse to start if the data directory has unrepaired inconsistencies")
+	benchmark := flag.String("benchmark", "", "play every riddle in this JSON file against the configured models, write a report and exit")
+	benchmarkOut := flag.String("benchmark-out", "", "where the benchmark's games and report go, defaults to benchmarks/{time} in the data directory")
+	flag.Parse()
+
 	os.MkdirAll(dataDir, 0755)
 	loadConfig()
-	loadStats()
-	loadLeaderboard()
+	if *benchmark != "" {
+		outDir := *benchmarkOut
+		if outDir == "" {
+			outDir = dataDir + "benchmarks/" + time.Now().Format("20060102-150405")
+		}
+		report, err := runBenchmark(*benchmark, outDir)
+		if err != nil {
+			log.Fatalln("Benchmark failed:", err)
+		}
+		log.Printf("Benchmark of %d games written to %s\n", report.Games, filepath.Join(outDir, "report.md"))
+		return
+	}
+	loadRooms()
+
+	found, unrepaired := checkData(*repair)
+	if *checkOnly {
+		log.Printf("Data check found %d problems, %d unrepaired\n", found, unrepaired)
+		if unrepaired > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if unrepaired > 0 {
+		log.Printf("WARNING: data directory has %d unrepaired inconsistencies; run with --check-data --repair\n", unrepaired)
+		if *strict {
+			log.Fatalln("Refusing to start with inconsistent data (--strict)")
+		}
+	}
+
+	go runTagWorker()
+	go preloadOllamaModels()
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/ws", handleWebSocket)
 	mux.HandleFunc("/config", handleGetConfig)
-	mux.HandleFunc("/stats", handleGetStats)
-	mux.HandleFunc("/leaderboard", handleGetLeaderboard)
+	mux.HandleFunc("/status", handleStatus)
+	mux.HandleFunc("/admin/models/", requireAdmin(handleAdminModels))
+	mux.HandleFunc("/admin/moderation", requireAdmin(handleAdminModeration))
+	mux.HandleFunc("/admin/breakers", requireAdmin(handleAdminBreakers))
+	mux.HandleFunc("/ws/dashboard", withOriginCapabilities(handleDashboard))
+	mux.HandleFunc("/oembed", withRateLimit(embedLimiter, handleOEmbed))
+	registerChaosRoutes(mux)
+
+	// Routes scoped to a room are served under /rooms/{room}/ and, for the
+	// default room, at the top level
+	roomMux := http.NewServeMux()
+	for _, m := range []*http.ServeMux{mux, roomMux} {
+		m.HandleFunc("/ws", withOriginCapabilities(withRoom(handleWebSocket)))
+		m.HandleFunc("/ws/spectate", withOriginCapabilities(withRoom(handleSpectate)))
+		m.HandleFunc("/stats", withRoom(handleGetStats))
+		m.HandleFunc("/stats/difficulty-calibration", withRoom(handleDifficultyCalibration))
+		m.HandleFunc("/leaderboard", withRoom(handleGetLeaderboard))
+		m.HandleFunc("/users/", withRoom(handleUserGames))
+		m.HandleFunc("/embed/games/", withRateLimit(embedLimiter, withRoom(handleEmbedGame)))
+		m.HandleFunc("/admin/leaderboard", requireAdmin(withRoom(handleAdminLeaderboard)))
+		m.HandleFunc("/admin/leaderboard/", requireAdmin(withRoom(handleAdminLeaderboardEntry)))
+		m.HandleFunc("/admin/leaderboard/rescore", requireAdmin(withRoom(handleAdminRescore)))
+	}
+	mux.HandleFunc("/rooms/", roomRouter(roomMux))
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./static/index.html")
@@ -288,6 +614,12 @@ func corsMiddleware(next http.Handler) http.Handler {
 }
 
 func loadConfig() {
+	// Held until the new config is stored, so a reload never lands between an
+	// admin update's swap and its write to config.json
+	configWriteMux.Lock()
+	defer configWriteMux.Unlock()
+
+	var config Config
 	file, err := os.ReadFile(dataDir + "config.json")
 	if err != nil {
 		log.Println("No config.json found, using default configuration")
@@ -298,6 +630,12 @@ func loadConfig() {
 				{Name: "CodeLlama", Provider: "ollama", Model: "codellama", Endpoint: "http://localhost:11434"},
 			},
 		}
+		setDefaultEnabled(&config)
+		setDefaultMaxTokens(&config)
+		config.guessPhrases = loadPhrases()
+		config.prompts = loadPrompts()
+		buildAnswerCheckers(&config)
+		runtimeConfig.Store(&config)
 		return
 	}
 
@@ -313,63 +651,125 @@ func loadConfig() {
 			config.Models[i].APIKey = envValue
 		}
 		// Also check for provider-specific env vars
-		switch config.Models[i].Provider {
-		case "openai":
-			if key := os.Getenv("OPENAI_API_KEY"); key != "" {
-				config.Models[i].APIKey = key
-			}
-		case "anthropic":
-			if key := os.Getenv("ANTHROPIC_API_KEY"); key != "" {
-				config.Models[i].APIKey = key
-			}
-		case "google":
-			if key := os.Getenv("GOOGLE_API_KEY"); key != "" {
-				config.Models[i].APIKey = key
-			}
-		case "huggingface":
-			if key := os.Getenv("HUGGINGFACE_API_KEY"); key != "" {
+		if envKey := providers.APIKeyEnv(config.Models[i].Provider); envKey != "" {
+			if key := os.Getenv(envKey); key != "" {
 				config.Models[i].APIKey = key
 			}
 		}
 	}
 
+	if err := validateConfig(&config); err != nil {
+		log.Fatal("Invalid config.json: ", err)
+	}
+
+	setDefaultEnabled(&config)
+	setDefaultMaxTokens(&config)
+	config.guessPhrases = loadPhrases()
+	config.prompts = loadPrompts()
+	buildAnswerCheckers(&config)
+	runtimeConfig.Store(&config)
 	log.Printf("Loaded configuration with %d models\n", len(config.Models))
+	for _, model := range config.Models {
+		log.Printf("Model %s (%s): %s\n", model.Name, model.Provider, providers.DescribeProxy(model))
+	}
+	logRequests := config.LogProviderRequests || os.Getenv("LOG_PROVIDER_REQUESTS") == "1"
+	providers.SetRequestLogging(logRequests)
+	if logRequests {
+		log.Println("Logging provider requests, with API keys redacted")
+	}
+	if config.RecordDir != "" {
+		log.Printf("Recording provider calls to %s\n", config.RecordDir)
+	}
+	if config.ReplayDir != "" {
+		log.Printf("WARNING: replaying recorded responses from %s, providers won't be called\n", config.ReplayDir)
+	}
+	if config.CacheResponses {
+		log.Printf("WARNING: response cache enabled, repeated prompts are answered from %s instead of the providers; don't use this for real games\n", responseCacheDir())
+	}
+}
+
+// validateConfig rejects model configs that can never work
+func validateConfig(config *Config) error {
+	for _, model := range config.Models {
+		if err := providers.Validate(model); err != nil {
+			return fmt.Errorf("model %q: %w", model.Name, err)
+		}
+	}
+	return nil
+}
+
+// setDefaultEnabled makes the enabled state explicit so /config always shows it
+func setDefaultEnabled(config *Config) {
+	for i := range config.Models {
+		if config.Models[i].Enabled == nil {
+			enabled := true
+			config.Models[i].Enabled = &enabled
+		}
+	}
 }
 
-func loadStats() {
-	file, err := os.ReadFile(dataDir + "stats.json")
+func (rm *room) loadStats() {
+	file, err := os.ReadFile(rm.dir+"stats.json")
 	if err != nil {
-		stats = Stats{
+		rm.stats = Stats{
 			ByDifficulty: make(map[string]int),
 			ByModel:      make(map[string]ModelStats),
 		}
 		return
 	}
 
-	json.Unmarshal(file, &stats)
-	if stats.ByModel == nil {
-		stats.ByModel = make(map[string]ModelStats)
+	json.Unmarshal(file, &rm.stats)
+	if rm.stats.ByModel == nil {
+		rm.stats.ByModel = make(map[string]ModelStats)
+	}
+	rm.migrateLegacyModelStats()
+}
+
+// migrateLegacyModelStats re-keys stats recorded before instance labels
+// existed (keyed by bare model name) under a "legacy" instance
+func (rm *room) migrateLegacyModelStats() {
+	for key, modelStat := range rm.stats.ByModel {
+		if modelStat.InstanceLabel != "" {
+			continue
+		}
+		delete(rm.stats.ByModel, key)
+		modelStat.InstanceLabel = "legacy"
+		rm.stats.ByModel[key+"@legacy"] = modelStat
 	}
 }
 
-func saveStats() {
-	data, _ := json.MarshalIndent(stats, "", "  ")
-	os.WriteFile(dataDir + "stats.json", data, 0644)
+func (rm *room) saveStats() {
+	data, _ := json.MarshalIndent(rm.stats, "", "  ")
+	os.WriteFile(rm.dir+"stats.json", data, 0644)
 }
 
-func loadLeaderboard() {
-	file, err := os.ReadFile(dataDir + "leaderboard.json")
+func (rm *room) loadLeaderboard() {
+	file, err := os.ReadFile(rm.dir+"leaderboard.json")
 	if err != nil {
-		leaderboard = []LeaderboardEntry{}
+		rm.leaderboard = []LeaderboardEntry{}
 		return
 	}
 
-	json.Unmarshal(file, &leaderboard)
+	json.Unmarshal(file, &rm.leaderboard)
+
+	// Entries from before IDs existed get one so they can be referenced
+	for i := range rm.leaderboard {
+		if rm.leaderboard[i].ID == "" {
+			rm.leaderboard[i].ID = newID()
+		}
+	}
+}
+
+// newID returns a random 16 character hex identifier
+func newID() string {
+	b := make([]byte, 8)
+	crand.Read(b)
+	return hex.EncodeToString(b)
 }
 
-func saveLeaderboard() {
-	data, _ := json.MarshalIndent(leaderboard, "", "  ")
-	os.WriteFile(dataDir + "leaderboard.json", data, 0644)
+func (rm *room) saveLeaderboard() {
+	data, _ := json.MarshalIndent(rm.leaderboard, "", "  ")
+	os.WriteFile(rm.dir+"leaderboard.json", data, 0644)
 }
 
 func calculateScore(result GameResult) int {
@@ -404,53 +804,54 @@ func calculateScore(result GameResult) int {
 	return int(score)
 }
 
-func updateStats(result GameResult) {
+func (rm *room) updateStats(result GameResult) {
 
 log.Println("Updating stats with result:", result)
-statsMux.Lock()
-defer statsMux.Unlock()
+rm.statsMux.Lock()
+defer rm.statsMux.Unlock()
 
-stats.TotalGames++
+rm.stats.TotalGames++
 if result.PlayerWins {
-stats.Wins++
+rm.stats.Wins++
 } else {
-stats.Losses++
+rm.stats.Losses++
 }
 
-if stats.TotalGames > 0 {
-stats.WinRate = float64(stats.Wins) / float64(stats.TotalGames) * 100
+if rm.stats.TotalGames > 0 {
+rm.stats.WinRate = float64(rm.stats.Wins) / float64(rm.stats.TotalGames) * 100
 }
 
-if stats.ByDifficulty == nil {
-stats.ByDifficulty = make(map[string]int)
+if rm.stats.ByDifficulty == nil {
+rm.stats.ByDifficulty = make(map[string]int)
 }
-stats.ByDifficulty[result.Difficulty]++
+rm.stats.ByDifficulty[result.Difficulty]++
 
-stats.TotalDuration += result.Duration
-stats.AverageDuration = stats.TotalDuration / float64(stats.TotalGames)
+rm.stats.TotalDuration += result.Duration
+rm.stats.AverageDuration = rm.stats.TotalDuration / float64(rm.stats.TotalGames)
 
 log.Println("Saving stats")
-saveStats()
+rm.saveStats()
 }
 
-func updateModelStats(game *GameState) {
-	statsMux.Lock()
-	defer statsMux.Unlock()
+func (rm *room) updateModelStats(game *GameState) {
+	rm.statsMux.Lock()
+	defer rm.statsMux.Unlock()
 
 	for _, modelCfg := range game.SelectedModels {
 		if state, exists := game.ModelStates[modelCfg.Name]; exists {
-			modelKey := modelCfg.Name
+			modelKey := modelCfg.StatsKey()
 
-			if stats.ByModel == nil {
-				stats.ByModel = make(map[string]ModelStats)
+			if rm.stats.ByModel == nil {
+				rm.stats.ByModel = make(map[string]ModelStats)
 			}
 
-			modelStat := stats.ByModel[modelKey]
+			modelStat := rm.stats.ByModel[modelKey]
 			if modelStat.Name == "" {
 				// Initialize new model stats
 				modelStat = ModelStats{
-					Name:     modelCfg.Name,
-					Provider: modelCfg.Provider,
+					Name:          modelCfg.Name,
+					Provider:      modelCfg.Provider,
+					InstanceLabel: modelCfg.Instance(),
 				}
 			}
 
@@ -460,23 +861,45 @@ func updateModelStats(game *GameState) {
 				modelStat.TotalGuessesToCorrect += state.GuessesToCorrect
 			}
 			modelStat.TotalResponseTime += state.ResponseTime
+			modelStat.TotalQueueWait += state.QueueWait
+			modelStat.TotalFirstTokenLatency += state.FirstTokenLatency
+			modelStat.LatencyGames++
+			modelStat.TokensUsed.add(state.TokensUsed)
+			modelStat.NearMisses += state.NearMisses
+			modelStat.Truncations += state.Truncations
+			modelStat.RoundTimeouts += state.Timeouts["round"]
+			for _, words := range state.ResponseWordCounts {
+				modelStat.TotalResponseWords += words
+				modelStat.TotalResponses++
+			}
+			for tier, count := range state.Timeouts {
+				if modelStat.TimeoutsByTier == nil {
+					modelStat.TimeoutsByTier = make(map[string]int)
+				}
+				modelStat.TimeoutsByTier[tier] += count
+			}
 
 			if modelStat.GamesPlayed > 0 {
 				modelStat.Accuracy = float64(modelStat.TimesCorrect) / float64(modelStat.GamesPlayed) * 100
 				modelStat.AvgResponseTime = modelStat.TotalResponseTime / float64(modelStat.GamesPlayed)
 			}
+			modelStat.AvgQueueWait = latencyAverage(modelStat.TotalQueueWait, modelStat.LatencyGames)
+			modelStat.AvgFirstTokenLatency = latencyAverage(modelStat.TotalFirstTokenLatency, modelStat.LatencyGames)
+			if modelStat.TotalResponses > 0 {
+				modelStat.AvgResponseWordCount = float64(modelStat.TotalResponseWords) / float64(modelStat.TotalResponses)
+			}
 			if modelStat.TimesCorrect > 0 {
 				modelStat.AvgGuessesToCorrect = float64(modelStat.TotalGuessesToCorrect) / float64(modelStat.TimesCorrect)
 			}
 
-			stats.ByModel[modelKey] = modelStat
+			rm.stats.ByModel[modelKey] = modelStat
 		}
 	}
 
-	saveStats()
+	rm.saveStats()
 }
 
-func addToLeaderboard(game *GameState, result GameResult) {
+func (rm *room) addToLeaderboard(game *GameState, result GameResult) {
 	// Build model details for leaderboard
 	var models []LeaderboardModelEntry
 	for _, modelCfg := range game.SelectedModels {
@@ -490,19 +913,27 @@ func addToLeaderboard(game *GameState, result GameResult) {
 						break
 					}
 				}
+				if !game.ExactMatch {
+					finalGuess = stripDecorations(finalGuess)
+				}
 			}
 
 			models = append(models, LeaderboardModelEntry{
-				Name:         modelCfg.Name,
-				Provider:     modelCfg.Provider,
-				Correct:      state.Correct,
+				Name:          modelCfg.Name,
+				Provider:      modelCfg.Provider,
+				InstanceLabel: modelCfg.Instance(),
+				Correct:       state.Correct,
+				Round:         state.Round,
 				ResponseTime: state.ResponseTime,
 				FinalGuess:   finalGuess,
+				MatchedAnswer: state.MatchedAnswer,
+				Phonetic:      state.Phonetic,
 			})
 		}
 	}
 
 	entry := LeaderboardEntry{
+		ID:           newID(),
 		Riddle:       game.Riddle,
 		Difficulty:   game.Difficulty,
 		Username:     game.Username,
@@ -510,55 +941,119 @@ func addToLeaderboard(game *GameState, result GameResult) {
 		CorrectCount: result.CorrectCount,
 		TotalModels:  result.TotalModels,
 		Duration:     result.Duration,
+		WallDuration: result.WallDuration,
+		RoundsPlayed: result.RoundsPlayed,
 		Timestamp:    result.Timestamp,
 		Score:        calculateScore(result),
+		ScoringVersion: scoringVersion,
 		Models:       models,
+		Summary:      result.Summary,
+		SummaryMarkdown: result.SummaryMarkdown,
+		Tags:         game.Tags,
+		PatternAnswer: game.answerPattern != nil,
+		Synonyms:     game.Synonyms,
+		ChosenModels: game.ChosenModels,
+		InferredTags: keywordTags(game),
 	}
 
-	leaderboardMux.Lock()
-	defer leaderboardMux.Unlock()
-
-	leaderboard = append(leaderboard, entry)
+	rm.leaderboardMux.Lock()
+	rm.leaderboard = append(rm.leaderboard, entry)
+	rm.gamesRecorded++
 
 	// Sort by score descending
-	for i := 0; i < len(leaderboard)-1; i++ {
-		for j := i + 1; j < len(leaderboard); j++ {
-			if leaderboard[j].Score > leaderboard[i].Score {
-				leaderboard[i], leaderboard[j] = leaderboard[j], leaderboard[i]
+	for i := 0; i < len(rm.leaderboard)-1; i++ {
+		for j := i + 1; j < len(rm.leaderboard); j++ {
+			if rm.leaderboard[j].Score > rm.leaderboard[i].Score {
+				rm.leaderboard[i], rm.leaderboard[j] = rm.leaderboard[j], rm.leaderboard[i]
 			}
 		}
 	}
 
-	// Keep top 100
-	if len(leaderboard) > 100 {
-		leaderboard = leaderboard[:100]
+	// Serve the top entries and move the rest to the archive
+	if max := leaderboardCap(); len(rm.leaderboard) > max {
+		rm.archiveEntries(rm.leaderboard[max:])
+		rm.leaderboard = rm.leaderboard[:max]
 	}
 
-	saveLeaderboard()
+	rm.saveLeaderboard()
+	rm.leaderboardMux.Unlock()
+
+	// Tags that need no model are counted with the game, so they can't be
+	// lost to a full tagging queue
+	rm.recordTagStats(append(append([]string{}, game.Tags...), entry.InferredTags...))
+	if taggingWithModel() {
+		enqueueTagJob(tagJob{
+			Room:       rm,
+			EntryID:    entry.ID,
+			Riddle:     game.Riddle,
+			Clues:      game.Clues,
+			AuthorTags: game.Tags,
+		})
+	}
 }
 
 func handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	config := *currentConfig()
+	config.Models = redactedModels(config.Models)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(config)
 }
 
-func handleGetStats(w http.ResponseWriter, r *http.Request) {
-	statsMux.Lock()
-	defer statsMux.Unlock()
+// redactedModels are models as they may be sent to a client, without their
+// credentials, see ModelConfig.Redacted
+func redactedModels(models []ModelConfig) []ModelConfig {
+	redacted := make([]ModelConfig, len(models))
+	for i, model := range models {
+		redacted[i] = model.Redacted()
+	}
+	return redacted
+}
+
+func handleGetStats(w http.ResponseWriter, r *http.Request, rm *room) {
+	rm.statsMux.Lock()
+	defer rm.statsMux.Unlock()
+
+	response := rm.stats
+	response.Instances = make(map[string][]ModelStats)
+	for _, modelStat := range rm.stats.ByModel {
+		response.Instances[modelStat.Name] = append(response.Instances[modelStat.Name], modelStat)
+	}
+	for name := range response.Instances {
+		instances := response.Instances[name]
+		sort.Slice(instances, func(i, j int) bool {
+			return instances[i].InstanceLabel < instances[j].InstanceLabel
+		})
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
+	json.NewEncoder(w).Encode(response)
 }
 
-func handleGetLeaderboard(w http.ResponseWriter, r *http.Request) {
-	leaderboardMux.Lock()
-	defer leaderboardMux.Unlock()
+func handleGetLeaderboard(w http.ResponseWriter, r *http.Request, rm *room) {
+	rm.leaderboardMux.Lock()
+	defer rm.leaderboardMux.Unlock()
+
+	visible := []LeaderboardEntry{}
+	for _, entry := range rm.leaderboard {
+		if !entry.Hidden {
+			visible = append(visible, entry)
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(leaderboard)
+	json.NewEncoder(w).Encode(visible)
 }
 
-func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+// errClientGone ends a connection's context when its client disconnects
+var errClientGone = errors.New("client disconnected")
+
+func handleWebSocket(w http.ResponseWriter, r *http.Request, rm *room) {
+	caps := capabilitiesFrom(r.Context())
+	if !caps.Has(CapPlay) {
+		http.Error(w, "origin not allowed to play", http.StatusForbidden)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("Upgrade error:", err)
@@ -566,28 +1061,128 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	for {
-		var submission RiddleSubmission
-		err := conn.ReadJSON(&submission)
+	// ctx ends when the client goes away, cancelling the provider calls of a
+	// game in progress. Reading carries on during games so a closed tab is
+	// noticed straight away; a riddle sent mid-game waits for the game to end.
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	c := newClient(conn, caps)
+	c.ctx = ctx
+
+	submissions := make(chan RiddleSubmission, 1)
+	go func() {
+		defer close(submissions)
+		for {
+			var submission RiddleSubmission
+			if err := conn.ReadJSON(&submission); err != nil {
+				log.Println("Read error:", err)
+				cancel(errClientGone)
+				return
+			}
+			select {
+			case submissions <- submission:
+			default:
+				log.Println("Ignoring riddle submitted while another is waiting")
+			}
+		}
+	}()
+
+	for submission := range submissions {
+		accepted := submission.acceptedAnswers()
+		if len(accepted) == 0 {
+			c.WriteJSON(map[string]interface{}{
+				"type":    "error",
+				"code":    "missingAnswer",
+				"message": "A riddle needs at least one answer.",
+			})
+			continue
+		}
+		pattern, err := answers.CompilePattern(submission.AnswerPattern)
+		if err != nil {
+			c.WriteJSON(map[string]interface{}{
+				"type":    "error",
+				"code":    "invalidAnswerPattern",
+				"field":   "answerPattern",
+				"message": "Invalid answer pattern: " + err.Error(),
+			})
+			continue
+		}
+		lang, err := parseLanguage(submission.Language)
+		if err != nil {
+			c.WriteJSON(map[string]interface{}{
+				"type":    "error",
+				"code":    "invalidLanguage",
+				"field":   "language",
+				"message": "Invalid language: " + err.Error(),
+			})
+			continue
+		}
+		if !hasLocalizedPrompts(lang.String()) {
+			log.Printf("WARNING: no prompt templates for %s riddles, prompting in English\n", lang)
+		}
+		synonyms, err := checkSynonyms(submission.Synonyms, lang)
 		if err != nil {
-			log.Println("Read error:", err)
-			break
+			c.WriteJSON(map[string]interface{}{
+				"type":    "error",
+				"code":    "invalidSynonyms",
+				"field":   "synonyms",
+				"message": "Invalid synonyms: " + err.Error(),
+			})
+			continue
+		}
+		// The pick is checked against the same config snapshot the models are
+		// then selected from, so a reload in between can't change what plays
+		config := currentConfig()
+		if unknown := unknownModels(config, submission.Models); len(unknown) > 0 {
+			c.WriteJSON(map[string]interface{}{
+				"type":    "error",
+				"code":    "unknownModels",
+				"field":   "models",
+				"models":  unknown,
+				"message": "Unknown or disabled models: " + strings.Join(unknown, ", "),
+			})
+			continue
+		}
+		if limit := maxChosenModels(config); len(distinctNames(submission.Models)) > limit {
+			c.WriteJSON(map[string]interface{}{
+				"type":    "error",
+				"code":    "tooManyModels",
+				"field":   "models",
+				"limit":   limit,
+				"message": fmt.Sprintf("Choose at most %d models.", limit),
+			})
+			continue
+		}
+		// Not an error, but the author should know "O" won't be found in "oval"
+		if pattern == nil && !submission.ExactMatch {
+			for _, answer := range accepted {
+				if answers.Strict(answer, lang) {
+					c.WriteJSON(map[string]interface{}{
+						"type":    "warning",
+						"code":    "strictAnswer",
+						"field":   "answers",
+						"message": fmt.Sprintf("%q is only one or two characters, so it is matched only when it is the whole guess, such as %q or \"the letter %s\".", answer, answer, answer),
+					})
+				}
+			}
 		}
 
 		gamesMux.Lock()
 
-		// Randomly select 3 models from config (or all if fewer than 3)
-		selectedModels := config.Models
-		if len(config.Models) > 3 {
-			// Shuffle the models and take first 3
-			shuffled := make([]ModelConfig, len(config.Models))
-			copy(shuffled, config.Models)
-			rand.Shuffle(len(shuffled), func(i, j int) {
-				shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		// Every GameState holds full guess histories, so the number running at
+		// once is capped to bound memory
+		if capacity := gameCapacityLocked(); capacity.AtCapacity {
+			gamesMux.Unlock()
+			log.Printf("Rejecting new game, %d games active\n", capacity.ActiveGames)
+			c.WriteJSON(map[string]interface{}{
+				"type":    "error",
+				"message": "The server is at capacity. Please try again in a few minutes.",
 			})
-			selectedModels = shuffled[:3]
+			continue
 		}
 
+		selectedModels, selectionTrace := selectModels(config, submission.Models)
+
 		modelStates := make(map[string]ModelState)
 		for _, model := range selectedModels {
 			modelStates[model.Name] = ModelState{GuessCount: 0}
@@ -595,7 +1190,13 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 		game := &GameState{
 			Riddle:       submission.Riddle,
-			Answer:       submission.Answer,
+			Answer:       accepted[0],
+			Answers:      accepted,
+			AnswerPattern: submission.AnswerPattern,
+			Synonyms:     synonyms,
+			Language:     lang.String(),
+			ExactMatch:   submission.ExactMatch,
+			SharedGuesses: submission.SharedGuesses,
 			Clues:        submission.Clues,
 			Difficulty:   submission.Difficulty,
 			CurrentRound: 0,
@@ -603,6 +1204,13 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 			StartTime:    time.Now(),
 			Username:     submission.Username,
 			SelectedModels: selectedModels,
+			Tags:           submission.Tags,
+			Room:           rm.name,
+			Commentary:     submission.Commentary == nil || *submission.Commentary,
+			ChosenModels:   len(submission.Models) > 0,
+			room:           rm,
+			answerPattern:  pattern,
+			language:       lang,
 		}
 		games[conn] = game
 		gamesMux.Unlock()
@@ -610,11 +1218,22 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		// Send game start message with selected models
 		startMsg := map[string]interface{}{
 			"type":          "gameStart",
-			"selectedModels": selectedModels,
+			"selectedModels": redactedModels(selectedModels),
+		}
+		if c.caps.Has(CapExtended) {
+			startMsg["selectionTrace"] = selectionTrace
 		}
-		conn.WriteJSON(startMsg)
+		if game.SharedGuesses {
+			startMsg["sharedGuesses"] = true
+		}
+		c.WriteJSON(startMsg)
+
+		playRound(ctx, c, game)
 
-		playRound(conn, game)
+		// Finished games stop counting against the ceiling straight away
+		gamesMux.Lock()
+		delete(games, conn)
+		gamesMux.Unlock()
 	}
 
 	gamesMux.Lock()
@@ -622,18 +1241,112 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	gamesMux.Unlock()
 }
 
-// Add this debugging code to cmd/server/main.go in the playRound function
-// Right after checking results, add:
+// selectModels picks a game's models from one config snapshot: the player's
+// chosen ones, or 3 enabled models at random (or all if fewer than 3). The
+// trace describes how they were picked.
+func selectModels(config *Config, chosen []string) ([]ModelConfig, map[string]interface{}) {
+	// Instances of the same model share a display name, and games track
+	// models by name, so one random instance of each name joins the pool
+	var names []string
+	instances := make(map[string][]ModelConfig)
+	for _, model := range config.Models {
+		if !model.IsEnabled() {
+			continue
+		}
+		if _, exists := instances[model.Name]; !exists {
+			names = append(names, model.Name)
+		}
+		instances[model.Name] = append(instances[model.Name], model)
+	}
 
-func playRound(conn *websocket.Conn, game *GameState) {
-	// Send round start message
-	conn.WriteJSON(map[string]interface{}{
-		"type":  "roundStart",
-		"round": game.CurrentRound,
-	})
+	var pool []ModelConfig
+	for _, name := range names {
+		pool = append(pool, instances[name][rand.Intn(len(instances[name]))])
+	}
+
+	selectedModels := pool
+	selectionTrace := map[string]interface{}{
+		"method":   "all",
+		"poolSize": len(pool),
+	}
+	if len(chosen) > 0 {
+		// The player's own pick, each named model once
+		selectionTrace["method"] = "chosen"
+		selectedModels = nil
+		for _, model := range pool {
+			if slices.Contains(chosen, model.Name) {
+				selectedModels = append(selectedModels, model)
+			}
+		}
+	} else if len(pool) > 3 {
+		selectionTrace["method"] = "random"
+		// Shuffle the models and take first 3
+		shuffled := make([]ModelConfig, len(pool))
+		copy(shuffled, pool)
+		rand.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		selectedModels = shuffled[:3]
+	}
+	return selectedModels, selectionTrace
+}
+
+// unknownModels are the names in a player's pick of models that aren't
+// enabled models in config
+func unknownModels(config *Config, names []string) []string {
+	enabled := make(map[string]bool)
+	for _, model := range config.Models {
+		if model.IsEnabled() {
+			enabled[model.Name] = true
+		}
+	}
+	var unknown []string
+	for _, name := range names {
+		if !enabled[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
 
+// defaultMaxChosenModels is how many models a player may pick for one game
+// unless maxChosenModels is set
+const defaultMaxChosenModels = 6
+
+// maxChosenModels is the most models a player may pick for one game. Every
+// chosen model is called each round, so the pick is bounded like the random
+// draw is.
+func maxChosenModels(config *Config) int {
+	if config.MaxChosenModels > 0 {
+		return config.MaxChosenModels
+	}
+	return defaultMaxChosenModels
+}
+
+// distinctNames is names without repeats, in order
+func distinctNames(names []string) []string {
+	var distinct []string
+	for _, name := range names {
+		if !slices.Contains(distinct, name) {
+			distinct = append(distinct, name)
+		}
+	}
+	return distinct
+}
+
+// runModels has each of models that hasn't answered correctly yet take its
+// turn this round, in parallel, and waits for all of them
+func runModels(ctx context.Context, c *client, game *GameState, models []ModelConfig) {
+	// Models still answering at the round's deadline are cut off, so one slow
+	// model doesn't hold up the rest
+	if deadline := roundDeadline(); deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, deadline, ErrRoundTimeout)
+		defer cancel()
+	}
+	snapshotSharedGuesses(game)
 	var wg sync.WaitGroup
-	for _, modelCfg := range game.SelectedModels {
+	for _, modelCfg := range models {
 		// Skip models that are already correct
 		if game.ModelStates[modelCfg.Name].Correct {
 			continue
@@ -642,12 +1355,43 @@ func playRound(conn *websocket.Conn, game *GameState) {
 		wg.Add(1)
 		go func(cfg ModelConfig) {
 			defer wg.Done()
-			prompt := buildPrompt(game, cfg.Name)
-			streamModelResponse(conn, cfg, prompt, game)
+			// Other models' guesses go into the prompt while they are answering
+			gamesMux.Lock()
+			prompt, promptTruncated := buildPrompt(game, cfg)
+			gamesMux.Unlock()
+			streamModelResponse(ctx, c, cfg, prompt, promptTruncated, game)
 		}(modelCfg)
 	}
-
 	wg.Wait()
+}
+
+// Add this debugging code to cmd/server/main.go in the playRound function
+// Right after checking results, add:
+
+// playRound plays the game's current round and any after it. A game whose
+// client disconnects stops at the end of the round and isn't recorded.
+func playRound(ctx context.Context, c *client, game *GameState) {
+	// Send round start message
+	c.WriteJSON(map[string]interface{}{
+		"type":  "roundStart",
+		"round": game.CurrentRound,
+	})
+
+	roundStart := time.Now()
+	runModels(ctx, c, game, game.SelectedModels)
+	// A model that can't be reached at all is swapped for a fallback before
+	// the game gets going, so it isn't scored as a model that was stumped
+	if game.CurrentRound == 0 && ctx.Err() == nil {
+		for substitutes := replaceFailedModels(c, game); len(substitutes) > 0; substitutes = replaceFailedModels(c, game) {
+			runModels(ctx, c, game, substitutes)
+		}
+	}
+	if ctx.Err() != nil {
+		log.Printf("Game by %s abandoned in round %d, not recording it: %v\n", game.Username, game.CurrentRound, context.Cause(ctx))
+		return
+	}
+	game.ActivitySeconds += time.Since(roundStart).Seconds()
+	startCommentary(c, game)
 
 	// Check results
 	correctCount := 0
@@ -685,7 +1429,7 @@ func playRound(conn *websocket.Conn, game *GameState) {
 		"allCorrect":     allCorrect,
 		"someCorrect":    someCorrect,
 		"cluesExhausted": cluesExhausted,
-		"modelStates":    game.ModelStates,
+		"modelStates":    c.visibleModelStates(game.ModelStates),
 	}
 
 	// Game ends if all models correct OR all clues exhausted
@@ -698,14 +1442,31 @@ func playRound(conn *websocket.Conn, game *GameState) {
 			CorrectCount: correctCount,
 			TotalModels:  totalModels,
 			Difficulty:   game.Difficulty,
-			Duration:     duration,
+			Duration:     game.ActivitySeconds,
+			WallDuration: duration,
 			RoundsPlayed: game.CurrentRound + 1,
 			Timestamp:    time.Now(),
 			Username:     game.Username,
 		}
+		tokensByModel := make(map[string]TokensUsed, len(game.ModelStates))
+		matchedAnswers := make(map[string]string)
+		for name, state := range game.ModelStates {
+			tokensByModel[name] = state.TokensUsed
+			gameResult.TokensUsed.add(state.TokensUsed)
+			if state.Correct {
+				matchedAnswers[name] = state.MatchedAnswer
+			}
+		}
 
 		log.Printf("GAME FINISHED - Player Wins: %v\n", gameResult.PlayerWins)
 
+		// Ranking reads the leaderboard and archive under their own locks
+		rank := game.room.rankToday(calculateScore(gameResult), gameResult.Timestamp)
+		gamesMux.Lock()
+		summary := newGameSummary(game, gameResult, rank)
+		gamesMux.Unlock()
+		gameResult.Summary, gameResult.SummaryMarkdown = renderSummaries(summary)
+
 
 		// Send game finished message with all result data
 		finishedMsg := map[string]interface{}{
@@ -714,8 +1475,15 @@ func playRound(conn *websocket.Conn, game *GameState) {
 			"correctCount": correctCount,
 			"totalModels":  totalModels,
 			"duration":     duration,
+			"activityDuration": game.ActivitySeconds,
 			"score":        calculateScore(gameResult),
-			"modelStates":  game.ModelStates,
+			"modelStates":  c.visibleModelStates(game.ModelStates),
+			"summary":      gameResult.Summary,
+			"summaryMarkdown": gameResult.SummaryMarkdown,
+			"tokensUsed":   tokensByModel,
+			"totalTokensUsed": gameResult.TokensUsed,
+			"matchedAnswers": matchedAnswers,
+			"matching":     matchingFor(game.Difficulty),
 		}
 
 		// Add result message
@@ -731,19 +1499,19 @@ func playRound(conn *websocket.Conn, game *GameState) {
 
 		log.Println("Sending gameFinished message")
 		// Small delay so users can see the final results
-		time.Sleep(2 * time.Second)
-		conn.WriteJSON(finishedMsg)
+		c.pause(2 * time.Second)
+		c.WriteJSON(finishedMsg)
 		
 		log.Println("Updating stats and leaderboard")
-		updateStats(gameResult)
-		updateModelStats(game)
-	addToLeaderboard(game, gameResult)
+		game.room.updateStats(gameResult)
+		game.room.updateModelStats(game)
+		game.room.addToLeaderboard(game, gameResult)
 
 		result["gameOver"] = true
 		log.Print("Stats and leaderboard updated")
 
 		// Pause before ending
-		time.Sleep(1500 * time.Millisecond)
+		c.pause(1500 * time.Millisecond)
 
 		return // End the game, don't continue
 	} else {
@@ -752,413 +1520,659 @@ func playRound(conn *websocket.Conn, game *GameState) {
 		result["nextRound"] = game.CurrentRound
 	}
 
-	conn.WriteJSON(result)
+	c.WriteJSON(result)
 
-	time.Sleep(1500 * time.Millisecond)
-	playRound(conn, game)
+	c.pause(1500 * time.Millisecond)
+	playRound(ctx, c, game)
 }
 
-func buildPrompt(game *GameState, modelName string) string {
-	prompt := fmt.Sprintf("Answer this riddle with just the answer (one or two words maximum):\n\n%s", game.Riddle)
-
-	if game.CurrentRound > 0 && game.CurrentRound <= len(game.Clues) {
-		cluesGiven := strings.Join(game.Clues[:game.CurrentRound], "\n")
-		prompt = fmt.Sprintf("%s\n\nClues:\n%s\n\nProvide only the answer.", prompt, cluesGiven)
+// pause holds the game for players to take in what was just sent. Nobody is
+// watching a headless game, such as a benchmark's, so it doesn't wait.
+func (c *client) pause(d time.Duration) {
+	if c.conn != nil {
+		time.Sleep(d)
 	}
+}
 
-	// Add history of incorrect guesses for this model
-	state := game.ModelStates[modelName]
-	var incorrectGuesses []string
-	for i, guess := range state.AllGuesses {
-		if !state.GuessResults[i] && strings.TrimSpace(guess) != "" {
-			incorrectGuesses = append(incorrectGuesses, guess)
-		}
-	}
-	if len(incorrectGuesses) > 0 {
-		prompt += fmt.Sprintf("\n\nDo not repeat these previous incorrect guesses: %s", strings.Join(incorrectGuesses, ", "))
+// usesChatHistory reports whether a model is sent the game as a conversation
+func usesChatHistory(modelCfg ModelConfig) bool {
+	return modelCfg.Provider == "ollama" && !modelCfg.LegacyGenerate
+}
+
+// buildChatTurn is this round's user turn in a conversation, from the chat
+// template. Later rounds repeat every clue so far in case an earlier turn got
+// no reply. It reports whether the turn was cut to fit the model's context
+// budget beside history.
+func buildChatTurn(game *GameState, modelCfg ModelConfig, history []ChatMessage) (string, bool) {
+	used := 0
+	for _, message := range history {
+		used += estimateTokens(message.Content)
 	}
+	return renderPrompt(chatTemplate, game, modelCfg, used)
+}
 
-	return prompt
+// buildPrompt is the whole prompt for this round, from the prompt template. It
+// reports whether the prompt was cut to fit the model's context budget.
+func buildPrompt(game *GameState, modelCfg ModelConfig) (string, bool) {
+	return renderPrompt(promptTemplate, game, modelCfg, 0)
 }
 
-func streamModelResponse(conn *websocket.Conn, modelCfg ModelConfig, prompt string, game *GameState) {
-	startTime := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
+func streamModelResponse(gameCtx context.Context, c *client, modelCfg ModelConfig, prompt string, promptTruncated bool, game *GameState) {
+	queuedAt := time.Now()
 
-	var response string
-	var err error
-
-	switch modelCfg.Provider {
-	case "openai":
-		response, err = streamOpenAI(ctx, conn, modelCfg, prompt)
-	case "anthropic":
-		response, err = streamAnthropic(ctx, conn, modelCfg, prompt)
-	case "google":
-		response, err = streamGoogle(ctx, conn, modelCfg, prompt)
-	case "ollama":
-		response, err = streamOllama(ctx, conn, modelCfg, prompt)
-	case "huggingface":
-		response, err = streamHuggingFace(ctx, conn, modelCfg, prompt)
-	default:
-		err = fmt.Errorf("unknown provider: %s", modelCfg.Provider)
+	// Chat-capable providers get the game as a conversation rather than one
+	// flattened prompt
+	var messages []ChatMessage
+	if usesChatHistory(modelCfg) {
+		gamesMux.Lock()
+		history := game.ModelStates[modelCfg.Name].Messages
+		if len(history) == 0 {
+			history = fewShotTurns(game, modelCfg)
+		}
+		var turn string
+		turn, promptTruncated = buildChatTurn(game, modelCfg, history)
+		messages = append(append([]ChatMessage{}, history...), ChatMessage{Role: "user", Content: turn})
+		gamesMux.Unlock()
+	}
+	if modelCfg.StructuredAnswers {
+		prompt += structuredAnswerInstruction
+		if len(messages) > 0 {
+			messages[len(messages)-1].Content += structuredAnswerInstruction
+		}
 	}
+	timeouts := timeoutsFor(modelCfg.Provider)
+	reasoning := providers.IsOpenAIReasoningModel(modelCfg)
+	if modelCfg.TimeoutSeconds > 0 {
+		timeouts.TotalSeconds = float64(modelCfg.TimeoutSeconds)
+	} else if reasoning {
+		timeouts.TotalSeconds *= 3
+	}
+	if reasoning {
+		// Reasoning models think silently before answering all at once
+		timeouts.FirstTokenSeconds = timeouts.TotalSeconds
+	}
+	ctx, cancel := context.WithTimeoutCause(gameCtx, seconds(timeouts.TotalSeconds), ErrTotalTimeout)
+	defer cancel()
 
-	responseTime := time.Since(startTime).Seconds()
+	// The provider timer only starts once a call slot is held, so time spent
+	// queueing behind other calls isn't blamed on the provider
+	sendProgress(c, "modelQueued", modelCfg.Name, queuedAt)
 
-	// Trim and validate response
-	response = strings.TrimSpace(response)
+	var response string
+	var simulated bool
+	var firstByteAt time.Time
+	var tokensUsed TokensUsed
+	var release func()
+	err := ErrModelUnavailable
+	called := allowCall(modelCfg.Name)
+	if called {
+		release, err = acquireCallSlot(ctx, c, modelCfg)
+	}
+	queueWait := time.Since(queuedAt).Seconds()
+	startTime := time.Now()
+	attempts := 0
+	if err == nil {
+		// Each attempt gets its own connect and first-token deadlines; all of
+		// them share the total one
+		response, simulated, attempts, err = callWithRetry(ctx, modelCfg, retriesFor(modelCfg.Provider), func(ctx context.Context) (string, bool, error) {
+			// The first attempt already waited in acquireCallSlot; retries count
+			// against the provider's rate limit too
+			if attempts++; attempts > 1 {
+				if err := waitForRateLimit(ctx, modelCfg.Provider); err != nil {
+					return "", true, err
+				}
+			}
+			callCtx, stopDeadlines := withTieredDeadlines(ctx, modelCfg, timeouts)
+			defer stopDeadlines()
+			traceCtx := withProgressTrace(callCtx, c, modelCfg.Name, func(at time.Time) {
+				firstByteAt = at
+			})
+			response, simulated, err := callProvider(withAnswer(withTokenCount(traceCtx, &tokensUsed), game.Answer), c, modelCfg, prompt, messages)
+			if err != nil {
+				if cause := context.Cause(callCtx); timeoutTier(cause) != "" {
+					err = fmt.Errorf("%w: %v", cause, err)
+				}
+			}
+			return response, simulated, err
+		})
+		release()
+	}
+	tier := timeoutTier(err)
+	outcome := providers.OutcomeOf(err)
+
+	// A model still answering when the round ended sits the round out, with
+	// no guess recorded
+	roundTimedOut := tier == "round"
+	if roundTimedOut {
+		outcome = outcomeTimedOut
+	}
 
-	var isCorrect bool
-	if err != nil || response == "" {
-		log.Printf("Error streaming from %s: %v\n", modelCfg.Name, err)
-		isCorrect = false
-		response = ""
-	} else {
-		isCorrect = checkAnswer(response, game.Answer)
+	// A cut-off guess is still checked, but flagged so a wrong one can be told
+	// apart from a model that finished and was simply wrong
+	truncated := errors.Is(err, providers.ErrResponseTruncated)
+	if truncated {
+		err = nil
+	}
+	// A call cut short by the client leaving or the round's deadline says
+	// nothing about the model's health
+	if called && gameCtx.Err() == nil {
+		recordCall(modelCfg.Name, err)
 	}
 
-	gamesMux.Lock()
-	state := game.ModelStates[modelCfg.Name]
-	state.Guess = response
-	state.GuessCount++
-	state.ResponseTime = responseTime
+	// Measured before any simulated streaming so it reflects provider latency only
+	responseTime := time.Since(startTime).Seconds()
 
-	if isCorrect && !state.Correct {
-		state.Correct = true
-		state.Round = game.CurrentRound + 1
-		state.GuessesToCorrect = state.GuessCount
+	if simulated && err == nil {
+		simulateStream(ctx, c, modelCfg.Name, response)
 	}
 
-	// Add to history only if response is not empty
-	if response != "" {
-		state.AllGuesses = append(state.AllGuesses, response)
-		state.GuessResults = append(state.GuessResults, isCorrect)
-		state.ResponseTimes = append(state.ResponseTimes, responseTime)
+	// A structured reply is checked on its answer field alone, or as plain text
+	// if it didn't come back as the JSON asked for
+	var confidence *float64
+	if err == nil && modelCfg.StructuredAnswers {
+		if answer, c, ok := parseStructuredAnswer(response); ok {
+			response, confidence = answer, c
+		} else {
+			log.Printf("Structured answer from %s isn't valid JSON, checking it as text\n", modelCfg.Name)
+		}
 	}
 
-	game.ModelStates[modelCfg.Name] = state
-	gamesMux.Unlock()
-
-	// Only send result if no error (successful response)
-	if err == nil && response != "" {
-		resultMsg := StreamMessage{
-			Model:   modelCfg.Name,
-			Content: fmt.Sprintf("%v", isCorrect),
-			Done:    true,
-			Type:    "result",
+	// A rambling reply gets one follow-up asking for just the answer, and the
+	// follow-up is what gets checked. The reply stays in the guess history.
+	var reply string
+	if err == nil && needsFollowUp(response) && !isRefusal(response) {
+		followUp, followUpMessages, followUpTime, followUpErr := askForAnswerOnly(ctx, c, modelCfg, timeouts, prompt, messages, response, game.Answer, &tokensUsed)
+		responseTime += followUpTime
+		if followUpErr != nil {
+			log.Printf("Follow-up to %s failed, checking its first reply: %v\n", modelCfg.Name, followUpErr)
+		} else if strings.TrimSpace(followUp) != "" {
+			reply, response, messages = strings.TrimSpace(response), followUp, followUpMessages
+			if answer, c, ok := parseStructuredAnswer(followUp); ok && modelCfg.StructuredAnswers {
+				response, confidence = answer, c
+			}
 		}
-		conn.WriteJSON(resultMsg)
 	}
-}
 
-func streamOpenAI(ctx context.Context, conn *websocket.Conn, cfg ModelConfig, prompt string) (string, error) {
-	reqBody := OpenAIRequest{
-		Model: cfg.Model,
-		Messages: []OpenAIMessage{
-			{Role: "user", Content: prompt},
-		},
-		Stream: true,
+	// Clean up the provider's formatting and validate response
+	response = sanitizeResponse(modelCfg.Provider, response)
+	if !game.ExactMatch {
+		response = stripDecorations(response)
 	}
 
-	body, _ := json.Marshal(reqBody)
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewReader(body))
-	if err != nil {
-		return "", err
+	// How long the model's own answer ran, before a follow-up cut it short
+	wordCount := len(strings.Fields(response))
+	if reply != "" {
+		wordCount = len(strings.Fields(reply))
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		wordCount = 0
 	}
-	defer resp.Body.Close()
 
-	var fullResponse strings.Builder
-	scanner := bufio.NewScanner(resp.Body)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
+	// A wordy reply is checked on the answer picked out of it, not on every
+	// word it happens to contain
+	var match AnswerMatch
+	var candidate string
+	var restated, skipped bool
+	if err != nil || response == "" {
+		log.Printf("Error streaming from %s: %v\n", modelCfg.Name, err)
+		response = ""
+	} else {
+		candidate = response
+		if matchingFor(game.Difficulty).ExtractAnswers {
+			candidate = extractAnswer(response)
 		}
+		match = checkAnswer(candidate, game)
 
-		data := strings.TrimPrefix(line, "data: ")
-		if data == "[DONE]" {
-			break
+		// An answer given away by the riddle itself doesn't count when the
+		// guess only quotes it
+		if match.Correct && answerChecker(game.Difficulty).Restates(candidate, game.answerRiddle()) {
+			match, restated = AnswerMatch{}, true
 		}
 
-		var streamResp OpenAIStreamResponse
-		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
-			continue
+		// Declining to guess is a pass, not a wrong answer
+		if !match.Correct && isRefusal(response) {
+			skipped = true
+			outcome = outcomeSkipped
 		}
+	}
 
-		if len(streamResp.Choices) > 0 {
-			content := streamResp.Choices[0].Delta.Content
-			fullResponse.WriteString(content)
-
-			msg := StreamMessage{
-				Model:   cfg.Name,
-				Content: content,
-				Done:    false,
-				Type:    "guess",
-			}
-			conn.WriteJSON(msg)
+	// What string matching rejects can still be overruled by the judge model
+	var ruling *JudgeRuling
+	if response != "" && !match.Correct && !restated && !skipped {
+		if ruling = judgeAnswer(gameCtx, game, candidate); ruling != nil && ruling.Verdict == "yes" {
+			match = AnswerMatch{Match: answers.Match{Correct: true, Answer: game.Answer}, Judged: true}
 		}
 	}
+	isCorrect := match.Correct
 
-	return fullResponse.String(), nil
-}
+	// A near miss is only for show, it never changes the verdict
+	nearMiss := !isCorrect && (match.NearMiss || ruling != nil && ruling.Verdict == "close")
 
-func streamAnthropic(ctx context.Context, conn *websocket.Conn, cfg ModelConfig, prompt string) (string, error) {
-	reqBody := AnthropicRequest{
-		Model: cfg.Model,
-		Messages: []AnthropicMessage{
-			{Role: "user", Content: prompt},
-		},
-		MaxTokens: 1024,
-		Stream:    true,
+	// Correctness is judged on the raw guess; only the stored and displayed form is moderated
+	display, moderation, keep := moderateGuess(ctx, response)
+	// The ruling is sent with the model's state, so it can't show what moderation hid
+	if ruling != nil && display != response {
+		ruling.Candidate = display
+	}
+	var replyDisplay string
+	var replyModeration *ModerationDecision
+	replyKeep := false
+	if reply != "" {
+		replyDisplay, replyModeration, replyKeep = moderateGuess(ctx, reply)
 	}
 
-	body, _ := json.Marshal(reqBody)
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewReader(body))
+	gamesMux.Lock()
+	state := game.ModelStates[modelCfg.Name]
+	state.Guess = display
+	state.Moderation = moderation
+	state.Candidate = ""
+	if candidate != response && display == response {
+		state.Candidate = candidate
+	}
+	if !skipped && !roundTimedOut {
+		state.GuessCount++
+	}
+	state.Error = ""
+	state.ErrorCategory = ""
 	if err != nil {
-		return "", err
+		state.Error = err.Error()
+		switch {
+		case errors.Is(err, ErrRateLimited):
+			state.ErrorCategory = "rateLimited"
+		case errors.Is(err, ErrModelUnavailable):
+			state.ErrorCategory = "unavailable"
+		case errors.Is(err, providers.ErrContentFiltered):
+			state.ErrorCategory = "filtered"
+		case errors.Is(err, providers.ErrStreamStalled):
+			state.ErrorCategory = "stalled"
+		default:
+			state.ErrorCategory = "provider"
+		}
+	}
+	state.Truncated = truncated
+	if truncated {
+		state.Truncations++
+	}
+	state.TimedOut = tier != ""
+	state.Unavailable = !called
+	state.Outcome = outcome
+	state.FollowedUp = reply != ""
+	state.PromptTruncated = promptTruncated
+	state.Confidence = confidence
+	state.Fuzzy = match.Fuzzy
+	state.Restated = restated
+	if nearMiss {
+		state.NearMisses++
+	}
+	if ruling != nil {
+		state.JudgeRulings = append(state.JudgeRulings, *ruling)
+	}
+	if truncated && !isCorrect {
+		state.ErrorCategory = "truncated"
+	}
+	if tier != "" {
+		state.ErrorCategory = "timeout:" + tier
+		if state.Timeouts == nil {
+			state.Timeouts = make(map[string]int)
+		}
+		state.Timeouts[tier]++
+	}
+	state.ResponseTime = responseTime
+	state.ResponseWordCount = wordCount
+	if wordCount > 0 {
+		state.ResponseWordCounts = append(state.ResponseWordCounts, wordCount)
+	}
+	state.TokensUsed.add(tokensUsed)
+	state.Attempts = attempts
+	state.QueueWait = queueWait
+	state.FirstTokenLatency = 0
+	if !firstByteAt.IsZero() {
+		state.FirstTokenLatency = firstByteAt.Sub(startTime).Seconds()
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", cfg.APIKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	if isCorrect && !state.Correct {
+		state.Correct = true
+		state.MatchedAnswer = match.Answer
+		state.Phonetic = match.Phonetic
+		state.Round = game.CurrentRound + 1
+		state.GuessesToCorrect = state.GuessCount
+	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+	// The conversation keeps the raw reply, it is never shown to clients
+	if response != "" && len(messages) > 0 {
+		state.Messages = append(messages, ChatMessage{Role: "assistant", Content: response})
 	}
-	defer resp.Body.Close()
 
-	var fullResponse strings.Builder
-	scanner := bufio.NewScanner(resp.Body)
+	// The rambling reply is never judged, so it goes in as not correct
+	if replyKeep {
+		state.AllGuesses = append(state.AllGuesses, replyDisplay)
+		state.GuessModeration = append(state.GuessModeration, replyModeration)
+		state.GuessResults = append(state.GuessResults, false)
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
+	// Add to history only if response is not empty and survived moderation. A
+	// pass isn't a guess, so it isn't fed back as one to avoid.
+	if response != "" && keep && !skipped {
+		state.AllGuesses = append(state.AllGuesses, display)
+		state.GuessModeration = append(state.GuessModeration, moderation)
+		state.GuessResults = append(state.GuessResults, isCorrect)
+		state.ResponseTimes = append(state.ResponseTimes, responseTime)
+		state.QueueWaits = append(state.QueueWaits, queueWait)
+	}
 
-		data := strings.TrimPrefix(line, "data: ")
+	game.ModelStates[modelCfg.Name] = state
+	errorCategory := state.ErrorCategory
+	gamesMux.Unlock()
 
-		var streamResp AnthropicStreamResponse
-		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
-			continue
+	// A failed model shows an error instead of staying blank. The provider's
+	// message is only sent to origins allowed to see error details.
+	if err != nil {
+		errorMsg := StreamMessage{
+			Model:   modelCfg.Name,
+			Content: errorCategory,
+			Done:    true,
+			Type:    "error",
+			Outcome: outcome,
+		}
+		if c.caps.Has(CapExtended) {
+			errorMsg.Content = err.Error()
 		}
+		c.WriteJSON(errorMsg)
+	}
 
-		if streamResp.Type == "content_block_delta" && streamResp.Delta.Type == "text_delta" {
-			content := streamResp.Delta.Text
-			fullResponse.WriteString(content)
+	// A timeout still settles the model's card for the round
+	if tier != "" {
+		c.WriteJSON(StreamMessage{
+			Model:    modelCfg.Name,
+			Content:  "false",
+			Done:     true,
+			Type:     "result",
+			TimedOut: true,
+			Outcome:  outcome,
+		})
+	}
 
-			msg := StreamMessage{
-				Model:   cfg.Name,
-				Content: content,
-				Done:    false,
-				Type:    "guess",
-			}
-			conn.WriteJSON(msg)
+	// Only send result if no error (successful response). An empty reply
+	// still settles the card, as a wrong guess.
+	if err == nil {
+		resultMsg := StreamMessage{
+			Model:   modelCfg.Name,
+			Content: fmt.Sprintf("%v", isCorrect),
+			Done:    true,
+			Type:    "result",
+			Outcome: outcome,
+			Fuzzy:   match.Fuzzy,
+			Phonetic: match.Phonetic,
+			Judged:  match.Judged,
+			Restated: restated,
+		}
+		c.WriteJSON(resultMsg)
+
+		if nearMiss {
+			c.WriteJSON(StreamMessage{
+				Model:      modelCfg.Name,
+				Done:       true,
+				Type:       "nearmiss",
+				Similarity: match.Similarity,
+			})
 		}
 	}
-
-	return fullResponse.String(), nil
 }
 
-func streamGoogle(ctx context.Context, conn *websocket.Conn, cfg ModelConfig, prompt string) (string, error) {
-	reqBody := GeminiRequest{
-		Contents: []GeminiContent{
-			{
-				Parts: []GeminiPart{
-					{Text: prompt},
-				},
-			},
-		},
+// acquireCallSlot waits until the model may call its provider and returns a
+// func to give the slot back. Outbound limits plug in here so that waiting on
+// them is recorded as queue wait rather than provider latency.
+func acquireCallSlot(ctx context.Context, c *client, modelCfg ModelConfig) (release func(), err error) {
+	release = func() {}
+	if slots := callSlotSemaphore(); slots != nil {
+		select {
+		case slots <- struct{}{}:
+		default:
+			// Show the model as queued rather than stuck
+			c.WriteJSON(StreamMessage{Model: modelCfg.Name, Content: "waiting", Type: "status"})
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return nil, context.Cause(ctx)
+			}
+		}
+		release = func() { <-slots }
 	}
 
-	body, _ := json.Marshal(reqBody)
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/%s:generateContent?key=%s", cfg.Model, cfg.APIKey)
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
-	if err != nil {
-		return "", err
+	if err := waitForRateLimit(ctx, modelCfg.Provider); err != nil {
+		release()
+		return nil, err
 	}
+	return release, nil
+}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+// callOutsideGame makes a background call, such as auto-tagging, through the
+// same gates as a game's calls: the model's breaker, a call slot, rate limits,
+// retries and tiered deadlines. Nothing is streamed anywhere.
+func callOutsideGame(ctx context.Context, modelCfg ModelConfig, prompt string) (string, error) {
+	if !allowCall(modelCfg.Name) {
+		return "", ErrModelUnavailable
 	}
-	defer resp.Body.Close()
-
-	var geminiResp GeminiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
+	c := newClient(nil, nil)
+	release, err := acquireCallSlot(ctx, c, modelCfg)
+	if err != nil {
 		return "", err
 	}
-
-	if len(geminiResp.Candidates) > 0 && len(geminiResp.Candidates[0].Content.Parts) > 0 {
-		content := geminiResp.Candidates[0].Content.Parts[0].Text
-
-		for _, char := range content {
-			msg := StreamMessage{
-				Model:   cfg.Name,
-				Content: string(char),
-				Done:    false,
-				Type:    "guess",
+	defer release()
+
+	timeouts := timeoutsFor(modelCfg.Provider)
+	attempts := 0
+	response, _, _, err := callWithRetry(ctx, modelCfg, retriesFor(modelCfg.Provider), func(ctx context.Context) (string, bool, error) {
+		if attempts++; attempts > 1 {
+			if err := waitForRateLimit(ctx, modelCfg.Provider); err != nil {
+				return "", true, err
 			}
-			conn.WriteJSON(msg)
-			time.Sleep(20 * time.Millisecond)
 		}
+		callCtx, stopDeadlines := withTieredDeadlines(ctx, modelCfg, timeouts)
+		defer stopDeadlines()
+		return callProvider(callCtx, c, modelCfg, prompt, nil)
+	})
+	recordCall(modelCfg.Name, err)
+	return response, err
+}
 
-		return content, nil
-	}
+type tokenCountKey struct{}
+type answerKey struct{}
 
-	return "", fmt.Errorf("no response from Gemini")
+// withTokenCount has callProvider add the tokens its provider reports to used
+func withTokenCount(ctx context.Context, used *TokensUsed) context.Context {
+	return context.WithValue(ctx, tokenCountKey{}, used)
 }
 
-func streamOllama(ctx context.Context, conn *websocket.Conn, cfg ModelConfig, prompt string) (string, error) {
-	endpoint := cfg.Endpoint
-	if endpoint == "" {
-		endpoint = "http://localhost:11434"
-	}
+// withAnswer lets callProvider hand the riddle's answer to the mock provider
+func withAnswer(ctx context.Context, answer string) context.Context {
+	return context.WithValue(ctx, answerKey{}, answer)
+}
 
-	reqBody := OllamaRequest{
-		Model:  cfg.Model,
-		Prompt: prompt,
-		Stream: true,
-	}
+func answerFrom(ctx context.Context) string {
+	answer, _ := ctx.Value(answerKey{}).(string)
+	return answer
+}
 
-	body, _ := json.Marshal(reqBody)
-	req, err := http.NewRequestWithContext(ctx, "POST", endpoint+"/api/generate", bytes.NewReader(body))
-	if err != nil {
-		return "", err
+// callProvider sends a prompt to the model's provider, streaming tokens to
+// the client. simulated reports that the provider returned the whole response
+// at once and nothing has been streamed yet.
+// messages, when set, is the conversation so far ending with this round's
+// turn; providers without chat support use prompt instead.
+func callProvider(ctx context.Context, c *client, modelCfg ModelConfig, prompt string, messages []ChatMessage) (response string, simulated bool, err error) {
+	provider, ok := providers.Lookup(modelCfg.Provider)
+	if !ok {
+		return "", false, fmt.Errorf("unknown provider: %s", modelCfg.Provider)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+	// With reasoningMode on, only the reply's last line is the guess; the rest
+	// is forwarded as thinking
+	var reasoning *reasoningStream
+	if modelCfg.ReasoningMode {
+		reasoning = newReasoningStream(c, modelCfg.Name)
 	}
-	defer resp.Body.Close()
-
-	var fullResponse strings.Builder
-	decoder := json.NewDecoder(resp.Body)
 
-	for {
-		var streamResp OllamaStreamResponse
-		if err := decoder.Decode(&streamResp); err != nil {
-			if err == io.EOF {
-				break
+	// A cache hit is replayed like any response that arrived all at once
+	config := currentConfig()
+	caching := config.CacheResponses
+	if caching {
+		if cached, hit := cachedResponse(modelCfg, prompt, messages); hit {
+			log.Printf("Serving cached response for %s\n", modelCfg.Name)
+			if reasoning != nil {
+				reasoning.add(cached)
+				cached = reasoning.finish()
 			}
-			return "", err
+			return cached, true, nil
 		}
+	}
 
-		fullResponse.WriteString(streamResp.Response)
+	var rec *recorder
+	if config.RecordDir != "" {
+		rec = startRecording(modelCfg, prompt, messages)
+	}
 
-		msg := StreamMessage{
-			Model:   cfg.Name,
-			Content: streamResp.Response,
-			Done:    streamResp.Done,
-			Type:    "guess",
+	// A reply past the length limit stops being read, and what was forwarded
+	// up to the limit is scored as a truncated guess. In reasoning mode the
+	// limit is on the answer alone, and maxTokens bounds the reasoning.
+	limit := maxResponseChars(modelCfg)
+	ctx, cutOff := context.WithCancelCause(ctx)
+	defer cutOff(nil)
+	var forwarded strings.Builder
+	received, tooLong := 0, false
+
+	streamed := false
+	tokens := newTokenCoalescer(c, modelCfg.Name)
+	onToken := func(token string) {
+		if token == "" || tooLong {
+			return
 		}
-		conn.WriteJSON(msg)
-
-		if streamResp.Done {
-			break
+		if reasoning != nil {
+			streamed = true
+			rec.add("guess", token)
+			reasoning.add(token)
+			return
 		}
+		if limit > 0 && received+utf8.RuneCountInString(token) > limit {
+			token = string([]rune(token)[:limit-received])
+			tooLong = true
+			cutOff(errResponseTooLong)
+		}
+		received += utf8.RuneCountInString(token)
+		forwarded.WriteString(token)
+		streamed = true
+		rec.add("guess", token)
+		tokens.add(token)
 	}
-
-	return fullResponse.String(), nil
-}
-
-func streamHuggingFace(ctx context.Context, conn *websocket.Conn, cfg ModelConfig, prompt string) (string, error) {
-	endpoint := cfg.Endpoint
-	if endpoint == "" {
-		endpoint = fmt.Sprintf("https://api-inference.huggingface.co/models/%s", cfg.Model)
+	onThinking := func(text string) {
+		rec.add("thinking", text)
+		sendThinking(c, modelCfg.Name, text)
 	}
-
-	reqBody := HuggingFaceRequest{
-		Inputs: prompt,
-		Parameters: HuggingFaceParameters{
-			MaxNewTokens: 100,
-			Temperature:  0.7,
+	ctx = providers.WithCall(ctx, providers.Call{
+		Messages:   messages,
+		OnThinking: onThinking,
+		Intercept:  pickChaosFault(modelCfg.Provider),
+		OnStatus: func(status string) {
+			c.WriteJSON(StreamMessage{Model: modelCfg.Name, Content: status, Type: "status"})
 		},
-		Options: HuggingFaceOptions{
-			UseCache:     false,
-			WaitForModel: true,
+		OnUsage: func(usage providers.Usage) {
+			rec.usage(usage)
+			if used, ok := ctx.Value(tokenCountKey{}).(*TokensUsed); ok {
+				used.addUsage(usage)
+			}
 		},
-	}
+		Answer: answerFrom(ctx),
+	})
 
-	body, _ := json.Marshal(reqBody)
-	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
-	if err != nil {
-		return "", err
+	if config.ReplayDir != "" {
+		response, err = replayRecording(ctx, config.ReplayDir, modelCfg, prompt, messages, onToken, onThinking)
+	} else {
+		response, err = provider.Stream(ctx, modelCfg, prompt, onToken)
 	}
+	tokens.flush()
+	// Recordings and the cache keep the reasoning, so a replay splits it again
+	whole := response
+	if reasoning != nil {
+		if !streamed {
+			reasoning.add(response)
+		}
+		response = reasoning.finish()
+	}
+	switch {
+	case tooLong:
+		response, err = forwarded.String(), providers.ErrResponseTruncated
+	case (!streamed || reasoning != nil) && err == nil && limit > 0 && utf8.RuneCountInString(response) > limit:
+		response, err = string([]rune(response)[:limit]), providers.ErrResponseTruncated
+	}
+	if reasoning == nil {
+		whole = response
+	} else if streamed {
+		tokens.add(response)
+		tokens.flush()
+	}
+	rec.save(config.RecordDir, whole, err)
+	if errors.Is(err, providers.ErrResponseTruncated) && streamed {
+		c.WriteJSON(StreamMessage{
+			Model:     modelCfg.Name,
+			Done:      true,
+			Type:      "guess",
+			Truncated: true,
+			Outcome:   providers.OutcomeTruncated,
+		})
+	}
+	if caching && err == nil {
+		cacheResponse(modelCfg, prompt, messages, whole)
+	}
+	return response, !streamed, err
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+// sendThinking forwards reasoning tokens so the UI can show them apart from
+// the guess. They are never part of the answer that gets checked.
+func sendThinking(c *client, modelName string, content string) {
+	if content == "" {
+		return
 	}
-	defer resp.Body.Close()
+	c.WriteJSON(StreamMessage{
+		Model:   modelName,
+		Content: content,
+		Done:    false,
+		Type:    "thinking",
+	})
+}
 
-	var hfResp []HuggingFaceResponse
-	if err := json.NewDecoder(resp.Body).Decode(&hfResp); err != nil {
-		return "", err
+// simulateStream replays a complete response from a non-streaming provider as
+// word-sized guess chunks, stopping early if the model's context is cancelled
+func simulateStream(ctx context.Context, c *client, modelName string, content string) {
+	pacing := currentConfig().SimulatedStreaming
+	if pacing.Pacing == "instant" {
+		c.WriteJSON(StreamMessage{Model: modelName, Content: content, Type: "guess"})
+		return
 	}
 
-	if len(hfResp) > 0 {
-		content := hfResp[0].GeneratedText
+	delay := time.Duration(pacing.DelayMs) * time.Millisecond
+	if pacing.DelayMs <= 0 {
+		delay = 30 * time.Millisecond
+	}
 
-		// Remove the prompt from the response if it's included
-		content = strings.TrimPrefix(content, prompt)
-		content = strings.TrimSpace(content)
+	for len(content) > 0 {
+		// Each chunk is a word plus the whitespace that follows it
+		end := strings.IndexAny(content, " \n\t")
+		if end == -1 {
+			end = len(content)
+		}
+		for end < len(content) && strings.ContainsRune(" \n\t", rune(content[end])) {
+			end++
+		}
 
-		// Simulate streaming
-		for _, char := range content {
-			msg := StreamMessage{
-				Model:   cfg.Name,
-				Content: string(char),
-				Done:    false,
-				Type:    "guess",
-			}
-			conn.WriteJSON(msg)
-			time.Sleep(20 * time.Millisecond)
+		c.WriteJSON(StreamMessage{Model: modelName, Content: content[:end], Type: "guess"})
+		content = content[end:]
+		if content == "" {
+			return
 		}
 
-		return content, nil
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
 	}
-
-	return "", fmt.Errorf("no response from HuggingFace")
 }
-
-func checkAnswer(guess string, correctAnswer string) bool {
-	guess = strings.TrimSpace(strings.ToLower(guess))
-	answer := strings.TrimSpace(strings.ToLower(correctAnswer))
-
-	guess = strings.TrimPrefix(guess, "the answer is ")
-	guess = strings.TrimPrefix(guess, "i believe the answer is ")
-	guess = strings.TrimPrefix(guess, "based on the clues, it's ")
-	guess = strings.TrimPrefix(guess, "it's ")
-	guess = strings.TrimPrefix(guess, "a ")
-	guess = strings.TrimPrefix(guess, "an ")
-	guess = strings.TrimSuffix(guess, "?")
-	guess = strings.TrimSuffix(guess, ".")
-
-	return strings.Contains(guess, answer) || strings.Contains(answer, guess) || guess == answer
-}
\ No newline at end of file
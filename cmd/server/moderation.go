@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// ModerationConfig gates model guesses before they are stored and shown in
+// round results and on the leaderboard. Streamed tokens are not moderated.
+type ModerationConfig struct {
+	Enabled    bool     `json:"enabled"`
+	Mode       string   `json:"mode"`       // "local" (default) or "openai"
+	Action     string   `json:"action"`     // "mask" (default), "drop" or "flag"
+	FailClosed bool     `json:"failClosed"` // Treat moderation errors as flagged instead of letting the guess through
+	Endpoint   string   `json:"endpoint"`   // Defaults to the OpenAI moderations endpoint
+	Terms      []string `json:"terms"`      // Extra terms flagged by the local classifier
+}
+
+// ModerationDecision records what moderation decided about one guess
+type ModerationDecision struct {
+	Flagged    bool     `json:"flagged"`
+	Action     string   `json:"action,omitempty"` // Action taken when flagged
+	Categories []string `json:"categories,omitempty"`
+	Source     string   `json:"source"`          // "local" or "openai"
+	Error      string   `json:"error,omitempty"` // Set when the moderation service failed
+}
+
+const moderationMask = "[removed]"
+
+// moderationTimeout bounds a call to the moderation endpoint, so a slow
+// service can't hold up a round's results
+var moderationTimeout = 5 * time.Second
+
+// defaultModerationTerms seeds the local classifier; deployments add their own
+var defaultModerationTerms = []string{"kill yourself", "nazi", "rape", "slur"}
+
+// moderateGuess runs the configured moderation on a finished guess and returns
+// the form to store and display along with the decision. ok is false when the
+// guess should be dropped from results entirely.
+func moderateGuess(ctx context.Context, guess string) (display string, decision *ModerationDecision, ok bool) {
+	cfg := currentConfig().Moderation
+	if !cfg.Enabled || guess == "" {
+		return guess, nil, true
+	}
+
+	var err error
+	decision = &ModerationDecision{Source: "local"}
+	if cfg.Mode == "openai" {
+		decision.Source = "openai"
+		decision.Categories, err = moderateWithOpenAI(ctx, cfg, guess)
+	} else {
+		decision.Categories = moderateLocally(cfg, guess)
+	}
+
+	if err != nil {
+		log.Printf("Moderation failed: %v\n", err)
+		decision.Error = err.Error()
+		decision.Flagged = cfg.FailClosed
+	} else {
+		decision.Flagged = len(decision.Categories) > 0
+	}
+	if !decision.Flagged {
+		return guess, decision, true
+	}
+
+	decision.Action = cfg.Action
+	if decision.Action == "" {
+		decision.Action = "mask"
+	}
+	moderationQueue.add(guess, *decision)
+
+	switch decision.Action {
+	case "drop":
+		return "", decision, false
+	case "flag":
+		return guess, decision, true
+	default:
+		return moderationMask, decision, true
+	}
+}
+
+// moderateLocally flags guesses containing any configured term as a whole word
+func moderateLocally(cfg ModerationConfig, guess string) []string {
+	words := " " + strings.Join(strings.FieldsFunc(strings.ToLower(guess), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), " ") + " "
+
+	for _, term := range append(append([]string{}, defaultModerationTerms...), cfg.Terms...) {
+		if strings.Contains(words, " "+strings.ToLower(term)+" ") {
+			return []string{"blocked-term"}
+		}
+	}
+	return nil
+}
+
+type openAIModerationResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+// moderateWithOpenAI asks the OpenAI moderations endpoint which categories the
+// guess falls into
+func moderateWithOpenAI(ctx context.Context, cfg ModerationConfig, guess string) ([]string, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.openai.com/v1/moderations"
+	}
+	// Read from the environment only, since /config serves this struct publicly
+	apiKey := os.Getenv("OPENAI_API_KEY")
+
+	ctx, cancel := context.WithTimeout(ctx, moderationTimeout)
+	defer cancel()
+
+	body, _ := json.Marshal(map[string]string{"input": guess})
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+
+	resp, err := providers.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("moderation endpoint returned %s", resp.Status)
+	}
+
+	var result openAIModerationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	if len(result.Results) == 0 {
+		return nil, fmt.Errorf("moderation endpoint returned no results")
+	}
+
+	var categories []string
+	for category, hit := range result.Results[0].Categories {
+		if hit {
+			categories = append(categories, category)
+		}
+	}
+	if result.Results[0].Flagged && len(categories) == 0 {
+		categories = []string{"flagged"}
+	}
+	sort.Strings(categories)
+	return categories, nil
+}
+
+// flaggedGuess is a moderated guess awaiting admin review, kept with its
+// original text
+type flaggedGuess struct {
+	Guess     string             `json:"guess"`
+	Decision  ModerationDecision `json:"decision"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// flaggedGuessQueue keeps the most recent flagged guesses in memory for review
+type flaggedGuessQueue struct {
+	mu      sync.Mutex
+	entries []flaggedGuess
+}
+
+const maxFlaggedGuesses = 200
+
+var moderationQueue = &flaggedGuessQueue{}
+
+func (q *flaggedGuessQueue) add(guess string, decision ModerationDecision) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.entries = append(q.entries, flaggedGuess{Guess: guess, Decision: decision, Timestamp: time.Now()})
+	if len(q.entries) > maxFlaggedGuesses {
+		q.entries = q.entries[len(q.entries)-maxFlaggedGuesses:]
+	}
+}
+
+func (q *flaggedGuessQueue) list() []flaggedGuess {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]flaggedGuess{}, q.entries...)
+}
+
+// handleAdminModeration serves GET /admin/moderation, the recently flagged
+// guesses with their original text
+func handleAdminModeration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(moderationQueue.list())
+}
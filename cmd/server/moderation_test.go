@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestModerateLocally checks the local classifier flags whole words only
+func TestModerateLocally(t *testing.T) {
+	cfg := ModerationConfig{Terms: []string{"Badword"}}
+	tests := []struct {
+		guess   string
+		flagged bool
+	}{
+		{"a piano", false},
+		{"Nazi", true},
+		{"nazism", false},
+		{"kill yourself!", true},
+		{"skill yourself", false},
+		{"that's a BADWORD.", true},
+		{"badwords", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.guess, func(t *testing.T) {
+			if flagged := len(moderateLocally(cfg, tt.guess)) > 0; flagged != tt.flagged {
+				t.Errorf("flagged = %v, want %v", flagged, tt.flagged)
+			}
+		})
+	}
+}
+
+// TestModerateWithOpenAI runs guesses past a fake moderation endpoint,
+// including one that fails and one that never answers
+func TestModerateWithOpenAI(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-moderation")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-moderation" {
+			http.Error(w, "no key", http.StatusUnauthorized)
+			return
+		}
+		// Reading the body also lets the server notice a client giving up
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["input"] != "a piano" {
+			http.Error(w, "bad input", http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/clean":
+			fmt.Fprint(w, `{"results": [{"flagged": false, "categories": {"violence": false}}]}`)
+		case "/flagged":
+			fmt.Fprint(w, `{"results": [{"flagged": true, "categories": {"violence": true, "harassment": true, "hate": false}}]}`)
+		case "/down":
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		case "/hang":
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+	defer func(timeout time.Duration) { moderationTimeout = timeout }(moderationTimeout)
+	moderationTimeout = 200 * time.Millisecond
+
+	tests := []struct {
+		name       string
+		path       string
+		failClosed bool
+		action     string
+		display    string
+		kept       bool
+		categories []string
+		failed     bool
+	}{
+		{"clean", "/clean", false, "", "a piano", true, nil, false},
+		{"masked", "/flagged", false, "", moderationMask, true, []string{"harassment", "violence"}, false},
+		{"dropped", "/flagged", false, "drop", "", false, []string{"harassment", "violence"}, false},
+		{"flagged only", "/flagged", false, "flag", "a piano", true, []string{"harassment", "violence"}, false},
+		{"down, fail open", "/down", false, "", "a piano", true, nil, true},
+		{"down, fail closed", "/down", true, "", moderationMask, true, nil, true},
+		{"timeout, fail open", "/hang", false, "", "a piano", true, nil, true},
+		{"timeout, fail closed", "/hang", true, "drop", "", false, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, Config{
+				Models: []ModelConfig{{Name: "Mock", Provider: "mock", Model: "always-correct"}},
+				Moderation: ModerationConfig{
+					Enabled:    true,
+					Mode:       "openai",
+					Action:     tt.action,
+					FailClosed: tt.failClosed,
+					Endpoint:   server.URL + tt.path,
+				},
+			})
+
+			start := time.Now()
+			display, decision, kept := moderateGuess(context.Background(), "a piano")
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("moderation took %v", elapsed)
+			}
+			if display != tt.display || kept != tt.kept {
+				t.Errorf("moderateGuess = %q, kept %v; want %q, kept %v", display, kept, tt.display, tt.kept)
+			}
+			if !slices.Equal(decision.Categories, tt.categories) {
+				t.Errorf("categories %v, want %v", decision.Categories, tt.categories)
+			}
+			if failed := decision.Error != ""; failed != tt.failed {
+				t.Errorf("moderation error %q, want failed=%v", decision.Error, tt.failed)
+			}
+			if decision.Source != "openai" {
+				t.Errorf("source %q, want openai", decision.Source)
+			}
+		})
+	}
+}
+
+// replyingServer is a fake OpenAI-protocol provider that always replies with
+// reply in one chunk
+func replyingServer(t *testing.T, reply string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", reply)
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestModeratedGuessNeverSent plays a model whose guess moderation masks,
+// ruled on by the answer judge, and checks the guess appears in nothing sent
+// to the player but the streamed tokens, which moderation doesn't cover
+func TestModeratedGuessNeverSent(t *testing.T) {
+	const flagged = "grawlix"
+	disabled := false
+	useConfig(t, Config{
+		Models: []ModelConfig{
+			{Name: "Rude", Provider: "openai-compatible", Model: "rude", Endpoint: replyingServer(t, flagged).URL},
+			{Name: "Judge", Provider: "openai-compatible", Model: "judge", Endpoint: replyingServer(t, "no").URL, Enabled: &disabled},
+		},
+		AnswerJudge: AnswerJudgeConfig{Model: "Judge"},
+		Moderation:  ModerationConfig{Enabled: true, Terms: []string{flagged}},
+	})
+	messages := playGame(t, serveGames(t), "", RiddleSubmission{
+		Riddle:     "What has keys but can't open locks?",
+		Answers:    []string{"piano"},
+		Difficulty: "easy",
+		Username:   "tester",
+		Models:     []string{"Rude"},
+	})
+
+	var rulings []JudgeRuling
+	for _, message := range messages {
+		if message["type"] == "guess" {
+			continue
+		}
+		sent := mustJSON(t, message)
+		if strings.Contains(sent, flagged) {
+			t.Errorf("%s message holds the moderated guess: %s", message["type"], sent)
+		}
+		if message["type"] == "gameFinished" {
+			var states map[string]ModelState
+			if err := json.Unmarshal([]byte(mustJSON(t, message["modelStates"])), &states); err != nil {
+				t.Fatal(err)
+			}
+			rulings = states["Rude"].JudgeRulings
+		}
+	}
+	if len(rulings) != 1 || rulings[0].Candidate != moderationMask {
+		t.Errorf("judge rulings %+v, want one on %q", rulings, moderationMask)
+	}
+}
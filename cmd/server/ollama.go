@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// preloadOllamaModels loads every enabled Ollama model into memory at startup
+// so the first real round isn't spent waiting for the model to load. An empty
+// prompt makes Ollama load the model without generating anything.
+func preloadOllamaModels() {
+	seen := make(map[string]bool)
+	for _, model := range currentConfig().Models {
+		if model.Provider != "ollama" || !model.IsEnabled() {
+			continue
+		}
+		endpoint := providers.OllamaEndpoint(model)
+		if seen[endpoint+"|"+model.Model] {
+			continue
+		}
+		seen[endpoint+"|"+model.Model] = true
+
+		go func(cfg ModelConfig, endpoint string) {
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			defer cancel()
+			if err := providers.PreloadOllama(ctx, cfg); err != nil {
+				log.Printf("Warm-up of Ollama model %s at %s failed: %v\n", cfg.Model, endpoint, err)
+				return
+			}
+			log.Printf("Warmed up Ollama model %s in %.1fs\n", cfg.Model, time.Since(start).Seconds())
+		}(model, endpoint)
+	}
+}
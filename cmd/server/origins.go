@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// Capability names something a websocket origin is allowed to do
+type Capability string
+
+const (
+	CapPlay      Capability = "play"      // start and play games
+	CapDashboard Capability = "dashboard" // open dashboard sockets
+	CapSpectate  Capability = "spectate"  // open spectator sockets
+	CapExtended  Capability = "extended"  // receive provider error details and selection traces
+)
+
+// OriginConfig grants a set of capabilities to a single frontend origin.
+// An origin of "*" matches any origin not listed explicitly.
+type OriginConfig struct {
+	Origin       string       `json:"origin"`
+	Capabilities []Capability `json:"capabilities"`
+}
+
+type capabilitySet map[Capability]bool
+
+func (s capabilitySet) Has(capability Capability) bool {
+	return s[capability]
+}
+
+type capabilitiesKey struct{}
+
+// resolveOriginCapabilities looks up the capabilities configured for an origin.
+// With no origins configured every origin may play, matching the old behavior.
+func resolveOriginCapabilities(origin string) (capabilitySet, bool) {
+	origins := currentConfig().Origins
+	if len(origins) == 0 {
+		return capabilitySet{CapPlay: true}, true
+	}
+
+	var wildcard *OriginConfig
+	for i := range origins {
+		switch origins[i].Origin {
+		case origin:
+			return newCapabilitySet(origins[i].Capabilities), true
+		case "*":
+			wildcard = &origins[i]
+		}
+	}
+	if wildcard != nil {
+		return newCapabilitySet(wildcard.Capabilities), true
+	}
+	return nil, false
+}
+
+func newCapabilitySet(capabilities []Capability) capabilitySet {
+	set := make(capabilitySet, len(capabilities))
+	for _, capability := range capabilities {
+		set[capability] = true
+	}
+	return set
+}
+
+// withOriginCapabilities rejects requests from unknown origins and attaches the
+// resolved capability set to the request context for the wrapped handler
+func withOriginCapabilities(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		caps, ok := resolveOriginCapabilities(r.Header.Get("Origin"))
+		if !ok {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), capabilitiesKey{}, caps)))
+	}
+}
+
+func capabilitiesFrom(ctx context.Context) capabilitySet {
+	caps, _ := ctx.Value(capabilitiesKey{}).(capabilitySet)
+	return caps
+}
+
+// client is a game websocket together with the capabilities of its origin.
+// Writes are serialized because model goroutines share the connection.
+type client struct {
+	conn       *websocket.Conn
+	caps       capabilitySet
+	writeMux   *sync.Mutex
+	streamType string          // Overrides the type of streamed tokens, see withStreamType
+	ctx        context.Context // Ends when the connection closes; nil for headless clients
+}
+
+func newClient(conn *websocket.Conn, caps capabilitySet) *client {
+	return &client{conn: conn, caps: caps, writeMux: &sync.Mutex{}}
+}
+
+// withStreamType returns a client on the same connection that relabels
+// streamed tokens with msgType, so a provider call can stream something other
+// than a guess
+func (c *client) withStreamType(msgType string) *client {
+	return &client{conn: c.conn, caps: c.caps, writeMux: c.writeMux, streamType: msgType, ctx: c.ctx}
+}
+
+// WriteJSON sends a message to the client. Headless clients, used for
+// background model calls, have no connection and discard everything, and
+// nothing is sent once the client has disconnected.
+func (c *client) WriteJSON(v interface{}) error {
+	if c.conn == nil {
+		return nil
+	}
+	if c.ctx != nil && c.ctx.Err() != nil {
+		return context.Cause(c.ctx)
+	}
+	if msg, ok := v.(StreamMessage); ok && c.streamType != "" {
+		msg.Type = c.streamType
+		v = msg
+	}
+	c.writeMux.Lock()
+	defer c.writeMux.Unlock()
+	return c.conn.WriteJSON(v)
+}
+
+// visibleModelStates returns the model states as this client may see them,
+// dropping provider error details unless the origin has the extended capability
+func (c *client) visibleModelStates(states map[string]ModelState) map[string]ModelState {
+	if c.caps.Has(CapExtended) {
+		return states
+	}
+
+	visible := make(map[string]ModelState, len(states))
+	for name, state := range states {
+		state.Error = ""
+		visible[name] = state
+	}
+	return visible
+}
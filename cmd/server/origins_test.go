@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	publicOrigin = "https://play.example.com"
+	viewerOrigin = "https://viewer.example.com"
+	adminOrigin  = "https://admin.example.com"
+)
+
+// useOriginConfig sets up origins that may play, may only watch, and may do
+// everything including seeing extended details
+func useOriginConfig(t *testing.T) {
+	useConfig(t, Config{
+		Models: []ModelConfig{
+			{Name: "Right", Provider: "mock", Model: "always-correct"},
+			{Name: "Wrong", Provider: "mock", Model: "always-wrong"},
+			{Name: "Broken", Provider: "mock", Model: "error"},
+		},
+		Retries: map[string]RetryConfig{"default": {MaxAttempts: 1}},
+		Origins: []OriginConfig{
+			{Origin: publicOrigin, Capabilities: []Capability{CapPlay}},
+			{Origin: viewerOrigin, Capabilities: []Capability{CapDashboard, CapSpectate}},
+			{Origin: adminOrigin, Capabilities: []Capability{CapPlay, CapDashboard, CapSpectate, CapExtended}},
+		},
+	})
+	t.Cleanup(func() {
+		breakersMux.Lock()
+		delete(breakers, "Broken")
+		breakersMux.Unlock()
+	})
+}
+
+// TestOriginPayloads plays the same game from a public and an extended origin
+// and diffs what each was sent: the same messages, with the extended fields
+// only ever serialized for the extended origin
+func TestOriginPayloads(t *testing.T) {
+	useOriginConfig(t)
+	url := serveGames(t)
+
+	var sentMux sync.Mutex
+	sent := make(map[string][]map[string]interface{})
+	t.Run("play", func(t *testing.T) {
+		for _, origin := range []string{publicOrigin, adminOrigin} {
+			origin := origin
+			t.Run(origin, func(t *testing.T) {
+				t.Parallel()
+				messages := playGame(t, url, origin, RiddleSubmission{
+					Riddle:     "What has keys but can't open locks?",
+					Answers:    []string{"piano"},
+					Difficulty: "easy",
+				})
+				sentMux.Lock()
+				sent[origin] = messages
+				sentMux.Unlock()
+			})
+		}
+	})
+	if t.Failed() {
+		return
+	}
+
+	// Which messages went out, by type and model
+	kinds := func(messages []map[string]interface{}) map[string]int {
+		counts := make(map[string]int)
+		for _, message := range messages {
+			if message["type"] == "guess" || message["type"] == "status" {
+				continue // Batched by timing, so their number varies
+			}
+			model, _ := message["model"].(string)
+			counts[message["type"].(string)+"/"+model]++
+		}
+		return counts
+	}
+	public, admin := kinds(sent[publicOrigin]), kinds(sent[adminOrigin])
+	if got, want := mustJSON(t, public), mustJSON(t, admin); got != want {
+		t.Errorf("origins were sent different messages:\npublic %s\nadmin  %s", got, want)
+	}
+
+	find := func(origin, msgType, model string) map[string]interface{} {
+		for _, message := range sent[origin] {
+			if message["type"] == msgType && (model == "" || message["model"] == model) {
+				return message
+			}
+		}
+		t.Fatalf("%s was sent no %s message for %q", origin, msgType, model)
+		return nil
+	}
+	if _, ok := find(adminOrigin, "gameStart", "")["selectionTrace"]; !ok {
+		t.Error("the extended origin wasn't sent the selection trace")
+	}
+	if got := find(publicOrigin, "error", "Broken")["content"]; got != "provider" {
+		t.Errorf("the public origin was sent %q for a failed model, want only its error category", got)
+	}
+	if got, _ := find(adminOrigin, "error", "Broken")["content"].(string); !strings.Contains(got, "always fails") {
+		t.Errorf("the extended origin was sent %q for a failed model, want the provider's error", got)
+	}
+	for _, message := range sent[publicOrigin] {
+		if data := mustJSON(t, message); strings.Contains(data, "selectionTrace") || strings.Contains(data, "always fails") {
+			t.Errorf("extended details sent to the public origin: %s", data)
+		}
+	}
+}
+
+// TestLiveSockets opens the dashboard and spectator sockets from each origin:
+// only origins with the capability get in, and provider errors only reach
+// extended ones
+func TestLiveSockets(t *testing.T) {
+	useOriginConfig(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws/dashboard", withOriginCapabilities(handleDashboard))
+	mux.HandleFunc("/ws/spectate", withOriginCapabilities(withRoom(handleSpectate)))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	base := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	const detail = "mock: 503 mock_error: the mock provider always fails"
+	game := newTestGame(t, "piano", currentConfig().Models...)
+	game.ModelStates["Broken"] = ModelState{Error: detail, ErrorCategory: "provider"}
+	key := &websocket.Conn{}
+	gamesMux.Lock()
+	games[key] = game
+	gamesMux.Unlock()
+	recordCall("Broken", errors.New(detail))
+	t.Cleanup(func() {
+		gamesMux.Lock()
+		delete(games, key)
+		gamesMux.Unlock()
+	})
+
+	for _, path := range []string{"/ws/dashboard", "/ws/spectate"} {
+		t.Run(path, func(t *testing.T) {
+			header := http.Header{"Origin": {publicOrigin}}
+			if _, resp, err := websocket.DefaultDialer.Dial(base+path, header); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+				t.Errorf("an origin without the capability got in: %v", err)
+			}
+
+			for _, origin := range []string{viewerOrigin, adminOrigin} {
+				_, data, err := dial(t, base+path, origin).ReadMessage()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.Contains(string(data), "piano") {
+					t.Errorf("%s was sent the answer: %s", origin, data)
+				}
+				if shown := strings.Contains(string(data), detail); shown != (origin == adminOrigin) {
+					t.Errorf("%s sent the provider's error=%v: %s", origin, shown, data)
+				}
+			}
+		})
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
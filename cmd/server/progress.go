@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// ProgressMessage reports where a model's call is in its lifecycle before any
+// tokens arrive: "modelQueued", "modelRequesting" or "modelStreaming"
+type ProgressMessage struct {
+	Type      string    `json:"type"`
+	Model     string    `json:"model"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func sendProgress(c *client, msgType string, modelName string, at time.Time) {
+	c.WriteJSON(ProgressMessage{Type: msgType, Model: modelName, Timestamp: at})
+}
+
+// withProgressTrace attaches an HTTP trace to ctx that emits modelRequesting
+// once the request has been written and modelStreaming on the first response
+// byte. onFirstByte receives the time the first byte arrived.
+func withProgressTrace(ctx context.Context, c *client, modelName string, onFirstByte func(time.Time)) context.Context {
+	var requesting, streaming sync.Once
+	trace := &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err != nil {
+				return
+			}
+			requesting.Do(func() {
+				sendProgress(c, "modelRequesting", modelName, time.Now())
+			})
+		},
+		GotFirstResponseByte: func() {
+			streaming.Do(func() {
+				now := time.Now()
+				sendProgress(c, "modelStreaming", modelName, now)
+				onFirstByte(now)
+			})
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace)
+}
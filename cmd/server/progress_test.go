@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stagedServer is a fake OpenAI-protocol provider that holds its response
+// back for firstByte, then streams "a piano" a word every perWord
+func stagedServer(t *testing.T, firstByte, perWord time.Duration) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		time.Sleep(firstByte)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, word := range []string{"a ", "piano"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", word)
+			w.(http.Flusher).Flush()
+			time.Sleep(perWord)
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestProgressSequence plays two models through one call slot against a
+// provider slow to answer, and checks each model's messages run queued,
+// requesting, streaming and then its guess, with the gaps between them
+// where the time actually went
+func TestProgressSequence(t *testing.T) {
+	const firstByte, perWord = 300 * time.Millisecond, 50 * time.Millisecond
+	server := stagedServer(t, firstByte, perWord)
+	useConfig(t, Config{
+		Models: []ModelConfig{
+			{Name: "Alpha", Provider: "openai-compatible", Model: "alpha", Endpoint: server.URL},
+			{Name: "Beta", Provider: "openai-compatible", Model: "beta", Endpoint: server.URL},
+		},
+		MaxConcurrentModelCalls: 1,
+	})
+	messages := playGame(t, serveGames(t), "", RiddleSubmission{
+		Riddle:     "What has keys but can't open locks?",
+		Answers:    []string{"piano"},
+		Difficulty: "easy",
+		Username:   "progress",
+	})
+
+	// Each model's lifecycle messages up to its first guess
+	sequences := map[string][]string{}
+	stamps := map[string]map[string]time.Time{}
+	for _, message := range messages {
+		model, _ := message["model"].(string)
+		kind, _ := message["type"].(string)
+		if model == "" || len(sequences[model]) > 0 && sequences[model][len(sequences[model])-1] == "guess" {
+			continue
+		}
+		switch kind {
+		case "modelQueued", "modelRequesting", "modelStreaming":
+			at, err := time.Parse(time.RFC3339Nano, message["timestamp"].(string))
+			if err != nil {
+				t.Fatalf("%s %s timestamp: %v", model, kind, err)
+			}
+			if stamps[model] == nil {
+				stamps[model] = map[string]time.Time{}
+			}
+			stamps[model][kind] = at
+		case "status":
+			kind = "status:" + message["content"].(string)
+		case "guess":
+		default:
+			continue
+		}
+		sequences[model] = append(sequences[model], kind)
+	}
+
+	// Whichever model got the slot second waited for it
+	first, second := "Alpha", "Beta"
+	if len(sequences["Alpha"]) > 1 && sequences["Alpha"][1] == "status:waiting" {
+		first, second = second, first
+	}
+	want := map[string]string{
+		first:  "[modelQueued modelRequesting modelStreaming guess]",
+		second: "[modelQueued status:waiting modelRequesting modelStreaming guess]",
+	}
+	for model, sequence := range want {
+		if got := fmt.Sprint(sequences[model]); got != sequence {
+			t.Errorf("%s messages: %s, want %s", model, got, sequence)
+		}
+	}
+	if t.Failed() {
+		return
+	}
+
+	// The first call starts at once; the second only once the first is done
+	// with the slot, after its first byte and both words
+	slack := 250 * time.Millisecond
+	gaps := []struct {
+		model    string
+		from, to string
+		min, max time.Duration
+	}{
+		{first, "modelQueued", "modelRequesting", 0, slack},
+		{first, "modelRequesting", "modelStreaming", firstByte, firstByte + slack},
+		{second, "modelQueued", "modelRequesting", firstByte + 2*perWord, firstByte + 2*perWord + slack},
+		{second, "modelRequesting", "modelStreaming", firstByte, firstByte + slack},
+	}
+	for _, gap := range gaps {
+		took := stamps[gap.model][gap.to].Sub(stamps[gap.model][gap.from])
+		if took < gap.min || took > gap.max {
+			t.Errorf("%s went from %s to %s in %v, want %v to %v", gap.model, gap.from, gap.to, took, gap.min, gap.max)
+		}
+	}
+}
+
+// TestQueueWaitAttribution plays two models through one call slot and checks
+// the one that waited for it has the wait recorded as queue wait, in the
+// round results, stats and leaderboard, and not as provider latency
+func TestQueueWaitAttribution(t *testing.T) {
+	const firstByte, perWord = 300 * time.Millisecond, 50 * time.Millisecond
+	server := stagedServer(t, firstByte, perWord)
+	useConfig(t, Config{
+		Models: []ModelConfig{
+			{Name: "Alpha", Provider: "openai-compatible", Model: "alpha", Endpoint: server.URL},
+			{Name: "Beta", Provider: "openai-compatible", Model: "beta", Endpoint: server.URL},
+		},
+		MaxConcurrentModelCalls: 1,
+	})
+	messages := playGame(t, serveGames(t), "", RiddleSubmission{
+		Riddle:     "What has keys but can't open locks?",
+		Answers:    []string{"piano"},
+		Difficulty: "easy",
+		Username:   "queued",
+	})
+
+	// The last round's results come with gameFinished
+	var states map[string]interface{}
+	for _, message := range messages {
+		if message["type"] == "gameResult" || message["type"] == "gameFinished" {
+			states, _ = message["modelStates"].(map[string]interface{})
+		}
+	}
+	if len(states) != 2 {
+		t.Fatalf("round results hold %d model states, want 2", len(states))
+	}
+	seconds := func(model, field string) float64 {
+		value, _ := states[model].(map[string]interface{})[field].(float64)
+		return value
+	}
+	first, second := "Alpha", "Beta"
+	if seconds("Alpha", "queueWait") > seconds("Beta", "queueWait") {
+		first, second = second, first
+	}
+
+	// Both calls take a first byte and two words at the provider; only the
+	// second waits, for the whole of the first call
+	call := (firstByte + 2*perWord).Seconds()
+	slack := 0.25
+	checks := []struct {
+		model, field string
+		min, max     float64
+	}{
+		{first, "queueWait", 0, slack},
+		{first, "responseTime", call, call + slack},
+		{second, "queueWait", call, call + slack},
+		{second, "responseTime", call, call + slack},
+		{second, "firstTokenLatency", firstByte.Seconds(), firstByte.Seconds() + slack},
+	}
+	for _, check := range checks {
+		if got := seconds(check.model, check.field); got < check.min || got > check.max {
+			t.Errorf("%s %s = %.3fs in the round results, want %.3fs to %.3fs", check.model, check.field, got, check.min, check.max)
+		}
+	}
+
+	rm := rooms[defaultRoom]
+	waitForRecorded(t, rm, 1)
+	rm.statsMux.Lock()
+	if len(rm.stats.ByModel) != 2 {
+		t.Errorf("stats for %d models, want 2", len(rm.stats.ByModel))
+	}
+	for _, stats := range rm.stats.ByModel {
+		name := stats.Name
+		if stats.AvgQueueWait == nil || *stats.AvgQueueWait != seconds(name, "queueWait") {
+			t.Errorf("%s average queue wait %v in stats, want %.3f", name, stats.AvgQueueWait, seconds(name, "queueWait"))
+		}
+		if stats.AvgResponseTime != seconds(name, "responseTime") {
+			t.Errorf("%s average response time %.3f in stats, want %.3f", name, stats.AvgResponseTime, seconds(name, "responseTime"))
+		}
+	}
+	rm.statsMux.Unlock()
+
+	rm.leaderboardMux.Lock()
+	defer rm.leaderboardMux.Unlock()
+	for _, model := range rm.leaderboard[0].Models {
+		if model.ResponseTime != seconds(model.Name, "responseTime") {
+			t.Errorf("%s response time %.3f on the leaderboard, want %.3f", model.Name, model.ResponseTime, seconds(model.Name, "responseTime"))
+		}
+	}
+}
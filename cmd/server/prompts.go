@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// The built-in prompt templates. Files of the same name in dataDir+"prompts/"
+// replace them, and those in dataDir+"prompts/<provider>/" replace them again
+// for that provider's models. A riddle in another language uses the
+// templates with its base language in the name, such as "prompt.de.tmpl",
+// where there are some.
+//
+//go:embed prompts/*.tmpl
+var defaultPromptFiles embed.FS
+
+const (
+	promptTemplate = "prompt.tmpl" // The whole prompt, for one-shot providers
+	chatTemplate   = "chat.tmpl"   // This round's user turn, for providers sent the game as a conversation
+
+	// maxAnswerWords is the longest answer prompts ask for
+	maxAnswerWords = 2
+
+	// maxIncorrectGuesses is how many of its wrong guesses a model is told not
+	// to repeat
+	maxIncorrectGuesses = 5
+)
+
+// PromptData is what prompt templates see
+type PromptData struct {
+	Riddle         string
+	Clues          []string         // The clues revealed so far, less any cut to fit the context budget
+	CluesOmitted   int              // Earlier clues cut to fit the model's context budget
+	Incorrect      []string         // The model's earlier wrong guesses, or the latest few when summarized
+	IncorrectTotal int              // Every earlier wrong guess, when Incorrect was cut down to fit; otherwise 0
+	Examples       []FewShotExample // Solved riddles to show first, see fewShotExamples
+	OthersTried    []string         // The other models' wrong guesses, in games with shared guesses
+	Difficulty     string
+	Reasoning      bool // The model thinks aloud and answers on the last line, see reasoningMode
+	MaxWords       int  // The most words the answer should have
+	Round          int  // The round being played, from 1
+	Provider       string
+	Language       string // The riddle's BCP-47 language tag
+	LanguageName   string // The language's English name, empty for English riddles
+}
+
+var promptFuncs = template.FuncMap{
+	"join": func(items []string, sep string) string { return strings.Join(items, sep) },
+	"list": naturalList,
+}
+
+var defaultPrompts = template.Must(template.New("prompts").Funcs(promptFuncs).ParseFS(defaultPromptFiles, "prompts/*.tmpl"))
+
+// samplePrompts fill every field, the second as cut down to fit a context
+// budget, so a template that names one that doesn't exist fails when it is
+// loaded rather than mid-game
+var samplePrompts = []PromptData{samplePrompt, func() PromptData {
+	cut := samplePrompt
+	cut.Clues, cut.CluesOmitted = cut.Clues[1:], 1
+	cut.IncorrectTotal = 4
+	cut.Reasoning = true
+	return cut
+}()}
+
+var samplePrompt = PromptData{
+	Riddle:       "What has keys but can't open locks?",
+	Clues:        []string{"It makes music", "It has 88 keys"},
+	Incorrect:    []string{"keyboard"},
+	OthersTried:  []string{"a door", "a map"},
+	Examples:     []FewShotExample{{Riddle: "What has hands but can't clap?", Answer: "a clock"}},
+	Difficulty:   "hard",
+	MaxWords:     maxAnswerWords,
+	Round:        3,
+	Language:     "de",
+	LanguageName: "German",
+}
+
+// loadPrompts parses the prompt templates from dataDir+"prompts/" over the
+// built-in ones, keyed by provider with "" for the set every other provider
+// uses. Any template that doesn't parse or render stops the server.
+func loadPrompts() map[string]*template.Template {
+	dir := dataDir + "prompts/"
+	base, err := parsePromptDir(defaultPrompts, dir)
+	if err != nil {
+		log.Fatal("Error loading prompt templates: ", err)
+	}
+	prompts := map[string]*template.Template{"": base}
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		provider := entry.Name()
+		tmpl, err := parsePromptDir(base, dir+provider+"/")
+		if err != nil {
+			log.Fatal("Error loading prompt templates for ", provider, ": ", err)
+		}
+		prompts[provider] = tmpl
+		log.Printf("Loaded prompt templates for %s\n", provider)
+	}
+	return prompts
+}
+
+// parsePromptDir parses the templates in dir over a copy of base and checks
+// that the prompts still render
+func parsePromptDir(base *template.Template, dir string) (*template.Template, error) {
+	tmpl, err := base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if len(files) > 0 {
+		if tmpl, err = tmpl.ParseFiles(files...); err != nil {
+			return nil, err
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if strings.HasSuffix(t.Name(), ".tmpl") {
+			for _, sample := range samplePrompts {
+				if _, err := executePrompt(tmpl, t.Name(), sample); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return tmpl, nil
+}
+
+func executePrompt(tmpl *template.Template, name string, data PromptData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// renderPrompt renders one of the prompt templates for a model, cut down to
+// fit its context budget beside the tokens already used by earlier turns. It
+// reports whether anything was cut.
+func renderPrompt(name string, game *GameState, modelCfg ModelConfig, used int) (string, bool) {
+	data := newPromptData(game, modelCfg)
+	if modelCfg.ContextBudget <= 0 {
+		return renderPromptData(name, data, modelCfg), false
+	}
+	return fitPrompt(name, data, modelCfg, modelCfg.ContextBudget-used-estimateTokens(modelCfg.SystemPrompt))
+}
+
+// renderPromptData renders a prompt template, falling back to the built-in
+// template if the configured one fails
+func renderPromptData(name string, data PromptData, modelCfg ModelConfig) string {
+	prompts := currentConfig().prompts
+	tmpl, ok := prompts[modelCfg.Provider]
+	if !ok {
+		tmpl = prompts[""]
+	}
+	if tmpl != nil {
+		out, err := executePrompt(tmpl, localizedPrompt(tmpl, name, data.Language), data)
+		if err == nil {
+			return out
+		}
+		log.Printf("Error rendering %s for %s, using the default: %v\n", name, modelCfg.Name, err)
+	}
+	out, err := executePrompt(defaultPrompts, localizedPrompt(defaultPrompts, name, data.Language), data)
+	if err != nil {
+		log.Printf("Error rendering default %s: %v\n", name, err)
+	}
+	return out
+}
+
+// localizedPrompt is the name of the template in the riddle's language, such
+// as "prompt.de.tmpl" for "prompt.tmpl", or name itself if there isn't one.
+// English riddles always use name.
+func localizedPrompt(tmpl *template.Template, name string, tag string) string {
+	base := baseLanguage(tag)
+	if base == defaultLanguage {
+		return name
+	}
+	localized := strings.TrimSuffix(name, ".tmpl") + "." + base + ".tmpl"
+	if tmpl.Lookup(localized) == nil {
+		return name
+	}
+	return localized
+}
+
+// hasLocalizedPrompts reports whether riddles in a language get prompts in it
+// rather than English ones
+func hasLocalizedPrompts(tag string) bool {
+	tmpl := currentConfig().prompts[""]
+	if tmpl == nil {
+		tmpl = defaultPrompts
+	}
+	return baseLanguage(tag) == defaultLanguage || localizedPrompt(tmpl, promptTemplate, tag) != promptTemplate
+}
+
+func newPromptData(game *GameState, modelCfg ModelConfig) PromptData {
+	data := PromptData{
+		Riddle:       game.Riddle,
+		Difficulty:   game.Difficulty,
+		MaxWords:     maxAnswerWords,
+		Round:        game.CurrentRound + 1,
+		Provider:     modelCfg.Provider,
+		Language:     game.Language,
+		LanguageName: languageName(game),
+		Examples:     fewShotExamples(game, modelCfg),
+		Reasoning:    modelCfg.ReasoningMode,
+	}
+	if game.CurrentRound > 0 && game.CurrentRound <= len(game.Clues) {
+		data.Clues = game.Clues[:game.CurrentRound]
+	}
+
+	data.Incorrect = incorrectGuesses(game.ModelStates[modelCfg.Name])
+	data.OthersTried = othersTried(game, modelCfg.Name, data.Incorrect)
+	return data
+}
+
+// incorrectGuesses are a model's latest maxIncorrectGuesses distinct wrong
+// guesses, oldest first, ignoring case. Each is cut down to the answer picked
+// out of it, so a reply that rambled doesn't go back into the prompt whole.
+func incorrectGuesses(state ModelState) []string {
+	seen := make(map[string]bool)
+	var guesses []string
+	for i := len(state.AllGuesses) - 1; i >= 0 && len(guesses) < maxIncorrectGuesses; i-- {
+		if state.GuessResults[i] || state.AllGuesses[i] == moderationMask {
+			continue
+		}
+		guess := shortGuess(state.AllGuesses[i])
+		key := strings.ToLower(guess)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		guesses = append(guesses, guess)
+	}
+	slices.Reverse(guesses)
+	return guesses
+}
+
+// shortGuess is the answer picked out of a guess, at most maxCandidateWords
+// words of it
+func shortGuess(guess string) string {
+	words := strings.Fields(strings.Trim(extractAnswer(guess), guessTrimChars))
+	if len(words) > maxCandidateWords {
+		words = words[:maxCandidateWords]
+	}
+	return strings.Join(words, " ")
+}
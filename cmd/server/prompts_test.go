@@ -0,0 +1,42 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestIncorrectGuesses builds up a model's guess history and checks what it
+// is told not to repeat: distinct ignoring case, cut down to the answer in
+// each reply, and only the latest few
+func TestIncorrectGuesses(t *testing.T) {
+	wrong := func(guesses ...string) ModelState {
+		return ModelState{AllGuesses: guesses, GuessResults: make([]bool, len(guesses))}
+	}
+	tests := []struct {
+		name  string
+		state ModelState
+		want  []string
+	}{
+		{"none", ModelState{}, nil},
+		{"in order", wrong("a teapot", "the moon"), []string{"a teapot", "the moon"}},
+		{"repeats", wrong("a teapot", "A Teapot", "the moon", "A TEAPOT"), []string{"the moon", "A TEAPOT"}},
+		{"rambling", wrong(
+			"I think the answer is a teapot, because it has a spout",
+			"It is a very long thing with many words in it",
+		), []string{"a teapot", "It is a very"}},
+		{"rambling repeats", wrong("I think the answer is a teapot, because it has a spout", "A teapot."), []string{"A teapot"}},
+		{"latest five", wrong("one", "two", "three", "four", "five", "six", "seven"), []string{"three", "four", "five", "six", "seven"}},
+		{"five distinct", wrong("one", "two", "three", "four", "five", "six", "Six", "SIX", "seven", "seven"), []string{"three", "four", "five", "SIX", "seven"}},
+		{"right and masked", ModelState{
+			AllGuesses:   []string{"a teapot", moderationMask, "a piano"},
+			GuessResults: []bool{false, false, true},
+		}, []string{"a teapot"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := incorrectGuesses(tt.state); !slices.Equal(got, tt.want) {
+				t.Errorf("incorrectGuesses = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// RateLimitConfig caps how fast calls go out to one provider, across all
+// games and rooms
+type RateLimitConfig struct {
+	RequestsPerMinute float64 `json:"requestsPerMinute"`
+	Burst             int     `json:"burst"` // Calls allowed at once after a quiet spell, defaults to 1
+}
+
+// ErrRateLimited means the provider's rate limit wouldn't free up a call
+// before the round's deadline
+var ErrRateLimited = errors.New("outbound rate limit leaves no time for the call")
+
+// tokenBucket is a token-bucket limiter. Callers reserve a token up front and
+// wait out the debt, so waiters are served in arrival order.
+type tokenBucket struct {
+	mu     sync.Mutex
+	config RateLimitConfig
+	perSec float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	after  func(time.Duration) <-chan time.Time
+}
+
+func newTokenBucket(config RateLimitConfig) *tokenBucket {
+	burst := float64(config.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	b := &tokenBucket{
+		config: config,
+		perSec: config.RequestsPerMinute / 60,
+		burst:  burst,
+		tokens: burst,
+		now:    time.Now,
+		after:  time.After,
+	}
+	b.last = b.now()
+	return b
+}
+
+// reserve takes a token and returns how long the caller must wait before
+// using it
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.tokens += now.Sub(b.last).Seconds() * b.perSec
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.perSec * float64(time.Second))
+}
+
+// cancel hands back a reserved token that won't be used
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	b.tokens++
+	b.mu.Unlock()
+}
+
+// Wait blocks until a call may go out, giving up if ctx ends first or its
+// deadline is too close for the wait
+func (b *tokenBucket) Wait(ctx context.Context) error {
+	wait := b.reserve()
+	if wait <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Sub(b.now()) < wait {
+		b.cancel()
+		return ErrRateLimited
+	}
+
+	select {
+	case <-ctx.Done():
+		b.cancel()
+		return context.Cause(ctx)
+	case <-b.after(wait):
+		return nil
+	}
+}
+
+var (
+	rateLimitersMux sync.Mutex
+	rateLimiters    = make(map[string]*tokenBucket)
+)
+
+// rateLimiterFor returns the provider's shared limiter, or nil if it has no
+// limit. A limiter is rebuilt when its configured limit changes.
+func rateLimiterFor(provider string) *tokenBucket {
+	config, ok := currentConfig().RateLimits[provider]
+	if !ok || config.RequestsPerMinute <= 0 {
+		return nil
+	}
+
+	rateLimitersMux.Lock()
+	defer rateLimitersMux.Unlock()
+	limiter := rateLimiters[provider]
+	if limiter == nil || limiter.config != config {
+		limiter = newTokenBucket(config)
+		rateLimiters[provider] = limiter
+	}
+	return limiter
+}
+
+// waitForRateLimit waits for the provider's rate limit, if it has one
+func waitForRateLimit(ctx context.Context, provider string) error {
+	if limiter := rateLimiterFor(provider); limiter != nil {
+		return limiter.Wait(ctx)
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeBucket is a limiter that runs on clock instead of real time
+func fakeBucket(config RateLimitConfig, clock *fakeClock) *tokenBucket {
+	b := newTokenBucket(config)
+	b.now, b.after, b.last = clock.Now, clock.After, clock.Now()
+	return b
+}
+
+// TestTokenBucketSpacing spends the burst at once, then spaces calls out at
+// the configured rate, queueing waiters in arrival order
+func TestTokenBucketSpacing(t *testing.T) {
+	clock := newFakeClock()
+	b := fakeBucket(RateLimitConfig{RequestsPerMinute: 60, Burst: 2}, clock)
+
+	for i, want := range []time.Duration{0, 0, time.Second, 2 * time.Second, 3 * time.Second} {
+		if wait := b.reserve(); wait != want {
+			t.Errorf("call %d waits %v, want %v", i+1, wait, want)
+		}
+	}
+
+	// Five seconds pay off the three in debt and refill the burst
+	clock.Advance(5 * time.Second)
+	for i, want := range []time.Duration{0, 0, time.Second} {
+		if wait := b.reserve(); wait != want {
+			t.Errorf("after a quiet spell, call %d waits %v, want %v", i+1, wait, want)
+		}
+	}
+
+	// Refilling never saves up more than the burst
+	clock.Advance(time.Hour)
+	for i, want := range []time.Duration{0, 0, time.Second} {
+		if wait := b.reserve(); wait != want {
+			t.Errorf("after an hour, call %d waits %v, want %v", i+1, wait, want)
+		}
+	}
+}
+
+// TestTokenBucketWait holds a call until the clock reaches its turn
+func TestTokenBucketWait(t *testing.T) {
+	clock := newFakeClock()
+	b := fakeBucket(RateLimitConfig{RequestsPerMinute: 30}, clock)
+	if err := b.Wait(context.Background()); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- b.Wait(context.Background()) }()
+	if wait := clock.Waiting(t); wait != 2*time.Second {
+		t.Errorf("second call waits %v, want 2s", wait)
+	}
+	clock.Advance(2*time.Second - time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("second call went out early: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Millisecond)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("second call: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second call still waiting once its turn came")
+	}
+}
+
+// TestTokenBucketGivesUp hands the token back when a call can't wait for it,
+// so the next caller isn't charged for it
+func TestTokenBucketGivesUp(t *testing.T) {
+	clock := newFakeClock()
+	b := fakeBucket(RateLimitConfig{RequestsPerMinute: 60}, clock)
+	b.reserve()
+
+	// The round ends before the call's turn
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(500*time.Millisecond))
+	defer cancel()
+	if err := b.Wait(ctx); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Wait with too little time = %v, want ErrRateLimited", err)
+	}
+	if wait := b.reserve(); wait != time.Second {
+		t.Errorf("after giving up, the next call waits %v, want 1s", wait)
+	}
+
+	// The round is cancelled while waiting
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Wait(ctx) }()
+	clock.Waiting(t)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait cancelled = %v, want context.Canceled", err)
+	}
+	if wait := b.reserve(); wait != 2*time.Second {
+		t.Errorf("after a cancelled wait, the next call waits %v, want 2s", wait)
+	}
+}
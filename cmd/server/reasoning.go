@@ -0,0 +1,62 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// reasoningTokenFactor is how many times the global maxTokens models with
+// reasoningMode get by default, so the reasoning leaves room for the answer
+const reasoningTokenFactor = 4
+
+// answerLabel is dropped from the front of a reasoning-mode reply's last line
+var answerLabel = regexp.MustCompile(`(?i)^[*_]*(?:final answer|answer)[*_]*\s*:[*_]*\s*`)
+
+// reasoningStream splits the reply of a model with reasoningMode on as it
+// streams. Each line is forwarded as thinking once a later one has begun, and
+// the last is held back, since that one is the answer.
+type reasoningStream struct {
+	c       *client
+	model   string
+	held    string // The last finished line with any blank lines after it, not yet forwarded
+	partial string // The line still being written
+}
+
+func newReasoningStream(c *client, modelName string) *reasoningStream {
+	return &reasoningStream{c: c, model: modelName}
+}
+
+// add takes the next piece of the reply
+func (r *reasoningStream) add(token string) {
+	r.partial += token
+	for {
+		end := strings.IndexByte(r.partial, '\n')
+		if end == -1 {
+			break
+		}
+		line := r.partial[:end+1]
+		r.partial = r.partial[end+1:]
+		if strings.TrimSpace(line) == "" {
+			r.held += line
+			continue
+		}
+		sendThinking(r.c, r.model, r.held)
+		r.held = line
+	}
+	if strings.TrimSpace(r.partial) != "" && r.held != "" {
+		sendThinking(r.c, r.model, r.held)
+		r.held = ""
+	}
+}
+
+// finish forwards the rest of the reasoning and returns the reply's last line
+// as the answer, without an "Answer:" label
+func (r *reasoningStream) finish() string {
+	answer := r.partial
+	if strings.TrimSpace(answer) == "" {
+		answer, r.held = r.held, ""
+	}
+	sendThinking(r.c, r.model, r.held)
+	r.held, r.partial = "", ""
+	return answerLabel.ReplaceAllString(strings.TrimSpace(answer), "")
+}
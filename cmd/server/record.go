@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// Recording is everything a provider call streamed, written when RecordDir
+// is set and served back when ReplayDir is
+type Recording struct {
+	Name         string           `json:"name"`
+	Provider     string           `json:"provider"`
+	Model        string           `json:"model"`
+	Prompt       string           `json:"prompt"`
+	Messages     []ChatMessage    `json:"messages,omitempty"`
+	StartedAt    time.Time        `json:"startedAt"`
+	Chunks       []RecordedChunk  `json:"chunks"`
+	Response     string           `json:"response"` // Final text as the provider returned it
+	Outcome      string           `json:"outcome"`
+	Error        string           `json:"error,omitempty"`
+	FirstChunkMs int64            `json:"firstChunkMs,omitempty"`
+	DurationMs   int64            `json:"durationMs"`
+	Usage        *providers.Usage `json:"usage,omitempty"`
+}
+
+// RecordedChunk is one piece of a streamed response
+type RecordedChunk struct {
+	AtMs int64  `json:"atMs"` // Since the call started
+	Type string `json:"type"` // "guess" or "thinking"
+	Text string `json:"text"`
+}
+
+// recorder collects a call's chunks as they arrive. A nil recorder records
+// nothing, so callers don't need to check whether recording is on.
+type recorder struct {
+	mu       sync.Mutex
+	rec      Recording
+	modelCfg ModelConfig // Names the recording, see recordingPath
+}
+
+func startRecording(modelCfg ModelConfig, prompt string, messages []ChatMessage) *recorder {
+	return &recorder{rec: Recording{
+		Name:      modelCfg.Name,
+		Provider:  modelCfg.Provider,
+		Model:     modelCfg.Model,
+		Prompt:    prompt,
+		Messages:  messages,
+		StartedAt: time.Now(),
+	}, modelCfg: modelCfg}
+}
+
+func (r *recorder) add(chunkType, text string) {
+	if r == nil || text == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	at := time.Since(r.rec.StartedAt).Milliseconds()
+	if len(r.rec.Chunks) == 0 {
+		r.rec.FirstChunkMs = at
+	}
+	r.rec.Chunks = append(r.rec.Chunks, RecordedChunk{AtMs: at, Type: chunkType, Text: text})
+}
+
+func (r *recorder) usage(usage providers.Usage) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rec.Usage = &usage
+}
+
+// save writes the finished recording to dir, replacing any earlier recording
+// of the same prompt
+func (r *recorder) save(dir, response string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rec.Response = response
+	r.rec.Outcome = providers.OutcomeOf(err)
+	if err != nil {
+		r.rec.Error = err.Error()
+	}
+	r.rec.DurationMs = time.Since(r.rec.StartedAt).Milliseconds()
+
+	data, _ := json.MarshalIndent(r.rec, "", "  ")
+	os.MkdirAll(dir, 0755)
+	path := recordingPath(dir, r.modelCfg, r.rec.Prompt, r.rec.Messages)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Printf("Error saving recording for %s: %v\n", r.rec.Name, err)
+	}
+}
+
+// recordingPath names a recording by the same request hash as the response
+// cache
+func recordingPath(dir string, modelCfg ModelConfig, prompt string, messages []ChatMessage) string {
+	return filepath.Join(dir, filepath.Base(responseCachePath(modelCfg, prompt, messages)))
+}
+
+// replayRecording plays back the recording of the same request with its
+// original timing, through the same callbacks a provider would use. A prompt
+// that was never recorded is an error, so a replayed game never reaches a
+// real provider.
+func replayRecording(ctx context.Context, dir string, modelCfg ModelConfig, prompt string, messages []ChatMessage, onToken, onThinking func(string)) (string, error) {
+	file, err := os.ReadFile(recordingPath(dir, modelCfg, prompt, messages))
+	if err != nil {
+		return "", fmt.Errorf("no recording of this prompt for %s: %w", modelCfg.Name, err)
+	}
+	var rec Recording
+	if err := json.Unmarshal(file, &rec); err != nil {
+		return "", fmt.Errorf("unreadable recording for %s: %w", modelCfg.Name, err)
+	}
+
+	start := time.Now()
+	for _, chunk := range rec.Chunks {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(time.Until(start.Add(time.Duration(chunk.AtMs) * time.Millisecond))):
+		}
+		if chunk.Type == "thinking" {
+			onThinking(chunk.Text)
+		} else {
+			onToken(chunk.Text)
+		}
+	}
+
+	switch rec.Outcome {
+	case providers.OutcomeTruncated:
+		return rec.Response, providers.ErrResponseTruncated
+	case providers.OutcomeFiltered:
+		return rec.Response, providers.ErrContentFiltered
+	case providers.OutcomeErrored:
+		return rec.Response, errors.New(rec.Error)
+	}
+	return rec.Response, nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// TestSlowReplayCompletes replays a call that took far longer to start than
+// the connect and first-token deadlines allow. Nothing is connected to while
+// replaying, so it only answers to the total deadline.
+func TestSlowReplayCompletes(t *testing.T) {
+	replayDir := t.TempDir()
+	useConfig(t, Config{
+		Models:    []ModelConfig{{Name: "Recorded", Provider: "openai-compatible", Model: "test", Endpoint: "http://127.0.0.1:1"}},
+		Timeouts:  map[string]TimeoutConfig{"default": {ConnectSeconds: 0.1, FirstTokenSeconds: 0.1, TotalSeconds: 10}},
+		ReplayDir: replayDir,
+	})
+	model := currentConfig().Models[0]
+	prompt := "What has keys but can't open locks?"
+
+	data, err := json.Marshal(Recording{
+		Name:     model.Name,
+		Provider: model.Provider,
+		Model:    model.Model,
+		Prompt:   prompt,
+		Chunks:   []RecordedChunk{{AtMs: 500, Type: "guess", Text: "pia"}, {AtMs: 550, Type: "guess", Text: "no"}},
+		Response: "piano",
+		Outcome:  providers.OutcomeCompleted,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(recordingPath(replayDir, model, prompt, nil), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	game := newTestGame(t, "piano", model)
+	streamModelResponse(context.Background(), newClient(nil, nil), model, prompt, false, game)
+
+	state := game.ModelStates[model.Name]
+	if state.ErrorCategory != "" || !state.Correct {
+		t.Errorf("slow replay ended with %q, correct=%v; want a correct answer", state.ErrorCategory, state.Correct)
+	}
+}
+
+// TestSecretsNeverRecorded calls models whose provider and proxy both reject
+// them quoting the credentials back, with recording and request logging on,
+// and checks neither the key nor the proxy password turns up in the logs,
+// the recordings or the model's state
+func TestSecretsNeverRecorded(t *testing.T) {
+	const key, proxyPassword = "sk-live-0123456789abcdef", "proxy-pass-9876"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, `{"error": {"message": "Incorrect API key provided: %s %s", "type": "invalid_api_key"}}`,
+			r.Header.Get("Authorization"), r.Header.Get("Proxy-Authorization"))
+	}))
+	t.Cleanup(server.Close)
+
+	recordDir := t.TempDir()
+	useConfig(t, Config{
+		Models: []ModelConfig{
+			{Name: "Direct", Provider: "openai-compatible", Model: "test", Endpoint: server.URL, APIKey: key},
+			// Plain HTTP through a proxy, so the proxy sees the whole request
+			{Name: "Proxied", Provider: "openai-compatible", Model: "test-proxied", Endpoint: "http://llm.internal/v1", APIKey: key,
+				Proxy: "http://alice:" + proxyPassword + "@" + strings.TrimPrefix(server.URL, "http://")},
+		},
+		RecordDir:           recordDir,
+		LogProviderRequests: true,
+	})
+	t.Cleanup(func() { providers.SetRequestLogging(false) })
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	models := currentConfig().Models
+	game := newTestGame(t, "piano", models...)
+	for _, model := range models {
+		streamModelResponse(context.Background(), newClient(nil, nil), model, "What has keys but can't open locks?", false, game)
+	}
+
+	recordings, _ := filepath.Glob(filepath.Join(recordDir, "*"))
+	if len(recordings) != len(models) {
+		t.Fatalf("%d recordings, want %d", len(recordings), len(models))
+	}
+	state, _ := json.Marshal(game.ModelStates)
+	places := map[string]string{"logs": logged.String(), "model states": string(state)}
+	for _, path := range recordings {
+		data, _ := os.ReadFile(path)
+		places[filepath.Base(path)] = string(data)
+	}
+	if !strings.Contains(places["model states"], "Incorrect API key") {
+		t.Fatalf("calls didn't fail as the provider said: %s", state)
+	}
+	for place, text := range places {
+		for _, secret := range []string{key, proxyPassword} {
+			if strings.Contains(text, secret) {
+				t.Errorf("%s hold %q:\n%s", place, secret, text)
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// outcomeSkipped is the outcome of a round whose reply declined to guess
+const outcomeSkipped = "skipped"
+
+// Phrases a model uses to decline to guess
+var refusalPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:i'?m|i am) (?:not (?:sure|certain)|unsure|uncertain)\b`),
+	regexp.MustCompile(`\bi (?:do not|don'?t) (?:know|have an answer)\b`),
+	regexp.MustCompile(`\bi (?:can ?not|can'?t|am unable to|'?m unable to|could ?n'?t|could not) (?:determine|answer|solve|tell|figure out|guess|say|work out)\b`),
+	regexp.MustCompile(`\b(?:not enough|insufficient) (?:information|context|clues)\b`),
+	regexp.MustCompile(`\b(?:no idea|not sure|unknown|pass|skip)\b`),
+}
+
+// refusalFillers are the words a refusal pads itself out with. A reply that
+// matches a refusal pattern is only a refusal if nothing else is left, so
+// "I'm not sure, maybe a candle" is still a guess.
+var refusalFillers = map[string]bool{
+	"i": true, "im": true, "me": true, "my": true, "sorry": true, "but": true,
+	"a": true, "an": true, "the": true, "this": true, "that": true, "it": true,
+	"is": true, "to": true, "of": true, "from": true, "with": true, "on": true,
+	"in": true, "without": true, "based": true, "be": true, "can": true,
+	"answer": true, "riddle": true, "information": true, "given": true,
+	"provided": true, "clue": true, "clues": true, "context": true, "enough": true,
+	"more": true, "yet": true, "really": true, "honestly": true, "exactly": true,
+	"what": true, "here": true, "so": true, "afraid": true, "unfortunately": true,
+	"ill": true, "will": true, "have": true, "just": true, "one": true, "round": true,
+}
+
+// isRefusal reports whether a cleaned reply declines to guess, such as "I'm
+// not sure" or "I cannot determine the answer from the information given",
+// rather than giving a wrong answer. It is only asked of replies that didn't
+// match, so a riddle whose answer is "pass" still works.
+func isRefusal(reply string) bool {
+	text := strings.ToLower(smartQuotes.Replace(reply))
+	matched := false
+	for _, pattern := range refusalPatterns {
+		if pattern.MatchString(text) {
+			text = pattern.ReplaceAllString(text, " ")
+			matched = true
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	words := strings.FieldsFunc(strings.ReplaceAll(text, "'", ""), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for _, word := range words {
+		if !refusalFillers[word] {
+			return false
+		}
+	}
+	return true
+}
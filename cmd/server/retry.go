@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// RetryConfig sets how a provider's transient failures are retried. Zero
+// values fall back to the defaults below.
+type RetryConfig struct {
+	MaxAttempts int `json:"maxAttempts"` // Attempts including the first; 1 disables retries
+	BaseDelayMs int `json:"baseDelayMs"` // Delay before the first retry, doubled for each one after
+}
+
+var defaultRetries = RetryConfig{
+	MaxAttempts: 3,
+	BaseDelayMs: 500,
+}
+
+// Retries wait by these, so tests can run the clock
+var (
+	retryNow   = time.Now
+	retryAfter = time.After
+)
+
+// retryableStatus are the provider responses worth another attempt: rate
+// limits and server errors that are usually gone a moment later
+var retryableStatus = map[int]bool{
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+}
+
+// retriesFor returns the retry policy for a provider, filling unset values
+// from the "default" entry and then the built-in defaults
+func retriesFor(provider string) RetryConfig {
+	configured := currentConfig().Retries
+	r := configured[provider]
+	fallback := configured["default"]
+
+	pick := func(values ...int) int {
+		for _, v := range values {
+			if v > 0 {
+				return v
+			}
+		}
+		return 0
+	}
+	return RetryConfig{
+		MaxAttempts: pick(r.MaxAttempts, fallback.MaxAttempts, defaultRetries.MaxAttempts),
+		BaseDelayMs: pick(r.BaseDelayMs, fallback.BaseDelayMs, defaultRetries.BaseDelayMs),
+	}
+}
+
+// callWithRetry makes up to policy.MaxAttempts calls, backing off between
+// them. Only failures that happen before anything was streamed are retried,
+// so the client never sees the same tokens twice. attempts reports how many
+// calls were made.
+func callWithRetry(ctx context.Context, modelCfg ModelConfig, policy RetryConfig, call func(ctx context.Context) (string, bool, error)) (response string, simulated bool, attempts int, err error) {
+	for attempts = 1; ; attempts++ {
+		response, simulated, err = call(ctx)
+		if err == nil || attempts >= policy.MaxAttempts || !simulated {
+			return response, simulated, attempts, err
+		}
+
+		var providerErr *providers.ProviderError
+		if !errors.As(err, &providerErr) || !retryableStatus[providerErr.StatusCode] {
+			return response, simulated, attempts, err
+		}
+
+		delay := providerErr.RetryAfter
+		if delay <= 0 {
+			delay = backoff(policy, attempts)
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Sub(retryNow()) < delay {
+			return response, simulated, attempts, err
+		}
+
+		log.Printf("Retrying %s in %v after attempt %d: %v\n", modelCfg.Name, delay, attempts, err)
+		select {
+		case <-ctx.Done():
+			return response, simulated, attempts, err
+		case <-retryAfter(delay):
+		}
+	}
+}
+
+// backoff is the wait before retry number attempt: the base delay doubled per
+// retry, half of it randomized so parallel games don't retry in lockstep
+func backoff(policy RetryConfig, attempt int) time.Duration {
+	delay := time.Duration(policy.BaseDelayMs) * time.Millisecond << (attempt - 1)
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
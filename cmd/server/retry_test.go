@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// useFakeRetryClock runs retries on clock for the rest of the test
+func useFakeRetryClock(t *testing.T) *fakeClock {
+	clock := newFakeClock()
+	retryNow, retryAfter = clock.Now, clock.After
+	t.Cleanup(func() { retryNow, retryAfter = time.Now, time.After })
+	return clock
+}
+
+// failingCall fails with each error in turn, then succeeds
+func failingCall(errs ...error) (call func(context.Context) (string, bool, error), calls *int) {
+	calls = new(int)
+	return func(context.Context) (string, bool, error) {
+		*calls++
+		if *calls <= len(errs) {
+			return "", true, errs[*calls-1]
+		}
+		return "piano", true, nil
+	}, calls
+}
+
+// TestBackoff doubles the delay for each retry, with up to half of it
+// randomized
+func TestBackoff(t *testing.T) {
+	policy := RetryConfig{MaxAttempts: 5, BaseDelayMs: 1000}
+	for attempt, full := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second} {
+		for i := 0; i < 100; i++ {
+			if delay := backoff(policy, attempt); delay < full/2 || delay > full {
+				t.Fatalf("backoff after attempt %d = %v, want between %v and %v", attempt, delay, full/2, full)
+			}
+		}
+	}
+}
+
+// TestRetryWaitsOutBackoff retries a server error after each backoff, and
+// doesn't make the next attempt until the clock says so
+func TestRetryWaitsOutBackoff(t *testing.T) {
+	clock := useFakeRetryClock(t)
+	unavailable := &providers.ProviderError{Provider: "openai", StatusCode: http.StatusServiceUnavailable}
+	call, calls := failingCall(unavailable, unavailable)
+
+	type result struct {
+		response string
+		attempts int
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		response, _, attempts, err := callWithRetry(context.Background(), ModelConfig{Name: "GPT"},
+			RetryConfig{MaxAttempts: 3, BaseDelayMs: 1000}, call)
+		done <- result{response, attempts, err}
+	}()
+
+	for retry, full := range []time.Duration{time.Second, 2 * time.Second} {
+		wait := clock.Waiting(t)
+		if wait < full/2 || wait > full {
+			t.Errorf("retry %d waits %v, want between %v and %v", retry+1, wait, full/2, full)
+		}
+		clock.Advance(wait - time.Millisecond)
+		select {
+		case <-done:
+			t.Fatalf("retry %d made before its backoff ran out", retry+1)
+		case <-time.After(20 * time.Millisecond):
+		}
+		clock.Advance(time.Millisecond)
+	}
+
+	select {
+	case r := <-done:
+		if r.err != nil || r.response != "piano" || r.attempts != 3 || *calls != 3 {
+			t.Errorf("callWithRetry = %q after %d attempts (%d calls), %v; want piano after 3", r.response, r.attempts, *calls, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("callWithRetry never finished")
+	}
+}
+
+// TestRetryHonoursRetryAfter waits as long as the provider asked rather than
+// the backoff
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	clock := useFakeRetryClock(t)
+	limited := &providers.ProviderError{Provider: "openai", StatusCode: http.StatusTooManyRequests, RetryAfter: 7 * time.Second}
+	call, _ := failingCall(limited)
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, _, err := callWithRetry(context.Background(), ModelConfig{Name: "GPT"}, RetryConfig{MaxAttempts: 2, BaseDelayMs: 100}, call)
+		done <- err
+	}()
+	if wait := clock.Waiting(t); wait != 7*time.Second {
+		t.Errorf("retry waits %v, want the 7s the provider asked for", wait)
+	}
+	clock.Advance(7 * time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("callWithRetry: %v", err)
+	}
+}
+
+// TestRetryGivesUp doesn't retry when the round would end first, or when
+// the failure isn't one a retry fixes
+func TestRetryGivesUp(t *testing.T) {
+	clock := useFakeRetryClock(t)
+	policy := RetryConfig{MaxAttempts: 3, BaseDelayMs: 1000}
+
+	ctx, cancel := context.WithDeadline(context.Background(), clock.Now().Add(400*time.Millisecond))
+	defer cancel()
+	call, calls := failingCall(&providers.ProviderError{Provider: "openai", StatusCode: http.StatusBadGateway})
+	if _, _, attempts, err := callWithRetry(ctx, ModelConfig{Name: "GPT"}, policy, call); err == nil || attempts != 1 || *calls != 1 {
+		t.Errorf("with the round ending before the backoff: %d attempts, %v; want 1 attempt and its error", attempts, err)
+	}
+
+	call, calls = failingCall(&providers.ProviderError{Provider: "openai", StatusCode: http.StatusUnauthorized})
+	if _, _, attempts, err := callWithRetry(context.Background(), ModelConfig{Name: "GPT"}, policy, call); err == nil || attempts != 1 || *calls != 1 {
+		t.Errorf("after a 401: %d attempts, %v; want 1 attempt and its error", attempts, err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+const defaultRoom = "default"
+
+// room is an isolated arena with its own stats and leaderboard. Model config,
+// providers and their health are shared by every room.
+type room struct {
+	name           string
+	dir            string
+	stats          Stats
+	statsMux       sync.Mutex
+	leaderboard    []LeaderboardEntry
+	leaderboardMux sync.Mutex
+	archiveMux     sync.Mutex
+	gamesRecorded  int // Bumped under leaderboardMux for every finished game
+	calibration    calibrationCache
+}
+
+var rooms = make(map[string]*room)
+
+// loadRooms loads the default room plus every room listed in config
+func loadRooms() {
+	migrateDefaultRoom()
+
+	names := append([]string{defaultRoom}, currentConfig().Rooms...)
+	for _, name := range names {
+		if _, exists := rooms[name]; exists {
+			continue
+		}
+		if !validRoomName(name) {
+			log.Fatalf("Invalid room name in config: %q\n", name)
+		}
+
+		rm := &room{name: name, dir: dataDir + "rooms/" + name + "/"}
+		os.MkdirAll(rm.dir, 0755)
+		rm.loadStats()
+		rm.loadLeaderboard()
+		rm.pruneArchive()
+		rooms[name] = rm
+	}
+	log.Printf("Loaded %d rooms\n", len(rooms))
+}
+
+func validRoomName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// migrateDefaultRoom moves data files from before rooms existed into the
+// default room's directory
+func migrateDefaultRoom() {
+	dir := dataDir + "rooms/" + defaultRoom + "/"
+	os.MkdirAll(dir, 0755)
+
+	for _, file := range []string{"stats.json", "leaderboard.json", "leaderboard_archive.jsonl"} {
+		if _, err := os.Stat(dataDir + file); err != nil {
+			continue
+		}
+		if _, err := os.Stat(dir + file); err == nil {
+			log.Printf("Both %s and %s exist, leaving the old file in place\n", dataDir+file, dir+file)
+			continue
+		}
+		if err := os.Rename(dataDir+file, dir+file); err != nil {
+			log.Fatalf("Error migrating %s into the default room: %v\n", file, err)
+		}
+		log.Printf("Migrated %s into the default room\n", file)
+	}
+}
+
+type roomKey struct{}
+
+// roomFor returns the room a request addresses: the /rooms/{room} path
+// prefix, else the ?room= query parameter, else the default room
+func roomFor(r *http.Request) (*room, bool) {
+	if rm, ok := r.Context().Value(roomKey{}).(*room); ok {
+		return rm, true
+	}
+	name := r.URL.Query().Get("room")
+	if name == "" {
+		name = defaultRoom
+	}
+	rm, ok := rooms[name]
+	return rm, ok
+}
+
+// withRoom resolves the request's room for a handler, rejecting unknown rooms
+func withRoom(next func(w http.ResponseWriter, r *http.Request, rm *room)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rm, ok := roomFor(r)
+		if !ok {
+			http.Error(w, "unknown room", http.StatusNotFound)
+			return
+		}
+		next(w, r, rm)
+	}
+}
+
+// roomRouter serves /rooms/{room}/... by stripping the prefix and dispatching
+// to the same handlers the unprefixed routes use for the default room
+func roomRouter(roomMux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/rooms/"), "/")
+		rm, ok := rooms[name]
+		if !ok {
+			http.Error(w, "unknown room", http.StatusNotFound)
+			return
+		}
+
+		r2 := r.Clone(context.WithValue(r.Context(), roomKey{}, rm))
+		r2.URL.Path = "/" + rest
+		r2.URL.RawPath = ""
+		roomMux.ServeHTTP(w, r2)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	codeFencePattern = regexp.MustCompile("(?s)^```[\\w-]*\\s*(.*?)\\s*```$")
+	// A label a completion model writes before its answer, often after
+	// echoing the riddle back
+	answerLabelPattern = regexp.MustCompile(`(?im)^\s*(?:answer|a)\s*:\s*`)
+	smartQuotes        = strings.NewReplacer("“", "\"", "”", "\"", "‘", "'", "’", "'")
+	// Bullets and numbers that start the items of a markdown list
+	listBulletPattern = regexp.MustCompile(`(?m)^\s*(?:[-*+•]|\d+[.)])\s+`)
+	// Bold, italic and code markers wrapped around words, wherever they are.
+	// The markers have to stand apart from the words around them, so
+	// snake_case and 2 * 3 are left alone.
+	emphasisPattern = regexp.MustCompile("(^|[^\\w*`])(\\*{1,3}|_{1,3}|`+)([^\\s*_`](?:[^*_`\n]*[^\\s*_`])?)(\\*{1,3}|_{1,3}|`+)($|[^\\w*`])")
+)
+
+// providerSanitizers clean up the habits of particular providers before the
+// shared rules run
+var providerSanitizers = map[string]func(string) string{
+	// Text-generation endpoints continue the prompt rather than answer it, so
+	// the reply can start with the riddle echoed back. Only what follows the
+	// last answer label is the guess.
+	"huggingface": func(raw string) string {
+		if labels := answerLabelPattern.FindAllStringIndex(raw, -1); len(labels) > 0 {
+			return raw[labels[len(labels)-1][1]:]
+		}
+		return raw
+	},
+}
+
+// Characters trimmed from either end of a guess: punctuation, quotes and
+// markdown emphasis
+const guessTrimChars = " \t\n.,!?;:\"'`*_"
+
+// sanitizeResponse strips the formatting providers wrap around a guess: code
+// fences, quotes, markdown emphasis and lists, punctuation and stray
+// whitespace. It runs on the final response before it is checked and shown,
+// never on the streamed tokens.
+func sanitizeResponse(provider, raw string) string {
+	text := raw
+	if sanitize, ok := providerSanitizers[provider]; ok {
+		text = sanitize(text)
+	}
+
+	text = strings.TrimSpace(smartQuotes.Replace(text))
+	if match := codeFencePattern.FindStringSubmatch(text); match != nil {
+		text = match[1]
+	}
+	// Adjacent emphasis shares the space between, so it takes another pass
+	for i := 0; i < 3 && emphasisPattern.MatchString(text); i++ {
+		text = emphasisPattern.ReplaceAllString(text, "$1$3$5")
+	}
+
+	// A list answer keeps its items, one after another
+	if listBulletPattern.MatchString(text) {
+		var items []string
+		for _, line := range strings.Split(listBulletPattern.ReplaceAllString(text, ""), "\n") {
+			if line = strings.Trim(line, guessTrimChars); line != "" {
+				items = append(items, line)
+			}
+		}
+		text = strings.Join(items, ", ")
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	return strings.Trim(text, guessTrimChars)
+}
+
+// decorative reports whether r dresses up a guess rather than being part of
+// it: emoji and other symbols, skin tones, variation selectors, the joiners
+// and keycaps of emoji sequences, and tag characters
+func decorative(r rune) bool {
+	return unicode.In(r, unicode.So, unicode.Sk, unicode.Variation_Selector) ||
+		r == '\u200d' || r == '\u20e3' || (r >= 0xe0020 && r <= 0xe007f)
+}
+
+// stripDecorations removes emoji and decorative symbols from a cleaned guess,
+// so "🕯️ A candle!" becomes "A candle". Riddles whose answer is a symbol
+// turn this off with exactMatch.
+func stripDecorations(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if decorative(r) {
+			return ' '
+		}
+		return r
+	}, text)
+	text = strings.Join(strings.Fields(text), " ")
+	return strings.Trim(text, guessTrimChars)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestSanitizeResponse runs replies captured from each provider through the
+// shared clean-up, so a provider changing its formatting habits shows up here
+func TestSanitizeResponse(t *testing.T) {
+	tests := []struct {
+		provider string
+		raw      string
+		want     string
+	}{
+		// Ollama models fence short answers like code
+		{"ollama", "```\nPiano\n```", "Piano"},
+		{"ollama", "```text\na piano\n```\n", "a piano"},
+		{"llamacpp", "```\nfootsteps\n```", "footsteps"},
+		// Claude quotes its answer with typographic quotes
+		{"anthropic", "“A piano”", "A piano"},
+		{"anthropic", "‘Footsteps’.", "Footsteps"},
+		// Gemini starts its reply on a new line
+		{"google", "\nA piano\n", "A piano"},
+		{"vertex", "\n\nAn echo.  \n", "An echo"},
+		// Text-generation endpoints echo the prompt before the answer label
+		{"huggingface", "Riddle: What has keys but can't open locks?\nAnswer: A piano", "A piano"},
+		{"huggingface", "Q: What has keys but can't open locks?\nA: a piano.", "a piano"},
+		{"huggingface", "What has keys but can't open locks?\nanswer:piano", "piano"},
+		{"huggingface", "A piano", "A piano"},
+		// Chat APIs mostly just punctuate
+		{"openai", "A piano.", "A piano"},
+		{"mistral", "\"Piano!\"", "Piano"},
+		{"groq", "A   grand\n piano", "A grand piano"},
+		{"cohere", "Piano", "Piano"},
+		// Only HuggingFace replies are cut at an answer label
+		{"openai", "Answer: a piano", "Answer: a piano"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.raw, func(t *testing.T) {
+			if got := sanitizeResponse(tt.provider, tt.raw); got != tt.want {
+				t.Errorf("sanitizeResponse(%q, %q) = %q, want %q", tt.provider, tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSanitizeMarkdown checks markdown around captured guesses is stripped,
+// and markers that are part of the guess are not
+func TestSanitizeMarkdown(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"**Piano**", "Piano"},
+		{"***Piano***", "Piano"},
+		{"*a piano*", "a piano"},
+		{"The answer is **a piano**!", "The answer is a piano"},
+		{"`piano`", "piano"},
+		{"``piano``", "piano"},
+		{"- piano\n- keyboard", "piano, keyboard"},
+		{"* **piano**", "piano"},
+		{"• piano", "piano"},
+		{"1. piano\n2. organ", "piano, organ"},
+		{"1) footsteps", "footsteps"},
+		{"a piano!!!", "a piano"},
+		// Adjacent emphasis shares the space between, so takes more passes
+		{"**bold** *italic* _under_ `code`", "bold italic under code"},
+		{"***a*** ***b*** ***c*** ***d***", "a b c d"},
+		{"_a_ _b_ _c_ _d_", "a b c d"},
+		// Markers inside words or between numbers aren't emphasis
+		{"snake_case_name", "snake_case_name"},
+		{"a snake_case answer", "a snake_case answer"},
+		{"2 * 3", "2 * 3"},
+		{"2*3", "2*3"},
+		{"2 * 3 * 4", "2 * 3 * 4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if got := sanitizeResponse("openai", tt.raw); got != tt.want {
+				t.Errorf("sanitizeResponse(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStoredGuessWithoutMarkdown plays replies wrapped in markdown and checks
+// the guess is stored, and reaches the leaderboard, without it
+func TestStoredGuessWithoutMarkdown(t *testing.T) {
+	for _, reply := range []string{"**Piano**", "`Piano`", "- *Piano*", "1. __Piano__!"} {
+		t.Run(reply, func(t *testing.T) {
+			useConfig(t, Config{Models: []ModelConfig{
+				{Name: "Fancy", Provider: "openai-compatible", Model: "test", Endpoint: replyingServer(t, reply).URL},
+			}})
+			model := currentConfig().Models[0]
+			game := newTestGame(t, "piano", model)
+
+			streamModelResponse(context.Background(), newClient(nil, nil), model, game.Riddle, false, game)
+
+			state := game.ModelStates[model.Name]
+			if state.Guess != "Piano" || !slices.Equal(state.AllGuesses, []string{"Piano"}) || !state.Correct {
+				t.Errorf("stored guess %q, history %q, correct=%v; want Piano, correct", state.Guess, state.AllGuesses, state.Correct)
+			}
+			rm := rooms[defaultRoom]
+			rm.addToLeaderboard(game, GameResult{CorrectCount: 1, TotalModels: 1, RoundsPlayed: 1, Timestamp: time.Now()})
+			if final := rm.leaderboard[0].Models[0].FinalGuess; final != "Piano" {
+				t.Errorf("leaderboard final guess %q, want Piano", final)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestChosenModels submits riddles picking their models, and checks a pick
+// that isn't a set of enabled models, or is too large, is rejected before
+// the game starts
+func TestChosenModels(t *testing.T) {
+	disabled := false
+	models := []ModelConfig{{Name: "Off", Provider: "mock", Model: "always-correct", Enabled: &disabled}}
+	for i := 1; i <= 4; i++ {
+		models = append(models, ModelConfig{Name: fmt.Sprintf("M%d", i), Provider: "mock", Model: "always-correct"})
+	}
+	useConfig(t, Config{Models: models, MaxChosenModels: 3})
+	url := serveGames(t)
+
+	tests := []struct {
+		name    string
+		chosen  []string
+		code    string   // Error code, "" if the game starts
+		rejects []string // Names reported as unknown
+		plays   []string // Models the game starts with
+	}{
+		{"chosen", []string{"M2", "M1"}, "", nil, []string{"M1", "M2"}},
+		{"repeated", []string{"M1", "M1", "M2", "M3", "M3"}, "", nil, []string{"M1", "M2", "M3"}},
+		{"unknown", []string{"M1", "Nobody"}, "unknownModels", []string{"Nobody"}, nil},
+		{"disabled", []string{"Off", "M1"}, "unknownModels", []string{"Off"}, nil},
+		{"case matters", []string{"m1"}, "unknownModels", []string{"m1"}, nil},
+		{"too many", []string{"M1", "M2", "M3", "M4"}, "tooManyModels", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := dial(t, url, "")
+			if err := conn.WriteJSON(RiddleSubmission{
+				Riddle:     "What has keys but can't open locks?",
+				Answers:    []string{"piano"},
+				Difficulty: "easy",
+				Username:   "tester",
+				Models:     tt.chosen,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+			var message map[string]interface{}
+			for message["type"] != "error" && message["type"] != "gameStart" {
+				message = nil
+				if err := conn.ReadJSON(&message); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.code != "" {
+				if message["type"] != "error" || message["code"] != tt.code {
+					t.Fatalf("got %v, want a %s error", message, tt.code)
+				}
+				var rejected []string
+				for _, name := range asSlice(message["models"]) {
+					rejected = append(rejected, name.(string))
+				}
+				if !slices.Equal(rejected, tt.rejects) {
+					t.Errorf("rejected %v, want %v", rejected, tt.rejects)
+				}
+				return
+			}
+			if message["type"] != "gameStart" {
+				t.Fatalf("got %v, want the game to start", message)
+			}
+			var playing []string
+			for _, model := range asSlice(message["selectedModels"]) {
+				playing = append(playing, model.(map[string]interface{})["name"].(string))
+			}
+			slices.Sort(playing)
+			if !slices.Equal(playing, tt.plays) {
+				t.Errorf("playing %v, want %v", playing, tt.plays)
+			}
+		})
+	}
+}
+
+// TestUnknownModelsUsesSnapshot checks a pick is validated against the
+// config it will be selected from, not whatever is running by then
+func TestUnknownModelsUsesSnapshot(t *testing.T) {
+	useConfig(t, Config{Models: []ModelConfig{{Name: "M1", Provider: "mock", Model: "always-correct"}}})
+	disabled := false
+	snapshot := &Config{Models: []ModelConfig{{Name: "M1", Provider: "mock", Model: "always-correct", Enabled: &disabled}}}
+
+	if unknown := unknownModels(snapshot, []string{"M1"}); !slices.Equal(unknown, []string{"M1"}) {
+		t.Errorf("unknownModels = %v, want M1, disabled in the snapshot", unknown)
+	}
+	if selected, _ := selectModels(snapshot, []string{"M1"}); len(selected) != 0 {
+		t.Errorf("selected %v from a snapshot where it is disabled", selected)
+	}
+}
+
+func asSlice(v interface{}) []interface{} {
+	s, _ := v.([]interface{})
+	return s
+}
@@ -0,0 +1,61 @@
+package main
+
+import "strings"
+
+const (
+	// maxSharedGuesses and maxSharedGuessChars keep the other models' wrong
+	// guesses from crowding out the riddle in the prompt
+	maxSharedGuesses    = 10
+	maxSharedGuessChars = 200
+)
+
+// triedGuess is one model's wrong guess, for games with SharedGuesses on
+type triedGuess struct {
+	model string
+	guess string
+}
+
+// snapshotSharedGuesses records every model's wrong guesses so far, newest
+// round first, before the round's models start. Prompts read the snapshot
+// rather than the live states the models are writing to.
+func snapshotSharedGuesses(game *GameState) {
+	if !game.SharedGuesses {
+		return
+	}
+	var tried []triedGuess
+	for i := game.CurrentRound; i >= 0; i-- {
+		for _, modelCfg := range game.SelectedModels {
+			state := game.ModelStates[modelCfg.Name]
+			if i < len(state.AllGuesses) && !state.GuessResults[i] {
+				tried = append(tried, triedGuess{model: modelCfg.Name, guess: state.AllGuesses[i]})
+			}
+		}
+	}
+	game.sharedTried = tried
+}
+
+// othersTried is what the other models have already guessed wrong, for a
+// model's prompt: deduplicated ignoring case, without the model's own
+// guesses, and cut off at maxSharedGuesses or maxSharedGuessChars
+func othersTried(game *GameState, modelName string, own []string) []string {
+	seen := make(map[string]bool)
+	for _, guess := range own {
+		seen[strings.ToLower(strings.TrimSpace(guess))] = true
+	}
+
+	var guesses []string
+	length := 0
+	for _, tried := range game.sharedTried {
+		key := strings.ToLower(strings.TrimSpace(tried.guess))
+		if tried.model == modelName || key == "" || tried.guess == moderationMask || seen[key] {
+			continue
+		}
+		if len(guesses) == maxSharedGuesses || length+len(key) > maxSharedGuessChars {
+			break
+		}
+		seen[key] = true
+		length += len(key)
+		guesses = append(guesses, strings.TrimSpace(tried.guess))
+	}
+	return guesses
+}
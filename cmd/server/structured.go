@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// structuredAnswerInstruction is added to the prompt of models with
+// structuredAnswers on. Providers in JSON mode also need the prompt itself to
+// ask for JSON.
+const structuredAnswerInstruction = "\n\nRespond in JSON as {\"answer\": \"your answer\", \"confidence\": 0.8}, where confidence is between 0 and 1."
+
+// StructuredAnswer is the JSON reply asked of models with structuredAnswers on
+type StructuredAnswer struct {
+	Answer     string   `json:"answer"`
+	Confidence *float64 `json:"confidence"`
+}
+
+// parseStructuredAnswer pulls the answer and confidence out of a JSON reply.
+// Anything that isn't a JSON object with an answer is reported as not ok, so
+// the caller can fall back to checking the raw text.
+func parseStructuredAnswer(reply string) (answer string, confidence *float64, ok bool) {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start == -1 || end < start {
+		return "", nil, false
+	}
+
+	var parsed StructuredAnswer
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &parsed); err != nil {
+		return "", nil, false
+	}
+	answer = strings.TrimSpace(parsed.Answer)
+	if answer == "" {
+		return "", nil, false
+	}
+	if c := parsed.Confidence; c != nil {
+		clamped := min(max(*c, 0), 1)
+		confidence = &clamped
+	}
+	return answer, confidence, true
+}
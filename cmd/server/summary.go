@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// SummaryConfig overrides the templates used for the end-of-game summary.
+// Templates are Go text/template over GameSummary.
+type SummaryConfig struct {
+	Text     string `json:"text"`
+	Markdown string `json:"markdown"`
+}
+
+const defaultSummaryText = `{{if .PlayerWins}}You stumped {{list .Stumped}} with {{article .Difficulty}} riddle in {{.Rounds}} {{plural .Rounds "round" "rounds"}} — {{.Score}} points, #{{.RankToday}} today` +
+	`{{else if not .Stumped}}All {{.TotalModels}} AIs solved your {{.Difficulty}} riddle in {{.Rounds}} {{plural .Rounds "round" "rounds"}}` +
+	`{{else}}No AI solved your {{.Difficulty}} riddle in {{.Rounds}} {{plural .Rounds "round" "rounds"}}, so the AIs take this one{{end}}`
+
+const defaultSummaryMarkdown = `{{if .PlayerWins}}You stumped {{bold .Stumped | list}} with {{article .Difficulty}} riddle in {{.Rounds}} {{plural .Rounds "round" "rounds"}} — **{{.Score}} points**, #{{.RankToday}} today` +
+	`{{else if not .Stumped}}All {{.TotalModels}} AIs solved your **{{.Difficulty}}** riddle in {{.Rounds}} {{plural .Rounds "round" "rounds"}}` +
+	`{{else}}No AI solved your **{{.Difficulty}}** riddle in {{.Rounds}} {{plural .Rounds "round" "rounds"}}, so the AIs take this one{{end}}`
+
+// GameSummary is the data the summary templates see. It never includes the
+// answer or model guesses so summaries are safe to share.
+type GameSummary struct {
+	Username    string
+	PlayerWins  bool
+	Difficulty  string
+	Rounds      int
+	Score       int
+	RankToday   int // Position among today's games in the room by score
+	TotalModels int
+	Stumped     []string // Models that never solved the riddle
+	Solved      []string
+}
+
+var summaryFuncs = template.FuncMap{
+	"list": naturalList,
+	"bold": func(items []string) []string {
+		out := make([]string, len(items))
+		for i, item := range items {
+			out[i] = "**" + item + "**"
+		}
+		return out
+	},
+	"plural": func(n int, one string, many string) string {
+		if n == 1 {
+			return one
+		}
+		return many
+	},
+	"article": func(word string) string {
+		if word != "" && strings.ContainsRune("aeiouAEIOU", rune(word[0])) {
+			return "an " + word
+		}
+		return "a " + word
+	},
+}
+
+// naturalList joins items as "A", "A and B" or "A, B and C"
+func naturalList(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+func newGameSummary(game *GameState, result GameResult, rankToday int) GameSummary {
+	summary := GameSummary{
+		Username:    game.Username,
+		PlayerWins:  result.PlayerWins,
+		Difficulty:  game.Difficulty,
+		Rounds:      result.RoundsPlayed,
+		Score:       calculateScore(result),
+		RankToday:   rankToday,
+		TotalModels: result.TotalModels,
+	}
+	for _, modelCfg := range game.SelectedModels {
+		if game.ModelStates[modelCfg.Name].Correct {
+			summary.Solved = append(summary.Solved, modelCfg.Name)
+		} else {
+			summary.Stumped = append(summary.Stumped, modelCfg.Name)
+		}
+	}
+	return summary
+}
+
+// renderSummaries renders the plain-text and markdown summaries, falling back
+// to the built-in templates if a configured one fails
+func renderSummaries(summary GameSummary) (text string, markdown string) {
+	cfg := currentConfig().Summary
+	return renderSummary("text", cfg.Text, defaultSummaryText, summary),
+		renderSummary("markdown", cfg.Markdown, defaultSummaryMarkdown, summary)
+}
+
+func renderSummary(name string, configured string, fallback string, summary GameSummary) string {
+	if configured != "" {
+		out, err := executeSummaryTemplate(name, configured, summary)
+		if err == nil {
+			return out
+		}
+		log.Printf("Error rendering configured %s summary, using the default: %v\n", name, err)
+	}
+	out, err := executeSummaryTemplate(name, fallback, summary)
+	if err != nil {
+		log.Printf("Error rendering default %s summary: %v\n", name, err)
+	}
+	return out
+}
+
+func executeSummaryTemplate(name string, text string, summary GameSummary) (string, error) {
+	tmpl, err := template.New(name).Funcs(summaryFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, summary); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// rankToday returns where a score would place among the room's games from
+// today, counting from 1. Today's games trimmed from the top list into the
+// archive still count. It takes the leaderboard and archive locks itself, so
+// callers shouldn't hold gamesMux.
+func (rm *room) rankToday(score int, now time.Time) int {
+	y, m, d := now.Date()
+	rank := 1
+	for _, entry := range rm.allLeaderboardEntries() {
+		ey, em, ed := entry.Timestamp.Date()
+		if ey == y && em == m && ed == d && !entry.Hidden && entry.Score > score {
+			rank++
+		}
+	}
+	return rank
+}
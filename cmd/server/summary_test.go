@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden summaries in testdata/summaries")
+
+// TestSummarySnapshots renders the plain-text and markdown summaries of fixed
+// games and compares them with testdata/summaries, rewritten with -update
+func TestSummarySnapshots(t *testing.T) {
+	useConfig(t, Config{})
+	models := []ModelConfig{
+		{Name: "Alpha", Provider: "mock", Model: "always-correct"},
+		{Name: "Beta", Provider: "mock", Model: "always-wrong"},
+		{Name: "Gamma", Provider: "mock", Model: "always-wrong"},
+	}
+	tests := []struct {
+		name       string
+		difficulty string
+		correct    []string
+		rounds     int
+	}{
+		{"stumped", "easy", []string{"Alpha"}, 2},
+		{"stumped-one", "hard", []string{"Alpha", "Beta"}, 1},
+		{"all-solved", "medium", []string{"Alpha", "Beta", "Gamma"}, 1},
+		{"none-solved", "easy", nil, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			game := newTestGame(t, "piano", models...)
+			game.Difficulty = tt.difficulty
+			for _, name := range tt.correct {
+				game.ModelStates[name] = ModelState{Correct: true}
+			}
+			result := GameResult{
+				PlayerWins:   len(tt.correct) > 0 && len(tt.correct) < len(models),
+				CorrectCount: len(tt.correct),
+				TotalModels:  len(models),
+				Difficulty:   tt.difficulty,
+				Duration:     30,
+				RoundsPlayed: tt.rounds,
+			}
+			text, markdown := renderSummaries(newGameSummary(game, result, 2))
+			checkSummary(t, tt.name+".txt", text)
+			checkSummary(t, tt.name+".md", markdown)
+		})
+	}
+}
+
+func checkSummary(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "summaries", name)
+	if *update {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(got+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if want = bytes.TrimSuffix(want, []byte("\n")); got != string(want) {
+		t.Errorf("summary differs from %s:\ngot:  %s\nwant: %s", path, got, want)
+	}
+}
+
+// TestRankTodayCountsArchive ranks a score against today's games when some of
+// them have been trimmed from the top list into the archive
+func TestRankTodayCountsArchive(t *testing.T) {
+	useConfig(t, Config{Models: []ModelConfig{{Name: "Mock", Provider: "mock", Model: "always-correct"}}})
+	rm := rooms[defaultRoom]
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+
+	rm.leaderboardMux.Lock()
+	rm.leaderboard = []LeaderboardEntry{
+		{ID: "top-today", Score: 900, Timestamp: now},
+		{ID: "top-yesterday", Score: 950, Timestamp: yesterday},
+		{ID: "hidden-today", Score: 990, Timestamp: now, Hidden: true},
+	}
+	rm.leaderboardMux.Unlock()
+	rm.archiveEntries([]LeaderboardEntry{
+		{ID: "archived-today", Score: 600, Timestamp: now},
+		{ID: "archived-today-low", Score: 100, Timestamp: now},
+		{ID: "archived-yesterday", Score: 800, Timestamp: yesterday},
+	})
+
+	tests := []struct {
+		score int
+		rank  int
+	}{
+		{1000, 1},
+		{700, 2},
+		{500, 3},
+		{600, 2},
+		{50, 4},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.score), func(t *testing.T) {
+			if rank := rm.rankToday(tt.score, now); rank != tt.rank {
+				t.Errorf("rankToday(%d) = %d, want %d", tt.score, rank, tt.rank)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AutoTaggingConfig controls genre inference for riddles submitted without tags
+type AutoTaggingConfig struct {
+	Enabled  bool                `json:"enabled"`
+	Mode     string              `json:"mode"`     // "keywords" (default) or "model"
+	Model    string              `json:"model"`    // Configured model name used in "model" mode
+	Taxonomy map[string][]string `json:"taxonomy"` // Tag -> keywords; defaults to defaultTagTaxonomy
+}
+
+var defaultTagTaxonomy = map[string][]string{
+	"nature":    {"tree", "river", "rain", "wind", "sun", "moon", "flower", "leaf", "sea", "mountain", "cloud", "snow"},
+	"animals":   {"animal", "bird", "fish", "cat", "dog", "horse", "legs", "wings", "fur", "feathers", "tail"},
+	"household": {"house", "room", "door", "key", "table", "chair", "kitchen", "bed", "window", "lock"},
+	"body":      {"body", "hand", "eye", "mouth", "head", "face", "teeth", "tongue", "heart", "finger"},
+	"time":      {"time", "day", "night", "hour", "clock", "year", "yesterday", "tomorrow", "age"},
+	"wordplay":  {"letter", "word", "spell", "alphabet", "begin with", "ends with", "backwards"},
+	"numbers":   {"number", "count", "math", "add", "half", "twice", "dozen", "odd", "even"},
+	"abstract":  {"silence", "secret", "promise", "name", "shadow", "echo", "nothing", "memory"},
+}
+
+// tagJob asks the tagging worker to classify one finished game
+type tagJob struct {
+	Room       *room
+	EntryID    string
+	Riddle     string
+	Clues      []string
+	AuthorTags []string
+}
+
+var tagJobs = make(chan tagJob, 64)
+
+// enqueueTagJob hands a finished game to the tagging worker without blocking
+// the game loop; if the queue is full the game simply isn't tagged by the
+// model. Its author's tags were already counted when it was saved.
+func enqueueTagJob(job tagJob) {
+	select {
+	case tagJobs <- job:
+	default:
+		log.Printf("Tag queue full, skipping auto-tagging for %s\n", job.EntryID)
+	}
+}
+
+// runTagWorker classifies finished games with the tagging model off the hot
+// path and records tag analytics. Classification failures are retried once
+// and are never fatal.
+func runTagWorker() {
+	for job := range tagJobs {
+		inferred, err := inferTags(job)
+		if err != nil {
+			log.Printf("Auto-tagging %s failed, retrying: %v\n", job.EntryID, err)
+			inferred, err = inferTags(job)
+		}
+		if err != nil {
+			log.Printf("Auto-tagging %s failed: %v\n", job.EntryID, err)
+			continue
+		}
+
+		if inferred = subtractTags(inferred, job.AuthorTags); len(inferred) > 0 {
+			job.Room.setLeaderboardInferredTags(job.EntryID, inferred)
+			job.Room.recordTagStats(inferred)
+		}
+	}
+}
+
+// taggingWithModel reports whether finished games go to the tagging worker
+// to be classified by a model
+func taggingWithModel() bool {
+	tagging := currentConfig().AutoTagging
+	return tagging.Enabled && tagging.Mode == "model"
+}
+
+// keywordTags are the tags inferred for a finished game by keyword, which
+// needs no model, so they are stored and counted along with the game. There
+// are none unless auto-tagging is on in keyword mode.
+func keywordTags(game *GameState) []string {
+	tagging := currentConfig().AutoTagging
+	if !tagging.Enabled || tagging.Mode == "model" {
+		return nil
+	}
+	tags := inferTagsByKeyword(game.Riddle+"\n"+strings.Join(game.Clues, "\n"), tagTaxonomy())
+	return subtractTags(tags, game.Tags)
+}
+
+func tagTaxonomy() map[string][]string {
+	if taxonomy := currentConfig().AutoTagging.Taxonomy; len(taxonomy) > 0 {
+		return taxonomy
+	}
+	return defaultTagTaxonomy
+}
+
+func inferTags(job tagJob) ([]string, error) {
+	tagging := currentConfig().AutoTagging
+	taxonomy := tagTaxonomy()
+	if tagging.Mode == "model" {
+		return inferTagsWithModel(job, tagging.Model, taxonomy)
+	}
+	return inferTagsByKeyword(job.Riddle+"\n"+strings.Join(job.Clues, "\n"), taxonomy), nil
+}
+
+// inferTagsByKeyword tags text with every taxonomy entry that has a keyword
+// appearing as a whole word (or phrase) in it
+func inferTagsByKeyword(text string, taxonomy map[string][]string) []string {
+	words := " " + strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), " ") + " "
+
+	var tags []string
+	for tag, keywords := range taxonomy {
+		for _, keyword := range keywords {
+			if strings.Contains(words, " "+strings.ToLower(keyword)+" ") {
+				tags = append(tags, tag)
+				break
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// inferTagsWithModel asks the configured helper model to pick tags from the taxonomy
+func inferTagsWithModel(job tagJob, modelName string, taxonomy map[string][]string) ([]string, error) {
+	var modelCfg *ModelConfig
+	for _, model := range currentConfig().Models {
+		if model.Name == modelName {
+			m := model
+			modelCfg = &m
+		}
+	}
+	if modelCfg == nil {
+		return nil, fmt.Errorf("unknown tagging model: %s", modelName)
+	}
+
+	var names []string
+	for tag := range taxonomy {
+		names = append(names, tag)
+	}
+	sort.Strings(names)
+
+	prompt := fmt.Sprintf("Classify this riddle into one or more of these categories: %s.\n\nRiddle: %s\n\nReply with only the category names, separated by commas.",
+		strings.Join(names, ", "), job.Riddle)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	response, err := callOutsideGame(ctx, *modelCfg, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	for _, part := range strings.Split(strings.ToLower(response), ",") {
+		part = strings.Trim(strings.TrimSpace(part), ".")
+		if _, ok := taxonomy[part]; ok {
+			tags = append(tags, part)
+		}
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("no known categories in response %q", response)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// subtractTags drops inferred tags the author already supplied so the two
+// lists stay distinct
+func subtractTags(tags []string, remove []string) []string {
+	var out []string
+	for _, tag := range tags {
+		found := false
+		for _, r := range remove {
+			if strings.EqualFold(tag, r) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+func (rm *room) recordTagStats(tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	rm.statsMux.Lock()
+	defer rm.statsMux.Unlock()
+
+	if rm.stats.ByTag == nil {
+		rm.stats.ByTag = make(map[string]int)
+	}
+	for _, tag := range tags {
+		rm.stats.ByTag[strings.ToLower(tag)]++
+	}
+	rm.saveStats()
+}
+
+func (rm *room) setLeaderboardInferredTags(entryID string, tags []string) {
+	rm.leaderboardMux.Lock()
+	defer rm.leaderboardMux.Unlock()
+
+	for i := range rm.leaderboard {
+		if rm.leaderboard[i].ID == entryID {
+			rm.leaderboard[i].InferredTags = tags
+			rm.saveLeaderboard()
+			return
+		}
+	}
+}
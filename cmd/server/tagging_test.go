@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// labeledRiddle is a riddle from testdata/tagging.json with the tags a
+// person gave it
+type labeledRiddle struct {
+	Riddle string   `json:"riddle"`
+	Clues  []string `json:"clues"`
+	Tags   []string `json:"tags"`
+}
+
+// TestKeywordTaggingQuality scores keyword tagging with the default taxonomy
+// against hand-labeled riddles. Keyword matching misses plenty, so this only
+// guards against it getting worse.
+func TestKeywordTaggingQuality(t *testing.T) {
+	data, err := os.ReadFile("testdata/tagging.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var riddles []labeledRiddle
+	if err := json.Unmarshal(data, &riddles); err != nil {
+		t.Fatal(err)
+	}
+
+	var found, inferred, labeled int
+	for _, riddle := range riddles {
+		tags := inferTagsByKeyword(riddle.Riddle+"\n"+strings.Join(riddle.Clues, "\n"), defaultTagTaxonomy)
+		for _, tag := range tags {
+			if slices.Contains(riddle.Tags, tag) {
+				found++
+			} else {
+				t.Logf("%q: wrongly tagged %s", riddle.Riddle, tag)
+			}
+		}
+		for _, tag := range riddle.Tags {
+			if !slices.Contains(tags, tag) {
+				t.Logf("%q: missed %s", riddle.Riddle, tag)
+			}
+		}
+		inferred += len(tags)
+		labeled += len(riddle.Tags)
+	}
+
+	precision := float64(found) / float64(inferred)
+	recall := float64(found) / float64(labeled)
+	t.Logf("precision %.2f, recall %.2f over %d riddles", precision, recall, len(riddles))
+	if precision < 0.6 || recall < 0.5 {
+		t.Errorf("precision %.2f and recall %.2f, want at least 0.6 and 0.5", precision, recall)
+	}
+}
+
+// TestModelTaggingIsGated checks model tagging waits for a call slot like a
+// game's calls do, and is turned away by an open circuit breaker
+func TestModelTaggingIsGated(t *testing.T) {
+	var calls atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"household, numbers\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	useConfig(t, Config{
+		Models:                  []ModelConfig{{Name: "Tagger", Provider: "openai-compatible", Model: "test", Endpoint: server.URL}},
+		MaxConcurrentModelCalls: 1,
+		AutoTagging:             AutoTaggingConfig{Enabled: true, Mode: "model", Model: "Tagger"},
+	})
+	job := tagJob{Riddle: "What has keys but can't open locks?"}
+
+	// Every slot is taken by a game's call
+	slots := callSlotSemaphore()
+	slots <- struct{}{}
+	done := make(chan []string, 1)
+	go func() {
+		tags, err := inferTags(job)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- tags
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if n := calls.Load(); n > 0 {
+		t.Fatalf("tagging called its model %d times while every call slot was taken", n)
+	}
+	<-slots
+	select {
+	case tags := <-done:
+		if !slices.Equal(tags, []string{"household", "numbers"}) {
+			t.Errorf("tagged %v, want household and numbers", tags)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("tagging never got a call slot")
+	}
+
+	// Tagging failures count against the model like a game's would
+	for i := 0; i < defaultCircuitBreaker.FailureThreshold; i++ {
+		recordCall("Tagger", errors.New("down"))
+	}
+	t.Cleanup(func() {
+		breakersMux.Lock()
+		delete(breakers, "Tagger")
+		breakersMux.Unlock()
+	})
+	before := calls.Load()
+	if _, err := inferTagsWithModel(job, "Tagger", defaultTagTaxonomy); !errors.Is(err, ErrModelUnavailable) {
+		t.Errorf("tagging with an open breaker returned %v, want ErrModelUnavailable", err)
+	}
+	if calls.Load() != before {
+		t.Error("tagging called a model whose breaker is open")
+	}
+}
+
+// TestTagsCountedWithFullQueue saves games while the tagging queue is full,
+// and checks the author's tags, and keyword tags, are counted anyway; only
+// tagging with a model waits for the queue
+func TestTagsCountedWithFullQueue(t *testing.T) {
+	for len(tagJobs) < cap(tagJobs) {
+		tagJobs <- tagJob{}
+	}
+	t.Cleanup(func() {
+		for len(tagJobs) > 0 {
+			<-tagJobs
+		}
+	})
+
+	tests := []struct {
+		mode     string
+		inferred []string
+		byTag    map[string]int
+	}{
+		{"keywords", []string{"time"}, map[string]int{"wordplay": 1, "time": 1}},
+		{"model", nil, map[string]int{"wordplay": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			model := ModelConfig{Name: "Mock", Provider: "mock", Model: "always-correct"}
+			useConfig(t, Config{
+				Models:      []ModelConfig{model},
+				AutoTagging: AutoTaggingConfig{Enabled: true, Mode: tt.mode, Model: "Mock"},
+			})
+			game := newTestGame(t, "clock", model)
+			game.Riddle = "What has hands but can't clap?"
+			game.Clues = []string{"It tells you the hour"}
+			game.Tags = []string{"wordplay"}
+
+			rm := rooms[defaultRoom]
+			rm.addToLeaderboard(game, GameResult{CorrectCount: 1, TotalModels: 1, RoundsPlayed: 1, Timestamp: time.Now()})
+
+			if inferred := rm.leaderboard[0].InferredTags; !slices.Equal(inferred, tt.inferred) {
+				t.Errorf("inferred tags %v, want %v", inferred, tt.inferred)
+			}
+			rm.statsMux.Lock()
+			byTag := rm.stats.ByTag
+			rm.statsMux.Unlock()
+			if !maps.Equal(byTag, tt.byTag) {
+				t.Errorf("stats by tag %v, want %v", byTag, tt.byTag)
+			}
+		})
+	}
+}
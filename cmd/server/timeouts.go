@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/tahcohcat/turingroulette/internal/providers"
+)
+
+// TimeoutConfig sets the three deadlines of a provider call. Zero values fall
+// back to the defaults below.
+type TimeoutConfig struct {
+	ConnectSeconds    float64 `json:"connectSeconds"`    // Establishing the connection
+	FirstTokenSeconds float64 `json:"firstTokenSeconds"` // From connecting to the first response byte
+	TotalSeconds      float64 `json:"totalSeconds"`      // The whole call
+}
+
+var defaultTimeouts = TimeoutConfig{
+	ConnectSeconds:    5,
+	FirstTokenSeconds: 15,
+	TotalSeconds:      60,
+}
+
+// outcomeTimedOut is the outcome of a round that ended before the model
+// answered. It counts as no guess rather than a wrong one.
+const outcomeTimedOut = "timedOut"
+
+var (
+	ErrConnectTimeout    = errors.New("timed out connecting to provider")
+	ErrFirstTokenTimeout = errors.New("timed out waiting for the first token")
+	ErrTotalTimeout      = errors.New("timed out waiting for the response to complete")
+	ErrRoundTimeout      = errors.New("the round ended before the model answered")
+)
+
+// roundDeadline is how long a round waits for its models before going on
+// without the ones still answering, or 0 for no limit. There is none unless
+// one is configured, since it would cut short models given longer timeouts.
+func roundDeadline() time.Duration {
+	limit := currentConfig().RoundDeadlineSeconds
+	if limit <= 0 {
+		return 0
+	}
+	return seconds(limit)
+}
+
+// timeoutsFor returns the deadlines for a provider, filling unset values from
+// the "default" entry and then the built-in defaults
+func timeoutsFor(provider string) TimeoutConfig {
+	configured := currentConfig().Timeouts
+	t := configured[provider]
+	fallback := configured["default"]
+
+	pick := func(values ...float64) time.Duration {
+		for _, v := range values {
+			if v > 0 {
+				return time.Duration(v * float64(time.Second))
+			}
+		}
+		return 0
+	}
+	return TimeoutConfig{
+		ConnectSeconds:    pick(t.ConnectSeconds, fallback.ConnectSeconds, defaultTimeouts.ConnectSeconds).Seconds(),
+		FirstTokenSeconds: pick(t.FirstTokenSeconds, fallback.FirstTokenSeconds, defaultTimeouts.FirstTokenSeconds).Seconds(),
+		TotalSeconds:      pick(t.TotalSeconds, fallback.TotalSeconds, defaultTimeouts.TotalSeconds).Seconds(),
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// withTieredDeadlines arms the connect deadline immediately, swaps it for the
+// first-token deadline once a connection is obtained, and disarms both when
+// the first response byte arrives. The total deadline is the caller's context.
+// A model whose provider makes no HTTP requests never connects, and neither
+// does a replayed call, so those only have the total deadline. The returned
+// stop func must be called once the call finishes.
+func withTieredDeadlines(ctx context.Context, modelCfg ModelConfig, timeouts TimeoutConfig) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	if providers.IsOffline(modelCfg.Provider) || currentConfig().ReplayDir != "" {
+		return ctx, func() { cancel(nil) }
+	}
+
+	var mu sync.Mutex
+	stopped := false
+	timer := time.AfterFunc(seconds(timeouts.ConnectSeconds), func() {
+		cancel(ErrConnectTimeout)
+	})
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			if stopped || !timer.Stop() {
+				return
+			}
+			timer = time.AfterFunc(seconds(timeouts.FirstTokenSeconds), func() {
+				cancel(ErrFirstTokenTimeout)
+			})
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			timer.Stop()
+		},
+	}
+
+	stop := func() {
+		mu.Lock()
+		stopped = true
+		timer.Stop()
+		mu.Unlock()
+		cancel(nil)
+	}
+	return httptrace.WithClientTrace(ctx, trace), stop
+}
+
+// timeoutTier reports which deadline ended a call, or "" if none did
+func timeoutTier(cause error) string {
+	switch {
+	case errors.Is(cause, ErrConnectTimeout):
+		return "connect"
+	case errors.Is(cause, ErrFirstTokenTimeout):
+		return "firstToken"
+	case errors.Is(cause, ErrTotalTimeout):
+		return "total"
+	case errors.Is(cause, ErrRoundTimeout):
+		return "round"
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestSlowMockCompletes plays a mock that takes far longer to start than the
+// connect and first-token deadlines allow. It never connects anywhere, so it
+// only answers to the total deadline.
+func TestSlowMockCompletes(t *testing.T) {
+	useConfig(t, Config{
+		Models:   []ModelConfig{{Name: "Slowpoke", Provider: "mock", Model: "slow", Options: map[string]interface{}{"delayMs": 600}}},
+		Timeouts: map[string]TimeoutConfig{"default": {ConnectSeconds: 0.1, FirstTokenSeconds: 0.1, TotalSeconds: 10}},
+	})
+	model := currentConfig().Models[0]
+	game := newTestGame(t, "piano", model)
+
+	streamModelResponse(context.Background(), newClient(nil, nil), model, "What has keys but can't open locks?", false, game)
+
+	state := game.ModelStates[model.Name]
+	if state.ErrorCategory != "" || !state.Correct {
+		t.Errorf("slow mock ended with %q, correct=%v; want a correct answer", state.ErrorCategory, state.Correct)
+	}
+}
+
+// TestRoundDeadline checks the round deadline is off unless configured, and
+// once it is, settles a late model as timed out without holding up the rest
+func TestRoundDeadline(t *testing.T) {
+	useConfig(t, Config{Models: []ModelConfig{{Name: "Quick", Provider: "mock", Model: "always-correct"}}})
+	if deadline := roundDeadline(); deadline != 0 {
+		t.Fatalf("round deadline %v by default, want none", deadline)
+	}
+
+	useConfig(t, Config{
+		Models: []ModelConfig{
+			{Name: "Quick", Provider: "mock", Model: "always-correct"},
+			{Name: "Slowpoke", Provider: "mock", Model: "slow", Options: map[string]interface{}{"delayMs": 5000}},
+		},
+		RoundDeadlineSeconds: 0.5,
+	})
+	models := currentConfig().Models
+	game := newTestGame(t, "piano", models...)
+
+	start := time.Now()
+	runModels(context.Background(), newClient(nil, nil), game, models)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("round took %v with a 0.5s deadline", elapsed)
+	}
+
+	if quick := game.ModelStates["Quick"]; !quick.Correct {
+		t.Errorf("the quick model wasn't settled by its own answer: %+v", quick)
+	}
+	slow := game.ModelStates["Slowpoke"]
+	if slow.Outcome != outcomeTimedOut || slow.ErrorCategory != "timeout:round" || slow.GuessCount != 0 {
+		t.Errorf("late model ended with outcome %q, category %q, %d guesses; want timedOut, timeout:round, 0", slow.Outcome, slow.ErrorCategory, slow.GuessCount)
+	}
+}
+
+// TestTimeoutTiers plays a model against fake servers that hang at each
+// stage of a call, and checks the deadline for that stage is the one that
+// fires, both in the error the player is sent and on the model's state
+func TestTimeoutTiers(t *testing.T) {
+	// Accepts connections but never completes the TLS handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	// Takes the request but never responds
+	silent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(silent.Close)
+
+	// Keeps streaming but never finishes
+	endless := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for {
+			fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"um \"}}]}\n\n")
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}))
+	t.Cleanup(endless.Close)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		tier     string
+	}{
+		{"connect", "https://" + listener.Addr().String(), "connect"},
+		{"first token", silent.URL, "firstToken"},
+		{"total", endless.URL, "total"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, Config{
+				Models:         []ModelConfig{{Name: "Hung", Provider: "openai-compatible", Model: "test", Endpoint: tt.endpoint}},
+				Timeouts:       map[string]TimeoutConfig{"default": {ConnectSeconds: 0.2, FirstTokenSeconds: 0.4, TotalSeconds: 1.5}},
+				Retries:        map[string]RetryConfig{"default": {MaxAttempts: 1}},
+				CircuitBreaker: CircuitBreakerConfig{FailureThreshold: -1},
+			})
+			messages := playGame(t, serveGames(t), "", RiddleSubmission{
+				Riddle:     "What has keys but can't open locks?",
+				Answers:    []string{"piano"},
+				Difficulty: "easy",
+				Username:   "tester",
+			})
+
+			category := "timeout:" + tt.tier
+			var errorSent, resultSent bool
+			for _, message := range messages {
+				switch message["type"] {
+				case "error":
+					if message["model"] == "Hung" {
+						errorSent = true
+						if message["content"] != category {
+							t.Errorf("player sent error %q, want %q", message["content"], category)
+						}
+					}
+				case "result":
+					if message["model"] == "Hung" {
+						resultSent = true
+						if message["timedOut"] != true || message["outcome"] != "errored" {
+							t.Errorf("result timedOut=%v, outcome %v; want timedOut, errored", message["timedOut"], message["outcome"])
+						}
+					}
+				}
+			}
+			if !errorSent || !resultSent {
+				t.Errorf("error sent: %v, result sent: %v; want both", errorSent, resultSent)
+			}
+
+			var states map[string]ModelState
+			if err := json.Unmarshal([]byte(mustJSON(t, messages[len(messages)-1]["modelStates"])), &states); err != nil {
+				t.Fatal(err)
+			}
+			state := states["Hung"]
+			if state.ErrorCategory != category || state.Timeouts[tt.tier] != 1 || len(state.Timeouts) != 1 {
+				t.Errorf("model state has category %q and timeouts %v, want %q once", state.ErrorCategory, state.Timeouts, tt.tier)
+			}
+		})
+	}
+}
@@ -2,4 +2,7 @@ module github.com/tahcohcat/turingroulette
 
 go 1.21
 
-require github.com/gorilla/websocket v1.5.3
+require (
+	github.com/gorilla/websocket v1.5.3
+	golang.org/x/text v0.22.0
+)
@@ -0,0 +1,392 @@
+// Package answers decides whether a model's guess answers a riddle. A Checker
+// holds the matching rules, set with options when it is built, and checks
+// guesses against a Riddle.
+package answers
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	DefaultFuzzyThreshold    = 0.2
+	DefaultRestatedThreshold = 0.6
+
+	// MaxPatternLength keeps an answer pattern to something a person could
+	// audit
+	MaxPatternLength = 200
+
+	// minPartialGuessLength is the fewest letters a guess needs to match part
+	// of a longer answer, so "a" or "it" don't match everything
+	minPartialGuessLength = 4
+
+	// maxStrictAnswerLength is the longest answer, in letters, matched only
+	// strictly, see Strict
+	maxStrictAnswerLength = 2
+)
+
+// Riddle is what a guess is checked against
+type Riddle struct {
+	Text     string         // The riddle itself, for spotting guesses that quote it
+	Answers  []string       // Every accepted answer
+	Pattern  *regexp.Regexp // Matched instead of the answers when set, see CompilePattern
+	Synonyms []string       // Groups of words that mean the same, such as "couch=sofa"
+	Language language.Tag   // The riddle's language; unset is English
+	Exact    bool           // Guesses must equal an answer, ignoring only case and surrounding space
+}
+
+// Match is the verdict on a guess
+type Match struct {
+	Correct  bool
+	Fuzzy    bool   // Accepted as close enough, within the fuzzy threshold
+	Phonetic bool   // Accepted as sounding the same, after fuzzy matching failed
+	Pattern  bool   // Matched the riddle's answer pattern
+	Answer   string // The accepted answer that matched, or the text the pattern matched
+
+	// A rejected guess one edit past the fuzzy threshold, with fuzzy matching on
+	NearMiss bool
+	// With fuzzy matching on, how close the guess came: 1 less the edits per
+	// letter of the answer
+	Similarity float64
+}
+
+// Checker checks guesses under a fixed set of matching rules. It holds no
+// state between checks, so one can be shared by every game.
+type Checker struct {
+	caseFolding       bool
+	articles          bool
+	wordBoundaries    bool
+	foldPlurals       bool
+	numbers           bool
+	compounds         bool
+	partialGuesses    bool
+	phonetic          bool
+	fuzzyThreshold    float64
+	restatedThreshold float64
+	phrases           map[string]Phrases
+}
+
+// Option sets one of a Checker's rules
+type Option func(*Checker)
+
+// New builds a Checker with every rule on and the default thresholds, then
+// applies opts
+func New(opts ...Option) *Checker {
+	c := &Checker{
+		caseFolding:       true,
+		articles:          true,
+		wordBoundaries:    true,
+		foldPlurals:       true,
+		numbers:           true,
+		compounds:         true,
+		partialGuesses:    true,
+		fuzzyThreshold:    DefaultFuzzyThreshold,
+		restatedThreshold: DefaultRestatedThreshold,
+		phrases:           DefaultPhrases(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithCaseFolding ignores case by the rules of the riddle's language
+func WithCaseFolding(on bool) Option {
+	return func(c *Checker) { c.caseFolding = on }
+}
+
+// WithArticleStripping drops leading articles, and phrases such as "the
+// answer is" around the answer, in the riddle's language
+func WithArticleStripping(on bool) Option {
+	return func(c *Checker) { c.articles = on }
+}
+
+// WithWordBoundaries requires the answer to appear as whole words, so "ant"
+// isn't found in "want"
+func WithWordBoundaries(on bool) Option {
+	return func(c *Checker) { c.wordBoundaries = on }
+}
+
+// WithPluralFolding matches "footstep" with "footsteps", in English
+func WithPluralFolding(on bool) Option {
+	return func(c *Checker) { c.foldPlurals = on }
+}
+
+// WithNumbers matches "eight" with "8", in English
+func WithNumbers(on bool) Option {
+	return func(c *Checker) { c.numbers = on }
+}
+
+// WithCompounds matches "fire-fly" and "fire fly" with "firefly"
+func WithCompounds(on bool) Option {
+	return func(c *Checker) { c.compounds = on }
+}
+
+// WithPartialGuesses accepts a guess that is part of a longer answer, such as
+// "piano" for "grand piano"
+func WithPartialGuesses(on bool) Option {
+	return func(c *Checker) { c.partialGuesses = on }
+}
+
+// WithPhonetic accepts a single-word guess that sounds like a single-word
+// answer, such as "nite" for "night", once fuzzy matching has failed. It is
+// off by default, and only applies in English.
+func WithPhonetic(on bool) Option {
+	return func(c *Checker) { c.phonetic = on }
+}
+
+// WithFuzzyThreshold sets the typos forgiven, as a fraction of the answer's
+// length: 0.2 allows one edit in a five-letter answer and two in a ten-letter
+// one. 0 accepts exact matches only.
+func WithFuzzyThreshold(threshold float64) Option {
+	return func(c *Checker) { c.fuzzyThreshold = threshold }
+}
+
+// WithRestatedThreshold sets the fraction of a guess's words taken from the
+// riddle above which it is checked for quoting it back, see Restates. 0 turns
+// the check off.
+func WithRestatedThreshold(threshold float64) Option {
+	return func(c *Checker) { c.restatedThreshold = threshold }
+}
+
+// CompilePattern checks an answer pattern. Matching ignores case; an empty
+// pattern gives nil.
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil, nil
+	}
+	if len(pattern) > MaxPatternLength {
+		return nil, fmt.Errorf("longer than %d characters", MaxPatternLength)
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid regular expression: %w", err)
+	}
+	return re, nil
+}
+
+// Check matches a guess against the riddle's accepted answers. An exact match
+// with any of them wins over a fuzzy one, and a fuzzy one over a phonetic
+// one. A rejected guess carries its
+// closest miss, a near miss first. A riddle with an answer pattern is matched
+// against that alone, after the same cleaning.
+func (c *Checker) Check(guess string, r *Riddle) Match {
+	if r.Exact {
+		return matchExactly(guess, r)
+	}
+	n := c.normalizer(r)
+	if r.Pattern != nil {
+		return n.matchPattern(guess, r.Pattern)
+	}
+
+	var fuzzy, miss Match
+	for _, answer := range r.Answers {
+		match := n.match(guess, answer)
+		if match.Correct && !match.Fuzzy && !match.Phonetic {
+			return match
+		}
+		if match.Correct && (!fuzzy.Correct || fuzzy.Phonetic && !match.Phonetic) {
+			fuzzy = match
+		}
+		if !match.Correct && (match.NearMiss && !miss.NearMiss ||
+			match.NearMiss == miss.NearMiss && match.Similarity > miss.Similarity) {
+			miss = match
+		}
+	}
+	if fuzzy.Correct {
+		return fuzzy
+	}
+	return miss
+}
+
+// normalizer splits and folds text under a checker's rules for one riddle
+type normalizer struct {
+	*Checker
+	language language.Tag
+	// Each synonym of the riddle, normalized, to the first word of its group
+	synonyms map[string]string
+}
+
+// normalizer prepares the rules for a riddle. Its synonyms are normalized by
+// the same rules, so they meet guesses and answers in the same form.
+func (c *Checker) normalizer(r *Riddle) normalizer {
+	n := normalizer{Checker: c, language: r.Language}
+	if len(r.Synonyms) == 0 {
+		return n
+	}
+	n.synonyms = make(map[string]string)
+	for _, group := range r.Synonyms {
+		var canonical string
+		for _, word := range strings.Split(group, "=") {
+			normalized := strings.Join(n.words(word), " ")
+			if canonical == "" {
+				canonical = normalized
+			}
+			n.synonyms[normalized] = canonical
+		}
+	}
+	return n
+}
+
+// words splits text as splitWords does and applies the word-level rules.
+// Plurals and numbers are only folded in English.
+func (n normalizer) words(text string) []string {
+	words := splitWords(text, n.language, n.caseFolding, n.articles)
+	english := baseLanguage(n.language) == "en"
+	if n.foldPlurals && english {
+		words = singularWords(words)
+	}
+	if n.numbers && english {
+		words = numberWords(words)
+	}
+	for i, word := range words {
+		if canonical, ok := n.synonyms[word]; ok {
+			words[i] = canonical
+		}
+	}
+	return words
+}
+
+func (n normalizer) match(guess string, correctAnswer string) Match {
+	guess = strings.TrimSpace(guess)
+	if n.caseFolding {
+		guess = lowerIn(guess, n.language)
+	}
+	if n.articles {
+		guess = stripPhrases(guess, n.phrases[baseLanguage(n.language)])
+	}
+
+	guessWords := n.words(guess)
+	answerWords := n.words(correctAnswer)
+	if len(guessWords) == 0 || len(answerWords) == 0 {
+		return Match{}
+	}
+	guessText, answerText := strings.Join(guessWords, " "), strings.Join(answerWords, " ")
+
+	// Almost any reply contains an "o" or an "i", so a short answer has to be
+	// the whole guess
+	if tooShortToFind(answerText) {
+		return matchStrictly(guessWords, answerWords, correctAnswer)
+	}
+
+	// With word boundaries the answer has to appear as whole words, so "ant"
+	// isn't found in "want". A guess that is part of a longer answer counts
+	// only if it is long enough to mean something on its own.
+	contains := containsWords
+	if !n.wordBoundaries {
+		contains = func(words, want []string) bool {
+			return strings.Contains(strings.Join(words, " "), strings.Join(want, " "))
+		}
+	}
+	names := func(words []string) bool {
+		return contains(words, answerWords) || (n.compounds && containsCompound(words, answerWords))
+	}
+
+	// A guess hedging between answers, as in "a cat or a dog or time itself",
+	// names the answer only if every alternative does
+	or := languageMatching[baseLanguage(n.language)].or
+	if split := alternatives(guessWords, or); split != nil && !slices.Contains(answerWords, or) {
+		for _, alternative := range split {
+			if !names(alternative) {
+				return Match{}
+			}
+		}
+		return Match{Correct: true, Answer: correctAnswer}
+	}
+
+	if names(guessWords) ||
+		(n.partialGuesses && len([]rune(strings.Join(guessWords, ""))) >= minPartialGuessLength && contains(answerWords, guessWords)) {
+		return Match{Correct: true, Answer: correctAnswer}
+	}
+
+	// Close misspellings such as "refridgerator" still count, and one edit
+	// more is a near miss
+	var miss Match
+	if n.fuzzyThreshold > 0 {
+		length := len([]rune(answerText))
+		allowed := int(n.fuzzyThreshold * float64(length))
+		distance := editDistance(guessText, answerText)
+		miss.Similarity = max(0, 1-float64(distance)/float64(length))
+		switch {
+		case allowed > 0 && distance <= allowed:
+			return Match{Correct: true, Fuzzy: true, Answer: correctAnswer, Similarity: miss.Similarity}
+		case distance == allowed+1:
+			miss.NearMiss, miss.Answer = true, correctAnswer
+		}
+	}
+
+	// Creative spellings such as "nite" get a last chance by sound, but only
+	// word against word, so phrases can't match on a few shared sounds
+	if n.phonetic && baseLanguage(n.language) == "en" && len(guessWords) == 1 && len(answerWords) == 1 &&
+		soundsAlike(guessWords[0], answerWords[0]) {
+		return Match{Correct: true, Phonetic: true, Answer: correctAnswer, Similarity: miss.Similarity}
+	}
+	return miss
+}
+
+func (n normalizer) matchPattern(guess string, pattern *regexp.Regexp) Match {
+	cleaned := strings.Join(n.words(guess), " ")
+	if cleaned == "" {
+		return Match{}
+	}
+	if matched := pattern.FindString(cleaned); matched != "" {
+		return Match{Correct: true, Pattern: true, Answer: matched}
+	}
+	return Match{}
+}
+
+// Words a guess can name a short answer with, as in "the letter O"
+var strictAnswerNouns = map[string]bool{
+	"letter": true, "character": true, "symbol": true, "number": true, "digit": true,
+}
+
+// Strict reports whether an answer is short enough, one or two letters, to
+// be matched only when it is the whole guess, rather than found in it
+func Strict(answer string, tag language.Tag) bool {
+	return tooShortToFind(strings.Join(Words(answer, tag), ""))
+}
+
+// tooShortToFind reports whether an answer has no more than
+// maxStrictAnswerLength letters. A character of a script such as Chinese or
+// Japanese is a word in itself, so "東京" is never too short.
+func tooShortToFind(answer string) bool {
+	if len([]rune(answer)) > maxStrictAnswerLength {
+		return false
+	}
+	for _, r := range answer {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchStrictly accepts a guess that is the short answer alone, or names it
+// as in "the letter O" or "letter O"
+func matchStrictly(guessWords, answerWords []string, correctAnswer string) Match {
+	if len(guessWords) == len(answerWords)+1 && strictAnswerNouns[guessWords[0]] {
+		guessWords = guessWords[1:]
+	}
+	if slices.Equal(guessWords, answerWords) {
+		return Match{Correct: true, Answer: correctAnswer}
+	}
+	return Match{}
+}
+
+// matchExactly is matching for riddles with Exact set: the guess has to be
+// one of the answers, ignoring only case and surrounding space
+func matchExactly(guess string, r *Riddle) Match {
+	guess = lowerIn(strings.TrimSpace(guess), r.Language)
+	for _, answer := range r.Answers {
+		if guess == lowerIn(strings.TrimSpace(answer), r.Language) {
+			return Match{Correct: true, Answer: answer}
+		}
+	}
+	return Match{}
+}
@@ -0,0 +1,72 @@
+package answers
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+// TestWholeWordMatching covers guesses that only contain the answer as part
+// of another word, or name it among others
+func TestWholeWordMatching(t *testing.T) {
+	tests := []struct {
+		guess   string
+		answer  string
+		correct bool
+	}{
+		{"time", "time", true},
+		{"It's time itself", "time", true},
+		{"I am not sure, maybe a cat or a dog or time itself", "time", false},
+		{"time or tide", "time", false},
+		{"Time, or more precisely the passage of time", "time", true},
+		{"now or never", "now or never", true},
+		{"a", "apple", false},
+		{"a", "time", false},
+		{"it", "kite", false},
+		{"ant", "ant", true},
+		{"an ant", "ant", true},
+		{"want", "ant", false},
+		{"I want an answer", "ant", false},
+		{"ant", "want", false},
+		{"piano", "grand piano", true},
+		{"pia", "grand piano", false},
+		{"fire fly", "firefly", true},
+		{"a fire-fly or a moth", "firefly", false},
+	}
+	c := New(WithFuzzyThreshold(0))
+	for _, tt := range tests {
+		t.Run(tt.guess+"/"+tt.answer, func(t *testing.T) {
+			match := c.Check(tt.guess, &Riddle{Answers: []string{tt.answer}})
+			if match.Correct != tt.correct {
+				t.Errorf("Check(%q) against %q: correct = %v, want %v", tt.guess, tt.answer, match.Correct, tt.correct)
+			}
+		})
+	}
+}
+
+// TestAlternativesInOtherLanguages splits hedged guesses at the riddle's own
+// word for "or"
+func TestAlternativesInOtherLanguages(t *testing.T) {
+	tests := []struct {
+		lang    string
+		guess   string
+		answer  string
+		correct bool
+	}{
+		{"fr", "un chat ou le temps", "temps", false},
+		{"fr", "le temps", "temps", true},
+		{"de", "die Zeit oder ein Hund", "Zeit", false},
+		{"es", "el tiempo o un perro", "tiempo", false},
+		{"pt", "o tempo", "tempo", true},
+		{"nl", "de tijd of een hond", "tijd", false},
+	}
+	c := New(WithFuzzyThreshold(0))
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.guess, func(t *testing.T) {
+			match := c.Check(tt.guess, &Riddle{Answers: []string{tt.answer}, Language: language.Make(tt.lang)})
+			if match.Correct != tt.correct {
+				t.Errorf("Check(%q) against %q: correct = %v, want %v", tt.guess, tt.answer, match.Correct, tt.correct)
+			}
+		})
+	}
+}
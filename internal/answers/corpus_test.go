@@ -0,0 +1,50 @@
+package answers
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+// corpusCase is a guess from a real game and the verdict it should get
+type corpusCase struct {
+	Guess    string   `json:"guess"`
+	Answers  []string `json:"answers"`
+	Synonyms []string `json:"synonyms"`
+	Language string   `json:"language"`
+	Correct  bool     `json:"correct"`
+	Fuzzy    bool     `json:"fuzzy"` // Accepted only as close enough
+	Note     string   `json:"note"`
+}
+
+// TestCorpus checks the default rules against testdata/corpus.json, guesses
+// models have made with the verdict each should get. A matching change that
+// moves any verdict shows up here; add the guess that prompted it.
+func TestCorpus(t *testing.T) {
+	data, err := os.ReadFile("testdata/corpus.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var corpus []corpusCase
+	if err := json.Unmarshal(data, &corpus); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New()
+	for _, tc := range corpus {
+		t.Run(tc.Guess+"/"+strings.Join(tc.Answers, "|"), func(t *testing.T) {
+			riddle := &Riddle{Answers: tc.Answers, Synonyms: tc.Synonyms}
+			if tc.Language != "" {
+				riddle.Language = language.Make(tc.Language)
+			}
+			match := c.Check(tc.Guess, riddle)
+			if match.Correct != tc.Correct || match.Correct && match.Fuzzy != tc.Fuzzy {
+				t.Errorf("Check(%q) against %q: correct = %v, fuzzy = %v, want %v, %v %s",
+					tc.Guess, tc.Answers, match.Correct, match.Fuzzy, tc.Correct, tc.Fuzzy, tc.Note)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package answers
+
+import (
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+)
+
+// languageRules are the parts of answer matching that depend on the riddle's
+// language
+type languageRules struct {
+	articles []string // Dropped from the start of a guess or answer
+	elided   []string // Articles joined to the next word, as in French "l'arbre"
+	or       string   // The word between alternatives, as in "a cat or a dog"
+}
+
+// Languages without an entry are matched without dropping articles or
+// splitting alternatives
+var languageMatching = map[string]languageRules{
+	"en": {articles: []string{"a", "an", "the"}, or: "or"},
+	"fr": {articles: []string{"le", "la", "les", "un", "une", "des"}, elided: []string{"l'"}, or: "ou"},
+	"es": {articles: []string{"el", "la", "los", "las", "un", "una"}, or: "o"},
+	"it": {articles: []string{"il", "lo", "la", "i", "gli", "le", "un", "uno", "una"}, elided: []string{"l'", "un'"}, or: "o"},
+	"pt": {articles: []string{"o", "a", "os", "as", "um", "uma"}, or: "ou"},
+	"de": {articles: []string{"der", "die", "das", "ein", "eine"}, or: "oder"},
+	"nl": {articles: []string{"de", "het", "een"}, or: "of"},
+	"tr": {articles: []string{"bir"}, or: "veya"},
+}
+
+// baseLanguage is the tag's language without region or script, with an
+// unset tag taken as English
+func baseLanguage(tag language.Tag) string {
+	if tag == language.Und {
+		return "en"
+	}
+	base, _ := tag.Base()
+	return base.String()
+}
+
+// lowerIn lowercases text by the rules of its language, so Turkish "I"
+// becomes dotless "ı" and "İ" becomes "i"
+func lowerIn(text string, tag language.Tag) string {
+	if tag == language.Und {
+		tag = language.English
+	}
+	return cases.Lower(tag).String(text)
+}
@@ -0,0 +1,87 @@
+package answers
+
+import "strconv"
+
+var (
+	unitNumbers = map[string]int{
+		"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+		"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+		"eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14,
+		"fifteen": 15, "sixteen": 16, "seventeen": 17, "eighteen": 18,
+		"nineteen": 19, "dozen": 12, "hundred": 100,
+	}
+	tensNumbers = map[string]int{
+		"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50,
+		"sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90,
+	}
+	unitOrdinals = map[string]int{
+		"first": 1, "second": 2, "third": 3, "fourth": 4, "fifth": 5,
+		"sixth": 6, "seventh": 7, "eighth": 8, "ninth": 9, "tenth": 10,
+		"eleventh": 11, "twelfth": 12, "thirteenth": 13, "fourteenth": 14,
+		"fifteenth": 15, "sixteenth": 16, "seventeenth": 17,
+		"eighteenth": 18, "nineteenth": 19, "hundredth": 100,
+	}
+	tensOrdinals = map[string]int{
+		"twentieth": 20, "thirtieth": 30, "fortieth": 40, "fiftieth": 50,
+		"sixtieth": 60, "seventieth": 70, "eightieth": 80, "ninetieth": 90,
+	}
+)
+
+// numberWords rewrites spelled-out numbers among words as digits, so "eight"
+// matches "8" and "twenty one" matches "21". Ordinals become "3rd"-style
+// words, matching what a model writes in digits. Only whole words are
+// rewritten, so the "one" in "someone" is left alone.
+func numberWords(words []string) []string {
+	out := make([]string, 0, len(words))
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		next := ""
+		if i+1 < len(words) {
+			next = words[i+1]
+		}
+
+		switch {
+		case (word == "a" || word == "one") && next == "hundred":
+			out = append(out, "100")
+			i++
+		case word == "a" && next == "dozen":
+			out = append(out, "12")
+			i++
+		case tensNumbers[word] > 0 && unitNumbers[next] > 0 && unitNumbers[next] < 10:
+			out = append(out, strconv.Itoa(tensNumbers[word]+unitNumbers[next]))
+			i++
+		case tensNumbers[word] > 0 && unitOrdinals[next] > 0 && unitOrdinals[next] < 10:
+			out = append(out, ordinal(tensNumbers[word]+unitOrdinals[next]))
+			i++
+		case tensNumbers[word] > 0:
+			out = append(out, strconv.Itoa(tensNumbers[word]))
+		case tensOrdinals[word] > 0:
+			out = append(out, ordinal(tensOrdinals[word]))
+		default:
+			if n, ok := unitNumbers[word]; ok {
+				out = append(out, strconv.Itoa(n))
+			} else if n, ok := unitOrdinals[word]; ok {
+				out = append(out, ordinal(n))
+			} else {
+				out = append(out, word)
+			}
+		}
+	}
+	return out
+}
+
+// ordinal writes n as a model would in digits, such as "3rd" or "11th"
+func ordinal(n int) string {
+	suffix := "th"
+	if n%100 < 11 || n%100 > 13 {
+		switch n % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(n) + suffix
+}
@@ -0,0 +1,171 @@
+package answers
+
+import "strings"
+
+// minPhoneticLength is the fewest letters either word needs to be compared
+// by sound, so short words with few sounds don't match each other
+const minPhoneticLength = 3
+
+// soundsAlike reports whether two single words have the same Metaphone key,
+// such as "nite" and "night" or "fone" and "phone". Metaphone keeps no vowels
+// after the first letter, so the first vowels have to agree too, or "cat"
+// would sound like "kit".
+func soundsAlike(a, b string) bool {
+	if len([]rune(a)) < minPhoneticLength || len([]rune(b)) < minPhoneticLength {
+		return false
+	}
+	keyA, keyB := metaphone(a), metaphone(b)
+	return len(keyA) >= 2 && keyA == keyB && firstVowel(a) == firstVowel(b)
+}
+
+// firstVowel is the first vowel letter of a word, with a "y" after the start
+// read as "i"
+func firstVowel(word string) rune {
+	for i, r := range strings.ToLower(word) {
+		switch {
+		case strings.ContainsRune("aeiou", r):
+			return r
+		case r == 'y' && i > 0:
+			return 'i'
+		}
+	}
+	return 0
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("AEIOU", c) >= 0
+}
+
+// metaphone is Lawrence Philips' original Metaphone key of an English word:
+// the consonant sounds it is spoken with, with vowels kept only at the start.
+// Letters outside A to Z are ignored.
+func metaphone(word string) string {
+	var letters []byte
+	for _, r := range strings.ToUpper(word) {
+		if r >= 'A' && r <= 'Z' {
+			letters = append(letters, byte(r))
+		}
+	}
+	w := string(letters)
+	if w == "" {
+		return ""
+	}
+
+	// Silent or changed first letters
+	switch {
+	case strings.HasPrefix(w, "AE"), strings.HasPrefix(w, "GN"), strings.HasPrefix(w, "KN"),
+		strings.HasPrefix(w, "PN"), strings.HasPrefix(w, "WR"):
+		w = w[1:]
+	case w[0] == 'X':
+		w = "S" + w[1:]
+	case strings.HasPrefix(w, "WH"):
+		w = "W" + w[2:]
+	}
+
+	at := func(i int) byte {
+		if i < 0 || i >= len(w) {
+			return 0
+		}
+		return w[i]
+	}
+	next := func(i int, s string) bool {
+		return strings.HasPrefix(w[i+1:], s)
+	}
+
+	var key strings.Builder
+	for i := 0; i < len(w); i++ {
+		c := w[i]
+		if c != 'C' && i > 0 && at(i-1) == c {
+			continue
+		}
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				key.WriteByte(c)
+			}
+		case 'B':
+			if !(i == len(w)-1 && at(i-1) == 'M') {
+				key.WriteByte('B')
+			}
+		case 'C':
+			switch {
+			case next(i, "IA"), next(i, "H") && at(i-1) != 'S':
+				key.WriteByte('X')
+			case next(i, "I"), next(i, "E"), next(i, "Y"):
+				if at(i-1) != 'S' {
+					key.WriteByte('S')
+				}
+			default:
+				key.WriteByte('K')
+			}
+		case 'D':
+			if next(i, "GE") || next(i, "GY") || next(i, "GI") {
+				key.WriteByte('J')
+			} else {
+				key.WriteByte('T')
+			}
+		case 'G':
+			switch {
+			case next(i, "H") && i+2 < len(w) && !isVowel(at(i+2)):
+				// Silent, as in "night"
+			case next(i, "H") && i+2 == len(w):
+				// Silent, as in "though"
+			case next(i, "N") && (i+2 == len(w) || next(i, "NED")):
+			case (next(i, "I") || next(i, "E") || next(i, "Y")) && at(i-1) != 'G':
+				key.WriteByte('J')
+			default:
+				key.WriteByte('K')
+			}
+		case 'H':
+			if strings.IndexByte("CSPTG", at(i-1)) >= 0 {
+				continue
+			}
+			// Silent after a vowel unless another follows, as in "ah"
+			if !(isVowel(at(i-1)) && !isVowel(at(i+1))) {
+				key.WriteByte('H')
+			}
+		case 'K':
+			if at(i-1) != 'C' {
+				key.WriteByte('K')
+			}
+		case 'P':
+			if next(i, "H") {
+				key.WriteByte('F')
+			} else {
+				key.WriteByte('P')
+			}
+		case 'Q':
+			key.WriteByte('K')
+		case 'S':
+			switch {
+			case next(i, "H"), next(i, "IO"), next(i, "IA"):
+				key.WriteByte('X')
+			default:
+				key.WriteByte('S')
+			}
+		case 'T':
+			switch {
+			case next(i, "IA"), next(i, "IO"):
+				key.WriteByte('X')
+			case next(i, "H"):
+				key.WriteByte('0')
+			case next(i, "CH"):
+			default:
+				key.WriteByte('T')
+			}
+		case 'V':
+			key.WriteByte('F')
+		case 'W', 'Y':
+			if isVowel(at(i + 1)) {
+				key.WriteByte(c)
+			}
+		case 'X':
+			key.WriteString("KS")
+		case 'Z':
+			key.WriteByte('S')
+		default:
+			key.WriteByte(c)
+		}
+	}
+	return key.String()
+}
@@ -0,0 +1,106 @@
+package answers
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Phrases are what a model writes around its answer in one language, such as
+// "my guess is" before it or "is my answer" after it
+type Phrases struct {
+	Prefixes []string `json:"prefixes"`
+	Suffixes []string `json:"suffixes"`
+}
+
+// DefaultPhrases are the phrases stripped from guesses, keyed by base
+// language, unless replaced with WithPhrases
+func DefaultPhrases() map[string]Phrases {
+	return map[string]Phrases{
+		"en": {
+			Prefixes: []string{
+				"the answer is", "the answer would be", "i believe the answer is", "i think the answer is",
+				"my answer is", "my guess is", "based on the clues", "based on the riddle",
+				"i believe it's", "i believe it is", "i think it's", "i think it is", "i'd say", "i would say",
+				"it must be", "it could be", "it's", "it is", "this sounds like", "sounds like",
+				"that would be", "probably", "perhaps", "maybe",
+			},
+			Suffixes: []string{"is my answer", "is my guess", "is the answer", "i think", "i believe", "perhaps", "maybe"},
+		},
+		"fr": {Prefixes: []string{"la réponse est", "je pense que c'est", "c'est"}, Suffixes: []string{"est ma réponse"}},
+		"es": {Prefixes: []string{"la respuesta es", "creo que es", "es"}, Suffixes: []string{"es mi respuesta"}},
+		"it": {Prefixes: []string{"la risposta è", "penso che sia", "è"}, Suffixes: []string{"è la mia risposta"}},
+		"pt": {Prefixes: []string{"a resposta é", "acho que é", "é"}, Suffixes: []string{"é a minha resposta"}},
+		"de": {Prefixes: []string{"die antwort ist", "ich glaube, es ist", "es ist"}, Suffixes: []string{"ist meine antwort"}},
+		"nl": {Prefixes: []string{"het antwoord is", "ik denk dat het", "het is"}, Suffixes: []string{"is mijn antwoord"}},
+		"tr": {Prefixes: []string{"cevap", "bence"}, Suffixes: []string{"cevabım"}},
+	}
+}
+
+// WithPhrases replaces the phrases stripped from guesses for each language
+// given, keyed by base language such as "en". Other languages keep their
+// defaults.
+func WithPhrases(phrases map[string]Phrases) Option {
+	return func(c *Checker) {
+		for lang, p := range phrases {
+			c.phrases[strings.ToLower(lang)] = Phrases{Prefixes: lowerAll(p.Prefixes), Suffixes: lowerAll(p.Suffixes)}
+		}
+	}
+}
+
+func lowerAll(phrases []string) []string {
+	lowered := make([]string, 0, len(phrases))
+	for _, phrase := range phrases {
+		if phrase = strings.ToLower(strings.TrimSpace(phrase)); phrase != "" {
+			lowered = append(lowered, phrase)
+		}
+	}
+	return lowered
+}
+
+// phraseSeparators are trimmed from where a phrase was cut off, as in "the
+// answer is: a candle"
+const phraseSeparators = " \t\n:,-–—"
+
+// stripPhrases takes the phrases off a lowercased guess until none is left,
+// so stacked ones such as "i think it's probably a candle" are all removed.
+// Phrases match whole words only, and a guess is never stripped to nothing.
+func stripPhrases(guess string, phrases Phrases) string {
+	for changed := true; changed; {
+		changed = false
+		for _, prefix := range phrases.Prefixes {
+			rest, ok := strings.CutPrefix(guess, prefix)
+			if !ok || !wordBreak(rest, true) {
+				continue
+			}
+			if rest = strings.TrimLeft(rest, phraseSeparators); rest != "" {
+				guess, changed = rest, true
+			}
+		}
+		for _, suffix := range phrases.Suffixes {
+			rest, ok := strings.CutSuffix(guess, suffix)
+			if !ok || !wordBreak(rest, false) {
+				continue
+			}
+			if rest = strings.TrimRight(rest, phraseSeparators); rest != "" {
+				guess, changed = rest, true
+			}
+		}
+	}
+	return guess
+}
+
+// wordBreak reports whether what is left after cutting a phrase off starts,
+// or with after false ends, between words
+func wordBreak(rest string, after bool) bool {
+	if rest == "" {
+		return true
+	}
+	var r rune
+	if after {
+		r, _ = utf8.DecodeRuneInString(rest)
+	} else {
+		r, _ = utf8.DecodeLastRuneInString(rest)
+	}
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+}
@@ -0,0 +1,84 @@
+package answers
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"testing"
+)
+
+// TestStripPhrases checks the default English phrases are taken off a guess
+// however many are stacked up, at either end, and only as whole words
+func TestStripPhrases(t *testing.T) {
+	tests := []struct {
+		guess string
+		want  string
+	}{
+		{"a candle", "a candle"},
+		{"the answer is a candle", "a candle"},
+		{"i believe the answer is a candle", "a candle"},
+		{"i think it's probably a candle", "a candle"},
+		{"based on the clues, i'd say it must be a candle", "a candle"},
+		{"the answer is: a candle", "a candle"},
+		{"a candle is my answer", "a candle"},
+		{"a candle, i think", "a candle"},
+		{"my guess is a candle is my answer", "a candle"},
+		{"maybe it is a candle, i believe", "a candle"},
+		{"maybe a candle maybe", "a candle"},
+		// Never stripped to nothing
+		{"it's", "it's"},
+		{"the answer is", "the answer is"},
+		// Whole words only
+		{"it isn't a candle", "it isn't a candle"},
+		{"maybelline", "maybelline"},
+		{"a candle, i thinking", "a candle, i thinking"},
+	}
+	phrases := DefaultPhrases()["en"]
+	for _, tt := range tests {
+		t.Run(tt.guess, func(t *testing.T) {
+			if got := stripPhrases(tt.guess, phrases); got != tt.want {
+				t.Errorf("stripPhrases(%q) = %q, want %q", tt.guess, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPhrasesFile loads testdata/phrases.json, laid out as a deployment's
+// phrases.json is, and checks its languages are stripped with its phrases
+// while the rest keep the defaults
+func TestPhrasesFile(t *testing.T) {
+	data, err := os.ReadFile("testdata/phrases.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var phrases map[string]Phrases
+	if err := json.Unmarshal(data, &phrases); err != nil {
+		t.Fatal(err)
+	}
+	c := New(WithPhrases(phrases))
+
+	tests := []struct {
+		lang  string
+		guess string
+		want  string
+	}{
+		{"it", "secondo me la risposta è una candela", "una candela"},
+		{"it", "forse direi una candela, credo", "una candela"},
+		{"it", "una candela è la mia risposta", "una candela"},
+		{"it", "penso che sia una candela", "penso che sia una candela"}, // A default the file replaced
+		{"de", "ich denke, die antwort ist eine kerze", "eine kerze"},
+		{"de", "vielleicht es ist eine kerze, glaube ich", "eine kerze"},
+		{"de", "eine kerze ist meine antwort", "eine kerze"},
+		{"fr", "je pense que c'est une bougie", "une bougie"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.guess, func(t *testing.T) {
+			if got := stripPhrases(tt.guess, c.phrases[tt.lang]); got != tt.want {
+				t.Errorf("stripPhrases(%q) = %q, want %q", tt.guess, got, tt.want)
+			}
+		})
+	}
+	if fr := DefaultPhrases()["fr"]; !slices.Equal(c.phrases["fr"].Prefixes, fr.Prefixes) {
+		t.Errorf("French prefixes %q, want the defaults %q", c.phrases["fr"].Prefixes, fr.Prefixes)
+	}
+}
@@ -0,0 +1,56 @@
+package answers
+
+import "strings"
+
+// minQuotedWords is the shortest run of the riddle's words taken as quoting
+// it rather than sharing a word with it
+const minQuotedWords = 3
+
+// Restates reports whether a guess that matched only did so by quoting
+// the riddle back, as in "What has keys but can't open locks? A piano has
+// keys" when the riddle gives its own answer away. That takes most of the
+// guess's words coming from the riddle, and the guess no longer matching once
+// the runs of words it quotes are taken out.
+func (c *Checker) Restates(guess string, r *Riddle) bool {
+	if c.restatedThreshold <= 0 {
+		return false
+	}
+	guessWords := Words(guess, r.Language)
+	riddleWords := Words(r.Text, r.Language)
+	if len(guessWords) < minQuotedWords || len(riddleWords) == 0 {
+		return false
+	}
+
+	inRiddle := make(map[string]bool, len(riddleWords))
+	for _, word := range riddleWords {
+		inRiddle[word] = true
+	}
+	overlap := 0
+	for _, word := range guessWords {
+		if inRiddle[word] {
+			overlap++
+		}
+	}
+	if float64(overlap)/float64(len(guessWords)) <= c.restatedThreshold {
+		return false
+	}
+
+	// Take out each longest run of words that appears in the riddle as it is
+	var rest []string
+	for i := 0; i < len(guessWords); {
+		run := 0
+		for n := len(guessWords) - i; n >= minQuotedWords; n-- {
+			if containsWords(riddleWords, guessWords[i:i+n]) {
+				run = n
+				break
+			}
+		}
+		if run == 0 {
+			rest = append(rest, guessWords[i])
+			i++
+			continue
+		}
+		i += run
+	}
+	return !c.Check(strings.Join(rest, " "), r).Correct
+}
@@ -0,0 +1,201 @@
+package answers
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Typographic punctuation folded to its plain form before comparing
+var typographicFolds = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "′", "'",
+	"“", "\"", "”", "\"", "„", "\"", "″", "\"",
+	"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "―", "-", "−", "-",
+)
+
+// Scripts whose combining marks are accents, dropped when comparing.
+// Elsewhere a mark can make a different letter, as the voicing mark does
+// Japanese "が" from "か", so it is kept.
+var accentedScripts = []*unicode.RangeTable{unicode.Latin, unicode.Greek, unicode.Cyrillic, unicode.Arabic, unicode.Hebrew}
+
+// foldUnicode reduces text to plain characters: accents are dropped so
+// "café" matches "cafe", full-width letters become ASCII, and curly quotes
+// and dashes become straight ones
+func foldUnicode(text string) string {
+	decomposed := norm.NFKD.String(typographicFolds.Replace(text))
+	var b strings.Builder
+	b.Grow(len(decomposed))
+	accented := false
+	for _, r := range decomposed {
+		if !unicode.Is(unicode.Mn, r) {
+			accented = unicode.In(r, accentedScripts...)
+		} else if accented {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFC.String(b.String())
+}
+
+// Words splits text into lowercase words the way answers are compared,
+// ignoring accents, punctuation and any leading article of the language
+func Words(text string, tag language.Tag) []string {
+	return splitWords(text, tag, true, true)
+}
+
+// splitWords splits text into words, ignoring accents and punctuation, and
+// with foldCase and stripArticles, case and any leading article of the
+// language. Apostrophes are dropped rather than splitting words, so "man's"
+// and "mans" are the same word. Case is folded before accents, so Turkish "I"
+// and "İ" stay distinct.
+func splitWords(text string, tag language.Tag, foldCase, stripArticles bool) []string {
+	if foldCase {
+		text = lowerIn(text, tag)
+	}
+	text = strings.TrimSpace(foldUnicode(text))
+
+	rules := languageMatching[baseLanguage(tag)]
+	if stripArticles {
+		for _, article := range rules.elided {
+			if rest, ok := strings.CutPrefix(text, article); ok && rest != "" {
+				text = rest
+				break
+			}
+		}
+	}
+	text = strings.ReplaceAll(text, "'", "")
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if stripArticles && len(words) > 1 && slices.Contains(rules.articles, words[0]) {
+		words = words[1:]
+	}
+	return words
+}
+
+// Plurals that don't end in a plain "s", and words that do without being
+// plural
+var (
+	irregularPlurals = map[string]string{
+		"dice": "die", "mice": "mouse", "lice": "louse", "geese": "goose",
+		"feet": "foot", "teeth": "tooth", "men": "man", "women": "woman",
+		"children": "child", "people": "person", "oxen": "ox",
+		"knives": "knife", "wives": "wife", "lives": "life", "leaves": "leaf",
+		"wolves": "wolf", "halves": "half", "calves": "calf", "shelves": "shelf",
+		"loaves": "loaf", "thieves": "thief", "scarves": "scarf",
+		"buses": "bus", "gases": "gas", "viruses": "virus", "octopuses": "octopus",
+	}
+	notPlural = map[string]bool{
+		"species": true, "series": true, "news": true, "means": true,
+		"chaos": true, "lens": true, "physics": true, "mathematics": true,
+		"always": true, "perhaps": true, "whereas": true, "yes": true,
+	}
+)
+
+// singular folds an English plural to its singular, erring towards leaving
+// a word alone: "bus", "glass" and "species" are kept as they are
+func singular(word string) string {
+	if s, ok := irregularPlurals[word]; ok {
+		return s
+	}
+	if len(word) <= 3 || notPlural[word] {
+		return word
+	}
+	switch {
+	case strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "sses"), strings.HasSuffix(word, "xes"),
+		strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+		return word
+	case strings.HasSuffix(word, "s"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+func singularWords(words []string) []string {
+	folded := make([]string, len(words))
+	for i, word := range words {
+		folded[i] = singular(word)
+	}
+	return folded
+}
+
+// containsCompound reports whether the answer, written without spaces or
+// hyphens, is spelled out by a run of the guess's words, so "fire-fly",
+// "fire fly" and "firefly" all match one another
+func containsCompound(words, answer []string) bool {
+	compound := strings.Join(answer, "")
+	for i := range words {
+		run := ""
+		for j := i; j < len(words) && len(run) < len(compound); j++ {
+			run += words[j]
+			if run == compound {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// alternatives splits words at each or, as in "a cat or a dog or time", and
+// gives nil when there is no more than one alternative
+func alternatives(words []string, or string) [][]string {
+	if or == "" || !slices.Contains(words, or) {
+		return nil
+	}
+	var split [][]string
+	start := 0
+	for i, word := range words {
+		if word == or {
+			if i > start {
+				split = append(split, words[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(words) {
+		split = append(split, words[start:])
+	}
+	if len(split) < 2 {
+		return nil
+	}
+	return split
+}
+
+// containsWords reports whether want appears in words as a run of whole words
+func containsWords(words, want []string) bool {
+	for i := 0; i+len(want) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(want)], want) {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance is the Levenshtein distance between a and b, counted in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
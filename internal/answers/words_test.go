@@ -0,0 +1,155 @@
+package answers
+
+import (
+	"testing"
+
+	"golang.org/x/text/language"
+)
+
+// TestSingular keeps words that only look plural as they are
+func TestSingular(t *testing.T) {
+	tests := map[string]string{
+		"footsteps": "footstep",
+		"footstep":  "footstep",
+		"scissors":  "scissor",
+		"dice":      "die",
+		"die":       "die",
+		"candles":   "candle",
+		"berries":   "berry",
+		"boxes":     "box",
+		"matches":   "match",
+		"glasses":   "glass",
+		"glass":     "glass",
+		"bus":       "bus",
+		"buses":     "bus",
+		"species":   "species",
+		"series":    "series",
+		"news":      "news",
+		"octopus":   "octopus",
+		"analysis":  "analysis",
+		"knives":    "knife",
+		"teeth":     "tooth",
+		"gas":       "gas",
+		"yes":       "yes",
+	}
+	for word, want := range tests {
+		if got := singular(word); got != want {
+			t.Errorf("singular(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+// TestPluralFolding matches plurals with singulars without letting the traps
+// match words they aren't
+func TestPluralFolding(t *testing.T) {
+	tests := []struct {
+		guess   string
+		answer  string
+		correct bool
+	}{
+		{"footstep", "footsteps", true},
+		{"Footsteps", "footstep", true},
+		{"a scissor", "scissors", true},
+		{"scissors", "scissor", true},
+		{"dice", "die", true},
+		{"a die", "dice", true},
+		{"buses", "bus", true},
+		{"bus", "bu", false},
+		{"glass", "glas", false},
+		{"species", "specie", false},
+		{"series", "serie", false},
+		{"die", "dye", false},
+	}
+	c := New(WithFuzzyThreshold(0))
+	for _, tt := range tests {
+		t.Run(tt.guess+"/"+tt.answer, func(t *testing.T) {
+			match := c.Check(tt.guess, &Riddle{Answers: []string{tt.answer}})
+			if match.Correct != tt.correct {
+				t.Errorf("Check(%q) against %q: correct = %v, want %v", tt.guess, tt.answer, match.Correct, tt.correct)
+			}
+		})
+	}
+
+	// Off, and outside English, a plural is a different word
+	off := New(WithFuzzyThreshold(0), WithPluralFolding(false))
+	if off.Check("footsteps", &Riddle{Answers: []string{"footstep"}}).Correct {
+		t.Errorf("footsteps matched footstep with plural folding off")
+	}
+	if c.Check("maisons", &Riddle{Answers: []string{"maison"}, Language: language.French}).Correct {
+		t.Errorf("maisons matched maison in French")
+	}
+}
+
+// TestUnicodeFolding matches accented, typographic and full-width spellings
+// with plain ones, without merging words that are genuinely different
+func TestUnicodeFolding(t *testing.T) {
+	tests := []struct {
+		guess   string
+		answer  string
+		correct bool
+	}{
+		// Accents
+		{"cafe", "café", true},
+		{"Café", "cafe", true},
+		{"resume", "résumé", true},
+		{"naïve", "naive", true},
+		{"ÉCLAIR", "éclair", true},
+		{"İstanbul", "istanbul", true},
+		{"cafe", "cage", false},
+		// Typographic quotes and dashes
+		{"don't", "don’t", true},
+		{"don’t", "don't", true},
+		{"“echo”", "echo", true},
+		{"jack–in–the–box", "jack-in-the-box", true},
+		// Full-width and compatibility forms
+		{"ｃａｔ", "cat", true},
+		{"ＣＡＴ", "cat", true},
+		{"１２", "twelve", true},
+		{"ﬁre", "fire", true},
+		// CJK
+		{"猫", "猫", true},
+		{"犬", "猫", false},
+		{"東京", "京都", false},
+		{"がき", "かき", false},
+		{"ガス", "カス", false},
+		{"ぱん", "はん", false},
+		{"시간", "시간", true},
+		{"시계", "시간", false},
+		// Mixed scripts
+		{"Tokyo (東京)", "東京", true},
+		{"東京 Tokyo", "tokyo", true},
+		{"Москва", "Moscow", false},
+		{"cаt", "cat", false}, // Cyrillic "а"
+		{"Ёлка", "елка", true},
+		{"क्या", "कया", false},
+		{"سَلام", "سلام", true},
+	}
+	c := New(WithFuzzyThreshold(0))
+	for _, tt := range tests {
+		t.Run(tt.guess+"/"+tt.answer, func(t *testing.T) {
+			match := c.Check(tt.guess, &Riddle{Answers: []string{tt.answer}})
+			if match.Correct != tt.correct {
+				t.Errorf("Check(%q) against %q: correct = %v, want %v", tt.guess, tt.answer, match.Correct, tt.correct)
+			}
+		})
+	}
+}
+
+// TestStrictScripts keeps short answers strict only in alphabets, where a
+// letter or two is found in almost any reply
+func TestStrictScripts(t *testing.T) {
+	tests := map[string]bool{
+		"O":     true,
+		"pi":    true,
+		"the O": true,
+		"ant":   false,
+		"猫":     false,
+		"東京":    false,
+		"시간":    false,
+	}
+	for answer, strict := range tests {
+		if got := Strict(answer, language.Und); got != strict {
+			t.Errorf("Strict(%q) = %v, want %v", answer, got, strict)
+		}
+	}
+}
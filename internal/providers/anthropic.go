@@ -0,0 +1,179 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	Register("anthropic", anthropic{})
+}
+
+// Anthropic structures
+type AnthropicRequest struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system,omitempty"`
+	Messages    []AnthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Stream      bool               `json:"stream"`
+	Thinking    *AnthropicThinking `json:"thinking,omitempty"`
+	Temperature *float64           `json:"temperature,omitempty"`
+}
+
+type AnthropicThinking struct {
+	Type         string `json:"type"` // "enabled"
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+type AnthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type AnthropicStreamResponse struct {
+	Type         string `json:"type"`
+	ContentBlock struct {
+		Type string `json:"type"` // "text", "thinking" or "redacted_thinking"
+	} `json:"content_block"`
+	Delta struct {
+		Type       string `json:"type"` // "text_delta", "thinking_delta" or "signature_delta"
+		Text       string `json:"text"`
+		Thinking   string `json:"thinking"`
+		StopReason string `json:"stop_reason"` // On message_delta, e.g. "end_turn" or "max_tokens"
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"` // On an error event
+	Message struct {
+		Usage AnthropicUsage `json:"usage"`
+	} `json:"message"` // On message_start
+	Usage AnthropicUsage `json:"usage"` // On message_delta, output tokens so far
+}
+
+type AnthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type anthropic struct{}
+
+func (anthropic) APIKeyEnv() string { return "ANTHROPIC_API_KEY" }
+
+func (anthropic) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	reqBody := AnthropicRequest{
+		Model:  cfg.Model,
+		System: cfg.SystemPrompt,
+		Messages: []AnthropicMessage{
+			{Role: "user", Content: prompt},
+		},
+		MaxTokens:   cfg.maxTokensOr(1024),
+		Stream:      true,
+		Temperature: cfg.Temperature,
+	}
+	if cfg.Thinking != nil {
+		// max_tokens has to cover the thinking budget as well as the answer
+		reqBody.Thinking = &AnthropicThinking{Type: "enabled", BudgetTokens: cfg.Thinking.BudgetTokens}
+		reqBody.MaxTokens += cfg.Thinking.BudgetTokens
+	}
+
+	body, err := withOptions(cfg, reqBody)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", cfg.APIKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	ctx, stopWatching := watchStream(ctx, cfg, resp)
+	defer stopWatching()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	var fullResponse strings.Builder
+	scanner := newStreamScanner(resp.Body)
+
+	// Only text blocks are the answer. Some gateways send thinking as
+	// text_delta, so the block type from content_block_start decides, not the
+	// delta type.
+	// Input tokens arrive with message_start and the output count with each
+	// message_delta; the last one is reported
+	blockType := "text"
+	var usage Usage
+	reported := false
+	var finished error
+	stopped := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+
+		var streamResp AnthropicStreamResponse
+		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
+			continue
+		}
+
+		switch streamResp.Type {
+		case "message_start":
+			usage.PromptTokens = streamResp.Message.Usage.InputTokens
+			usage.CompletionTokens = streamResp.Message.Usage.OutputTokens
+		case "message_delta":
+			if streamResp.Usage.InputTokens > 0 {
+				usage.PromptTokens = streamResp.Usage.InputTokens
+			}
+			usage.CompletionTokens = streamResp.Usage.OutputTokens
+			reported = true
+			finished = finishError(streamResp.Delta.StopReason)
+		case "message_stop":
+			stopped = true
+		case "error":
+			return fullResponse.String(), &StreamError{Provider: cfg.Provider, Code: streamResp.Error.Type, Message: streamResp.Error.Message}
+		case "content_block_start":
+			blockType = streamResp.ContentBlock.Type
+		case "content_block_stop":
+			blockType = "text"
+		case "content_block_delta":
+			switch {
+			case streamResp.Delta.Type == "signature_delta":
+				// Verifies the thinking block; nothing to show
+			case streamResp.Delta.Type == "thinking_delta":
+				thinking(ctx, streamResp.Delta.Thinking)
+			case blockType != "text":
+				thinking(ctx, streamResp.Delta.Text+streamResp.Delta.Thinking)
+			case streamResp.Delta.Type == "text_delta":
+				content := streamResp.Delta.Text
+				fullResponse.WriteString(content)
+				onToken(content)
+			}
+		}
+	}
+
+	if reported {
+		reportUsage(ctx, usage)
+	}
+	if err := scanner.Err(); err != nil || ctx.Err() != nil {
+		return streamResult(ctx, fullResponse.String(), err)
+	}
+	if !stopped {
+		return fullResponse.String(), ErrStreamIncomplete
+	}
+	return fullResponse.String(), finished
+}
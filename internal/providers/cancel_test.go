@@ -0,0 +1,116 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowServer answers every provider the same way: a first line, then nothing
+// until the client goes away. A Replicate prediction is created first, and
+// points at the server's stream.
+func slowServer(t *testing.T, requested chan<- struct{}, open *atomic.Int64) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/predictions") {
+			fmt.Fprint(w, `{"id": "p1", "urls": {"stream": "https://stream.replicate.test/stream", "cancel": "https://api.replicate.com/v1/predictions/p1/cancel"}}`)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/cancel") {
+			return
+		}
+		select {
+		case requested <- struct{}{}:
+		default:
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "{}\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			open.Add(1)
+		case http.StateClosed, http.StateHijacked:
+			open.Add(-1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestStreamStopsOnCancel cancels every provider's call partway through a
+// stream that never finishes, and checks that the call returns at once and
+// leaves no goroutines or connections behind
+func TestStreamStopsOnCancel(t *testing.T) {
+	requested := make(chan struct{}, 1)
+	var open atomic.Int64
+	server := slowServer(t, requested, &open)
+	redirect(t, server)
+
+	vertexTokens.mu.Lock()
+	vertexTokens.token, vertexTokens.expires = "vertex-token", time.Now().Add(time.Hour)
+	vertexTokens.mu.Unlock()
+
+	baseline := runtime.NumGoroutine()
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			provider, _ := Lookup(name)
+			cfg := testConfig(name, server.URL)
+			if err := Validate(cfg); err != nil {
+				t.Fatalf("test config invalid: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() {
+				_, err := provider.Stream(ctx, cfg, "What has keys but can't open locks?", func(string) {})
+				done <- err
+			}()
+
+			// The mock makes no request, so it is cancelled during its delay
+			if name != "mock" {
+				select {
+				case <-requested:
+				case err := <-done:
+					t.Fatalf("returned before the stream started: %v", err)
+				case <-time.After(5 * time.Second):
+					t.Fatal("never called the server")
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+
+			select {
+			case err := <-done:
+				if err == nil {
+					t.Error("a cancelled stream returned no error")
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("still streaming 2s after cancellation")
+			}
+		})
+	}
+
+	httpClient.CloseIdleConnections()
+	deadline := time.Now().Add(3 * time.Second)
+	for (runtime.NumGoroutine() > baseline || open.Load() > 0) && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+		httpClient.CloseIdleConnections()
+	}
+	if leaked := runtime.NumGoroutine() - baseline; leaked > 0 {
+		buf := make([]byte, 1<<16)
+		t.Errorf("%d goroutines left after cancelling every provider:\n%s", leaked, buf[:runtime.Stack(buf, true)])
+	}
+	if n := open.Load(); n > 0 {
+		t.Errorf("%d connections to the server still open", n)
+	}
+}
@@ -0,0 +1,211 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// OpenAI chat-completions structures, shared by every provider that speaks
+// the OpenAI protocol
+type OpenAIRequest struct {
+	Model          string                `json:"model"`
+	Messages       []OpenAIMessage       `json:"messages"`
+	Stream         bool                  `json:"stream"`
+	Temperature    *float64              `json:"temperature,omitempty"`
+	MaxTokens      *int                  `json:"max_tokens,omitempty"`
+	StreamOptions  *OpenAIStreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *OpenAIResponseFormat `json:"response_format,omitempty"`
+}
+
+// OpenAIResponseFormat holds a model to replying in JSON
+type OpenAIResponseFormat struct {
+	Type string `json:"type"` // "json_object"
+}
+
+// jsonResponseFormat is the response format for a model with structured
+// answers on, or nil to leave the reply as plain text
+func jsonResponseFormat(cfg ModelConfig) *OpenAIResponseFormat {
+	if !cfg.StructuredAnswers {
+		return nil
+	}
+	return &OpenAIResponseFormat{Type: "json_object"}
+}
+
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type OpenAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type OpenAIStreamResponse struct {
+	Choices []struct {
+		Delta struct {
+			Content          string `json:"content"`
+			ReasoningContent string `json:"reasoning_content"` // DeepSeek R1 and other reasoning models
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *OpenAIUsage       `json:"usage"` // Final chunk, when the provider reports usage
+	Error *OpenAIStreamError `json:"error"` // Sent instead of a chunk when the call fails mid-stream
+}
+
+type OpenAIStreamError struct {
+	Message string      `json:"message"`
+	Type    string      `json:"type"`
+	Code    interface{} `json:"code"`
+}
+
+// chatCompletionsRequest describes a chat-completions call to any provider
+// that speaks the OpenAI streaming protocol
+type chatCompletionsRequest struct {
+	URL          string
+	Headers      map[string]string
+	IncludeUsage bool // Ask for a usage chunk; providers that send one unasked are read anyway
+	JSONMode     bool // The provider accepts response_format, so structured answers can be asked for
+}
+
+// bearer is the usual header set for providers authenticating with an API key
+func bearer(apiKey string) map[string]string {
+	return map[string]string{"Authorization": "Bearer " + apiKey}
+}
+
+// streamChatCompletions posts an OpenAI-shaped chat completion and passes the
+// streamed answer deltas to onToken
+func streamChatCompletions(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string), call chatCompletionsRequest) (string, error) {
+	reqBody := OpenAIRequest{
+		Model:       cfg.Model,
+		Messages:    cfg.systemMessages(prompt),
+		Stream:      true,
+		Temperature: cfg.Temperature,
+		MaxTokens:   cfg.MaxTokens,
+	}
+	if call.JSONMode {
+		reqBody.ResponseFormat = jsonResponseFormat(cfg)
+	}
+	if call.IncludeUsage {
+		reqBody.StreamOptions = &OpenAIStreamOptions{IncludeUsage: true}
+	}
+
+	body, err := withOptions(cfg, reqBody)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", call.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range call.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	ctx, stopWatching := watchStream(ctx, cfg, resp)
+	defer stopWatching()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	// Reasoning models send their chain of thought either as reasoning_content
+	// or inline in <think> tags; both are passed on as thinking and kept out
+	// of the answer
+	var fullResponse strings.Builder
+	var splitter thinkSplitter
+	emit := func(answer string, reasoning string) {
+		thinking(ctx, reasoning)
+		if answer == "" {
+			return
+		}
+		fullResponse.WriteString(answer)
+		onToken(answer)
+	}
+
+	scanner := newStreamScanner(resp.Body)
+	var finished error
+	// Set once the provider says the response is over, with [DONE] or a
+	// finish reason; a stream that just stops is incomplete
+	done := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+
+		var streamResp OpenAIStreamResponse
+		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
+			continue
+		}
+
+		if streamResp.Error != nil {
+			code, _ := streamResp.Error.Code.(string)
+			if code == "" {
+				code = streamResp.Error.Type
+			}
+			emit(splitter.Flush())
+			return fullResponse.String(), &StreamError{Provider: cfg.Provider, Code: code, Message: streamResp.Error.Message}
+		}
+
+		if streamResp.Usage != nil {
+			reportUsage(ctx, streamResp.Usage.usage())
+		}
+
+		if len(streamResp.Choices) > 0 {
+			delta := streamResp.Choices[0].Delta
+			thinking(ctx, delta.ReasoningContent)
+			emit(splitter.Split(delta.Content))
+			if reason := streamResp.Choices[0].FinishReason; reason != "" {
+				done = true
+				if err := finishError(reason); err != nil {
+					finished = err
+				}
+			}
+		}
+	}
+	emit(splitter.Flush())
+
+	if err := scanner.Err(); err != nil || ctx.Err() != nil {
+		return streamResult(ctx, fullResponse.String(), err)
+	}
+	if !done {
+		return fullResponse.String(), ErrStreamIncomplete
+	}
+	return fullResponse.String(), finished
+}
+
+func (u OpenAIUsage) usage() Usage {
+	return Usage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+}
+
+// chatCompletionsURL turns a base URL such as "http://localhost:8000/v1" into
+// its chat-completions endpoint, accepting the full endpoint URL as well
+func chatCompletionsURL(base string) string {
+	base = strings.TrimRight(base, "/")
+	if strings.HasSuffix(base, "/chat/completions") {
+		return base
+	}
+	return base + "/chat/completions"
+}
@@ -0,0 +1,52 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestChatCompletionsEnd checks how a chat-completions stream ends: a stream
+// is only finished once the provider says so, with [DONE] or a finish reason
+func TestChatCompletionsEnd(t *testing.T) {
+	chunk := func(content, finish string) string {
+		reason := "null"
+		if finish != "" {
+			reason = fmt.Sprintf("%q", finish)
+		}
+		return fmt.Sprintf("data: {\"choices\":[{\"delta\":{\"content\":%q},\"finish_reason\":%s}]}\n\n", content, reason)
+	}
+	tests := []struct {
+		name   string
+		stream string
+		want   string
+		err    error
+	}{
+		{"done", chunk("a ", "") + chunk("piano", "") + "data: [DONE]\n\n", "a piano", nil},
+		{"finish reason", chunk("a ", "") + chunk("piano", "stop"), "a piano", nil},
+		{"finish reason and done", chunk("a piano", "stop") + "data: [DONE]\n\n", "a piano", nil},
+		{"token limit", chunk("a pi", "length") + "data: [DONE]\n\n", "a pi", ErrResponseTruncated},
+		{"content filter", chunk("a", "content_filter") + "data: [DONE]\n\n", "a", ErrContentFiltered},
+		{"cut off", chunk("a ", "") + chunk("pia", ""), "a pia", ErrStreamIncomplete},
+		{"nothing parseable", "data: {\"choices\":[\x00\n\n", "", ErrStreamIncomplete},
+		{"empty", "", "", ErrStreamIncomplete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, tt.stream)
+			}))
+			defer server.Close()
+
+			provider, _ := Lookup("openai-compatible")
+			got, err := provider.Stream(context.Background(), testConfig("openai-compatible", server.URL), "riddle", func(string) {})
+			if got != tt.want || !errors.Is(err, tt.err) {
+				t.Errorf("got %q, %v; want %q, %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package providers
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Provider calls share transports so connections to a host are kept alive
+// and reused across rounds and games instead of paying for a new TCP and TLS
+// handshake on every call. Per-call deadlines come from the caller's context;
+// the transport only bounds the steps a context deadline can't tell apart.
+// Connections are direct unless a model sets a proxy.
+var transport = &http.Transport{
+	DialContext: (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:   true,
+	MaxIdleConns:        100,
+	MaxIdleConnsPerHost: 16, // Several models on one Ollama or vLLM host are called in parallel
+	IdleConnTimeout:     90 * time.Second,
+	TLSHandshakeTimeout: 10 * time.Second,
+	// Generous, since reasoning models and cold HuggingFace models send no
+	// headers until they have answered
+	ResponseHeaderTimeout: 5 * time.Minute,
+	ExpectContinueTimeout: 1 * time.Second,
+}
+
+var httpClient = &http.Client{Transport: loggingTransport{transport}}
+
+// HTTPClient returns the shared client for direct calls, for the server's own
+// requests to model APIs outside a provider, such as moderation. Its requests
+// are logged and redacted like a provider's.
+func HTTPClient() *http.Client {
+	return httpClient
+}
+
+// ProxyFromEnv is the proxy setting that uses HTTPS_PROXY, HTTP_PROXY and
+// NO_PROXY instead of a fixed URL
+const ProxyFromEnv = "env"
+
+type clientKey struct {
+	proxy    string
+	insecure bool
+}
+
+var (
+	clientsMux sync.Mutex
+	clients    = map[clientKey]*http.Client{{}: httpClient}
+)
+
+// scrubbedClient is a shared client whose errors never quote a credential.
+// A failed request's error quotes its URL, and Gemini's key is a URL
+// parameter.
+type scrubbedClient struct {
+	*http.Client
+}
+
+func (c scrubbedClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.Client.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactRequest(req).URL
+	}
+	return resp, err
+}
+
+// clientFor returns the shared client a model's calls should go through. Each
+// combination of proxy and certificate checking gets its own transport.
+func clientFor(cfg ModelConfig) scrubbedClient {
+	key := clientKey{proxy: cfg.Proxy, insecure: cfg.InsecureSkipVerify}
+
+	clientsMux.Lock()
+	defer clientsMux.Unlock()
+	if client, ok := clients[key]; ok {
+		return scrubbedClient{client}
+	}
+
+	t := transport.Clone()
+	switch key.proxy {
+	case "":
+	case ProxyFromEnv:
+		t.Proxy = http.ProxyFromEnvironment
+	default:
+		// Validated at startup, see validateProxy
+		proxyURL, _ := url.Parse(key.proxy)
+		t.Proxy = http.ProxyURL(proxyURL)
+	}
+	if key.insecure {
+		// For e.g. a vLLM box on the local network with a self-signed certificate
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	client := &http.Client{Transport: loggingTransport{t}}
+	clients[key] = client
+	return scrubbedClient{client}
+}
+
+func validateProxy(proxy string) error {
+	if proxy == "" || proxy == ProxyFromEnv {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("proxy %q is not a URL like http://proxy.internal:3128", proxy)
+	}
+	return nil
+}
+
+// DescribeProxy says how a model's calls leave the server, with any proxy
+// credentials masked, for the startup log
+func DescribeProxy(cfg ModelConfig) string {
+	switch cfg.Proxy {
+	case "":
+		return "direct"
+	case ProxyFromEnv:
+		return "proxied per environment"
+	}
+	return "proxied via " + redactProxy(cfg.Proxy)
+}
+
+// redactProxy masks the whole userinfo of a proxy URL, since proxies that take
+// a token often put it in the username
+func redactProxy(proxy string) string {
+	if proxy == "" || proxy == ProxyFromEnv {
+		return proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return "****"
+	}
+	if u.User != nil {
+		u.User = url.User("xxxxx")
+	}
+	return u.String()
+}
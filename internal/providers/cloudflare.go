@@ -0,0 +1,122 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	Register("cloudflare", cloudflare{})
+}
+
+// Cloudflare Workers AI structures
+type CloudflareRequest struct {
+	Messages    []OpenAIMessage `json:"messages"`
+	Stream      bool            `json:"stream"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	MaxTokens   *int            `json:"max_tokens,omitempty"`
+}
+
+// CloudflareStreamEvent is one SSE event in stream mode
+type CloudflareStreamEvent struct {
+	Response string `json:"response"`
+}
+
+// CloudflareResponse is the envelope of a non-streaming answer
+type CloudflareResponse struct {
+	Result struct {
+		Response string `json:"response"`
+	} `json:"result"`
+	Success bool `json:"success"`
+	Errors  []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type cloudflare struct{}
+
+func (cloudflare) APIKeyEnv() string { return "CLOUDFLARE_API_TOKEN" }
+
+func (cloudflare) Validate(cfg ModelConfig) error {
+	if cfg.AccountID == "" {
+		return errors.New("the cloudflare provider requires an accountId")
+	}
+	return nil
+}
+
+// Stream runs a model on Workers AI in stream mode. Models that can't stream
+// answer with the JSON envelope instead, which is returned all at once.
+func (cloudflare) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	reqBody := CloudflareRequest{
+		Messages:    cfg.systemMessages(prompt),
+		Stream:      true,
+		Temperature: cfg.Temperature,
+		MaxTokens:   cfg.MaxTokens,
+	}
+
+	body, err := withOptions(cfg, reqBody)
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/%s/ai/run/%s", cfg.AccountID, cfg.Model)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	ctx, stopWatching := watchStream(ctx, cfg, resp)
+	defer stopWatching()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var envelope CloudflareResponse
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+			return streamResult(ctx, "", err)
+		}
+		if !envelope.Success && len(envelope.Errors) > 0 {
+			return "", fmt.Errorf("cloudflare: %s", envelope.Errors[0].Message)
+		}
+		return envelope.Result.Response, nil
+	}
+
+	var fullResponse strings.Builder
+	scanner := newStreamScanner(resp.Body)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			break
+		}
+
+		var event CloudflareStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			continue
+		}
+
+		fullResponse.WriteString(event.Response)
+		onToken(event.Response)
+	}
+
+	return streamResult(ctx, fullResponse.String(), scanner.Err())
+}
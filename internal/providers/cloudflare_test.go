@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestCloudflareResponses checks both shapes Workers AI answers in, the SSE
+// stream and the result.response envelope of models that can't stream, and
+// the errors envelope it reports failures in
+func TestCloudflareResponses(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        string
+		tokens      []string
+		err         string // Part of the error message, "" for none
+	}{
+		{
+			name:        "stream",
+			status:      http.StatusOK,
+			contentType: "text/event-stream",
+			body:        "data: {\"response\":\"a \"}\n\ndata: {\"response\":\"piano\"}\n\ndata: [DONE]\n\n",
+			want:        "a piano",
+			tokens:      []string{"a ", "piano"},
+		},
+		{
+			name:        "stream with usage",
+			status:      http.StatusOK,
+			contentType: "text/event-stream",
+			body:        "data: {\"response\":\"a piano\",\"p\":\"abc\"}\n\ndata: {\"response\":\"\",\"usage\":{\"prompt_tokens\":30,\"completion_tokens\":3}}\n\ndata: [DONE]\n\n",
+			want:        "a piano",
+			tokens:      []string{"a piano", ""},
+		},
+		{
+			name:        "envelope",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"result":{"response":"a piano"},"success":true,"errors":[],"messages":[]}`,
+			want:        "a piano",
+		},
+		{
+			name:        "error envelope",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"result":null,"success":false,"errors":[{"code":5006,"message":"Error: oneOf at '/' not met, 0 matches"}],"messages":[]}`,
+			err:         "oneOf at '/' not met",
+		},
+		{
+			name:        "error status",
+			status:      http.StatusBadRequest,
+			contentType: "application/json",
+			body:        `{"result":null,"success":false,"errors":[{"code":7003,"message":"No route for the URI"}],"messages":[]}`,
+			err:         "No route for the URI",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/client/v4/accounts/account/ai/run/test-model" || r.Header.Get("Authorization") != "Bearer sk-test-key-1234" {
+					http.Error(w, "wrong request "+r.URL.Path, http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+			redirect(t, server)
+
+			var tokens []string
+			provider, _ := Lookup("cloudflare")
+			got, err := provider.Stream(context.Background(), testConfig("cloudflare", ""), "riddle", func(token string) {
+				tokens = append(tokens, token)
+			})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got %q, %v; want an error with %q", got, err, tt.err)
+				}
+				var providerErr *ProviderError
+				if tt.status != http.StatusOK && (!errors.As(err, &providerErr) || providerErr.StatusCode != tt.status || providerErr.Message != tt.err) {
+					t.Errorf("error %#v, want a ProviderError with status %d and message %q", err, tt.status, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v; want %q", got, err, tt.want)
+			}
+			if !slices.Equal(tokens, tt.tokens) {
+				t.Errorf("streamed %q, want %q", tokens, tt.tokens)
+			}
+		})
+	}
+}
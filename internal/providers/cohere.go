@@ -0,0 +1,97 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	Register("cohere", cohere{})
+}
+
+// Cohere structures
+type CohereRequest struct {
+	Model       string          `json:"model"`
+	Messages    []OpenAIMessage `json:"messages"`
+	Stream      bool            `json:"stream"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	MaxTokens   *int            `json:"max_tokens,omitempty"`
+}
+
+type CohereStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Message struct {
+			Content struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"message"`
+	} `json:"delta"`
+}
+
+type cohere struct{}
+
+func (cohere) APIKeyEnv() string { return "COHERE_API_KEY" }
+
+func (cohere) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	reqBody := CohereRequest{
+		Model:       cfg.Model,
+		Messages:    cfg.systemMessages(prompt),
+		Stream:      true,
+		Temperature: cfg.Temperature,
+		MaxTokens:   cfg.MaxTokens,
+	}
+
+	body, err := withOptions(cfg, reqBody)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.cohere.com/v2/chat", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	ctx, stopWatching := watchStream(ctx, cfg, resp)
+	defer stopWatching()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	var fullResponse strings.Builder
+	scanner := newStreamScanner(resp.Body)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event CohereStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "content-delta":
+			content := event.Delta.Message.Content.Text
+			fullResponse.WriteString(content)
+			onToken(content)
+		case "message-end":
+			return streamResult(ctx, fullResponse.String(), nil)
+		}
+	}
+
+	return streamResult(ctx, fullResponse.String(), scanner.Err())
+}
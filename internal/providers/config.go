@@ -0,0 +1,137 @@
+package providers
+
+import (
+	"net/url"
+	"time"
+)
+
+type ModelConfig struct {
+	Name               string                 `json:"name"`
+	Provider           string                 `json:"provider"` // A registered provider name, see Names
+	Model              string                 `json:"model"`
+	APIKey             string                 `json:"apiKey"`
+	Endpoint           string                 `json:"endpoint"`
+	Enabled            *bool                  `json:"enabled"`                      // Defaults to true; disabled models are kept out of selection
+	InstanceLabel      string                 `json:"instanceLabel,omitempty"`      // Distinguishes hosts serving the same model name
+	Streaming          bool                   `json:"streaming,omitempty"`          // HuggingFace: the endpoint is a TGI server with /generate_stream
+	Project            string                 `json:"project,omitempty"`            // Vertex AI: Google Cloud project ID
+	Region             string                 `json:"region,omitempty"`             // Vertex AI: location, e.g. "us-central1"
+	AccountID          string                 `json:"accountId,omitempty"`          // Cloudflare Workers AI: the account the model runs under
+	TimeoutSeconds     int                    `json:"timeoutSeconds,omitempty"`     // Total time allowed per call, overriding the provider's timeouts.totalSeconds
+	IdleTimeoutSeconds int                    `json:"idleTimeoutSeconds,omitempty"` // Longest gap allowed between streamed chunks, 15 by default; negative disables
+	InsecureSkipVerify bool                   `json:"insecureSkipVerify,omitempty"` // Accept self-signed certificates from the endpoint, for local servers only
+	Proxy              string                 `json:"proxy,omitempty"`              // Proxy URL for this model's calls, "env" to use HTTPS_PROXY and friends; empty connects directly
+	LegacyGenerate     bool                   `json:"legacyGenerate,omitempty"`     // Ollama: use /api/generate for versions without /api/chat
+	KeepAlive          string                 `json:"keepAlive,omitempty"`          // Ollama: how long the model stays loaded after a call, defaults to "10m"
+	Thinking           *ThinkingConfig        `json:"thinking,omitempty"`           // Anthropic: enables extended thinking
+	Reasoning          bool                   `json:"reasoning,omitempty"`          // OpenAI: o-series reasoning model, detected from the model name if unset
+	ReasoningEffort    string                 `json:"reasoningEffort,omitempty"`    // OpenAI reasoning models: "low", "medium" or "high"
+	Temperature        *float64               `json:"temperature,omitempty"`        // Sampling temperature, the provider's default if unset
+	MaxTokens          *int                   `json:"maxTokens,omitempty"`          // Longest answer in tokens, the server's maxTokens if unset
+	MaxResponseChars   int                    `json:"maxResponseChars,omitempty"`   // Characters of the reply scored before the call is cut off, the server's maxResponseChars if 0; negative disables
+	ContextBudget      int                    `json:"contextBudget,omitempty"`      // Rough tokens a prompt may take with the system prompt and earlier turns, past which older clues and guesses are cut; 0 for no limit
+	StructuredAnswers  bool                   `json:"structuredAnswers,omitempty"`  // OpenAI, Groq, Mistral and Ollama: ask for the answer as JSON with a confidence
+	ReasoningMode      bool                   `json:"reasoningMode,omitempty"`      // Ask the model to think step by step and score only the last line of its reply
+	SystemPrompt       string                 `json:"systemPrompt,omitempty"`       // Persona or standing instructions, sent as the system message where the provider has one
+	FewShot            []FewShotExample       `json:"fewShot,omitempty"`            // Worked examples for this model, replacing the global fewShot; [] turns them off
+	Options            map[string]interface{} `json:"options,omitempty"`            // Extra request fields such as top_p, stop or seed, merged into the provider's request body
+}
+
+// FewShotExample is a solved riddle shown to a model before the real one
+type FewShotExample struct {
+	Riddle string `json:"riddle"`
+	Answer string `json:"answer"`
+}
+
+// ThinkingConfig turns on extended thinking for models that support it
+type ThinkingConfig struct {
+	BudgetTokens int `json:"budgetTokens"`
+}
+
+// Instance identifies which deployment of a model this is: the configured
+// instance label, else the endpoint host, else the provider
+func (m ModelConfig) Instance() string {
+	if m.InstanceLabel != "" {
+		return m.InstanceLabel
+	}
+	if m.Endpoint != "" {
+		if u, err := url.Parse(m.Endpoint); err == nil && u.Host != "" {
+			return u.Host
+		}
+	}
+	return m.Provider
+}
+
+// StatsKey is the key of the model's entry in the stats' per-model map
+func (m ModelConfig) StatsKey() string {
+	return m.Name + "@" + m.Instance()
+}
+
+// maxTokensOr returns the configured token limit, or fallback if unset
+func (m ModelConfig) maxTokensOr(fallback int) int {
+	if m.MaxTokens != nil {
+		return *m.MaxTokens
+	}
+	return fallback
+}
+
+// temperatureOr returns the configured temperature, or fallback if unset
+func (m ModelConfig) temperatureOr(fallback float64) float64 {
+	if m.Temperature != nil {
+		return *m.Temperature
+	}
+	return fallback
+}
+
+// systemMessages are the chat messages for a prompt, after the system prompt
+// if the model has one
+func (m ModelConfig) systemMessages(prompt string) []OpenAIMessage {
+	if m.SystemPrompt == "" {
+		return []OpenAIMessage{{Role: "user", Content: prompt}}
+	}
+	return []OpenAIMessage{{Role: "system", Content: m.SystemPrompt}, {Role: "user", Content: prompt}}
+}
+
+// withSystemPrompt puts the system prompt ahead of the prompt, for providers
+// that take plain text with no separate system message
+func (m ModelConfig) withSystemPrompt(prompt string) string {
+	if m.SystemPrompt == "" {
+		return prompt
+	}
+	return m.SystemPrompt + "\n\n" + prompt
+}
+
+// Redacted returns the config as it may be served or logged: the API key is
+// left out and any proxy credentials are masked
+func (m ModelConfig) Redacted() ModelConfig {
+	m.APIKey = ""
+	m.Proxy = redactProxy(m.Proxy)
+	return m
+}
+
+// IsEnabled reports whether the model may be selected for new games
+func (m ModelConfig) IsEnabled() bool {
+	return m.Enabled == nil || *m.Enabled
+}
+
+// ChatMessage is one turn of a provider-agnostic conversation
+type ChatMessage struct {
+	Role    string `json:"role"` // "system", "user" or "assistant"
+	Content string `json:"content"`
+}
+
+// defaultIdleTimeout is how long a stream may go quiet before it is
+// abandoned as stalled
+const defaultIdleTimeout = 15 * time.Second
+
+// idleTimeout returns the longest gap allowed between streamed chunks, or 0
+// if stalls aren't detected for this model
+func (m ModelConfig) idleTimeout() time.Duration {
+	switch {
+	case m.IdleTimeoutSeconds < 0:
+		return 0
+	case m.IdleTimeoutSeconds == 0:
+		return defaultIdleTimeout
+	}
+	return time.Duration(m.IdleTimeoutSeconds) * time.Second
+}
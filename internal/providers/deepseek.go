@@ -0,0 +1,20 @@
+package providers
+
+import "context"
+
+func init() {
+	Register("deepseek", deepSeek{})
+}
+
+type deepSeek struct{}
+
+func (deepSeek) APIKeyEnv() string { return "DEEPSEEK_API_KEY" }
+
+// Stream calls DeepSeek's chat endpoint. R1's reasoning is passed on as
+// thinking and only the final answer is returned for checking.
+func (deepSeek) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	return streamChatCompletions(ctx, cfg, prompt, onToken, chatCompletionsRequest{
+		URL:     "https://api.deepseek.com/chat/completions",
+		Headers: bearer(cfg.APIKey),
+	})
+}
@@ -0,0 +1,191 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	Register("google", google{})
+}
+
+// Google Gemini structures
+type GeminiRequest struct {
+	SystemInstruction *GeminiContent          `json:"system_instruction,omitempty"`
+	Contents          []GeminiContent         `json:"contents"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type GeminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
+}
+
+// geminiSystemInstruction is the model's system prompt, or nil without one
+func geminiSystemInstruction(cfg ModelConfig) *GeminiContent {
+	if cfg.SystemPrompt == "" {
+		return nil
+	}
+	return &GeminiContent{Parts: []GeminiPart{{Text: cfg.SystemPrompt}}}
+}
+
+// geminiGenerationConfig is nil when the model leaves both to Gemini's defaults
+func geminiGenerationConfig(cfg ModelConfig) *GeminiGenerationConfig {
+	if cfg.Temperature == nil && cfg.MaxTokens == nil {
+		return nil
+	}
+	return &GeminiGenerationConfig{Temperature: cfg.Temperature, MaxOutputTokens: cfg.MaxTokens}
+}
+
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"` // Required by Vertex AI
+	Parts []GeminiPart `json:"parts"`
+}
+
+type GeminiPart struct {
+	Text string `json:"text"`
+}
+
+type GeminiResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []GeminiPart `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+}
+
+type google struct{}
+
+func (google) APIKeyEnv() string { return "GOOGLE_API_KEY" }
+
+// Stream streams from Gemini's SSE endpoint. Older models without a streaming
+// endpoint answer 404 and get a blocking generateContent call instead.
+func (p google) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	reqBody := GeminiRequest{
+		SystemInstruction: geminiSystemInstruction(cfg),
+		Contents: []GeminiContent{
+			{
+				Parts: []GeminiPart{
+					{Text: prompt},
+				},
+			},
+		},
+		GenerationConfig: geminiGenerationConfig(cfg),
+	}
+
+	body, err := withGeminiOptions(cfg, reqBody)
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/%s:streamGenerateContent?alt=sse&key=%s", cfg.Model, cfg.APIKey)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	response, err := streamGeminiSSE(ctx, cfg, req, onToken)
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) && providerErr.StatusCode == http.StatusNotFound {
+		return p.generate(ctx, cfg, prompt)
+	}
+	return response, err
+}
+
+// streamGeminiSSE sends a streamGenerateContent request and passes on the
+// text of each chunk, shared by the AI Studio and Vertex AI providers
+func streamGeminiSSE(ctx context.Context, cfg ModelConfig, req *http.Request, onToken func(string)) (string, error) {
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	ctx, stopWatching := watchStream(ctx, cfg, resp)
+	defer stopWatching()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	var fullResponse strings.Builder
+	scanner := newStreamScanner(resp.Body)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &chunk); err != nil {
+			continue
+		}
+
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			fullResponse.WriteString(part.Text)
+			onToken(part.Text)
+		}
+	}
+
+	return streamResult(ctx, fullResponse.String(), scanner.Err())
+}
+
+// generate makes a blocking Gemini call for models that can't stream
+func (google) generate(ctx context.Context, cfg ModelConfig, prompt string) (string, error) {
+	reqBody := GeminiRequest{
+		SystemInstruction: geminiSystemInstruction(cfg),
+		Contents: []GeminiContent{
+			{
+				Parts: []GeminiPart{
+					{Text: prompt},
+				},
+			},
+		},
+		GenerationConfig: geminiGenerationConfig(cfg),
+	}
+
+	body, err := withGeminiOptions(cfg, reqBody)
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1/models/%s:generateContent?key=%s", cfg.Model, cfg.APIKey)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	defer closeOnCancel(ctx, resp.Body)()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	var geminiResp GeminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
+		return "", err
+	}
+
+	if len(geminiResp.Candidates) > 0 && len(geminiResp.Candidates[0].Content.Parts) > 0 {
+		return geminiResp.Candidates[0].Content.Parts[0].Text, nil
+	}
+
+	return "", fmt.Errorf("no response from Gemini")
+}
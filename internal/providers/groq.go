@@ -0,0 +1,23 @@
+package providers
+
+import "context"
+
+func init() {
+	Register("groq", groq{})
+}
+
+type groq struct{}
+
+func (groq) APIKeyEnv() string { return "GROQ_API_KEY" }
+
+func (groq) SupportsStructuredAnswers(ModelConfig) bool { return true }
+
+// Stream calls Groq's OpenAI-compatible endpoint. Its short rate limits come
+// with a "try again in" hint that is picked up as the retry delay.
+func (groq) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	return streamChatCompletions(ctx, cfg, prompt, onToken, chatCompletionsRequest{
+		URL:      "https://api.groq.com/openai/v1/chat/completions",
+		Headers:  bearer(cfg.APIKey),
+		JSONMode: true,
+	})
+}
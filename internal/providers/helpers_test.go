@@ -0,0 +1,48 @@
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// redirect sends every provider request made through the shared client to
+// server instead of the provider's real host, for the rest of the test
+func redirect(t *testing.T, server *httptest.Server) {
+	t.Helper()
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := httpClient.Transport
+	httpClient.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme, req.URL.Host = target.Scheme, target.Host
+		return saved.RoundTrip(req)
+	})
+	t.Cleanup(func() { httpClient.Transport = saved })
+}
+
+// testConfig is a model config every registered provider accepts, pointed
+// at endpoint where a provider needs one
+func testConfig(provider, endpoint string) ModelConfig {
+	cfg := ModelConfig{Name: "test", Provider: provider, Model: "test-model", APIKey: "sk-test-key-1234"}
+	switch provider {
+	case "llamacpp", "openai-compatible":
+		cfg.Endpoint = endpoint
+	case "cloudflare":
+		cfg.AccountID = "account"
+	case "vertex":
+		cfg.Project, cfg.Region = "project", "us-central1"
+	case "replicate":
+		cfg.Model = "owner/model"
+	case "mock":
+		cfg.Model = mockSlow
+	}
+	return cfg
+}
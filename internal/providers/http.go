@@ -0,0 +1,246 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrResponseTruncated is returned alongside the partial response when the
+// model stopped because it hit its token limit
+var ErrResponseTruncated = errors.New("response truncated at the token limit")
+
+// ErrContentFiltered is returned alongside the partial response when the
+// provider's content filter stopped the model
+var ErrContentFiltered = errors.New("response stopped by the provider's content filter")
+
+// ErrStreamIncomplete means the stream ended without the provider saying the
+// response was finished
+var ErrStreamIncomplete = errors.New("stream ended before the response was finished")
+
+// ErrStreamStalled means the provider stopped sending anything partway
+// through a stream, see ModelConfig.IdleTimeoutSeconds
+var ErrStreamStalled = errors.New("stream stalled, nothing received from the provider")
+
+// How a provider call ended, see OutcomeOf
+const (
+	OutcomeCompleted = "completed"
+	OutcomeTruncated = "truncated"
+	OutcomeFiltered  = "filtered"
+	OutcomeErrored   = "errored"
+)
+
+// OutcomeOf classifies the error a provider's Stream returned
+func OutcomeOf(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeCompleted
+	case errors.Is(err, ErrResponseTruncated):
+		return OutcomeTruncated
+	case errors.Is(err, ErrContentFiltered):
+		return OutcomeFiltered
+	}
+	return OutcomeErrored
+}
+
+// finishError maps a provider's finish or stop reason to the error Stream
+// returns with the response, nil for a normal finish
+func finishError(reason string) error {
+	switch reason {
+	case "length", "max_tokens":
+		return ErrResponseTruncated
+	case "content_filter", "refusal":
+		return ErrContentFiltered
+	}
+	return nil
+}
+
+// closeOnCancel closes a provider response body as soon as ctx is done, so a
+// read blocked on a stalled provider returns at once instead of whenever the
+// transport notices. The returned stop func should be deferred.
+func closeOnCancel(ctx context.Context, body io.Closer) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		body.Close()
+	})
+}
+
+// watchStream is closeOnCancel for a streamed response, which also gives up
+// on a provider that goes the model's idle timeout without sending anything.
+// It swaps resp.Body for one that restarts the idle timer on every read; the
+// returned ctx is cancelled with ErrStreamStalled as its cause once the
+// stream stalls. The returned stop func should be deferred.
+func watchStream(ctx context.Context, cfg ModelConfig, resp *http.Response) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	stopClosing := closeOnCancel(ctx, resp.Body)
+
+	idle := cfg.idleTimeout()
+	if idle <= 0 {
+		return ctx, func() {
+			stopClosing()
+			cancel(nil)
+		}
+	}
+
+	timer := time.AfterFunc(idle, func() {
+		cancel(ErrStreamStalled)
+	})
+	resp.Body = &idleReader{ReadCloser: resp.Body, timer: timer, idle: idle}
+	return ctx, func() {
+		timer.Stop()
+		stopClosing()
+		cancel(nil)
+	}
+}
+
+// idleReader restarts its timer whenever data arrives
+type idleReader struct {
+	io.ReadCloser
+	timer *time.Timer
+	idle  time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.idle)
+	}
+	return n, err
+}
+
+// maxStreamLine is the longest line a stream may send. Some events, such as
+// Anthropic's message_start or a large OpenAI chunk, go past bufio's 64KB
+// default, which would otherwise end the stream early.
+const maxStreamLine = 1 << 20
+
+// newStreamScanner reads a streamed response line by line
+func newStreamScanner(body io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	return scanner
+}
+
+// streamResult reports a cancelled or timed-out stream as an error rather
+// than letting a partial response through as a finished guess
+func streamResult(ctx context.Context, response string, err error) (string, error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, ErrStreamStalled) {
+			return response, cause
+		}
+		return response, ctxErr
+	}
+	if errors.Is(err, bufio.ErrTooLong) {
+		err = fmt.Errorf("stream line longer than %d bytes: %w", maxStreamLine, err)
+	}
+	return response, err
+}
+
+// ProviderError is a non-2xx response from a provider
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Code       string // The provider's error code or type, e.g. "invalid_api_key", if it sent one
+	Message    string
+	RetryAfter time.Duration // From the Retry-After header or the error message, zero if absent
+}
+
+// Error reads like "openai: 401 invalid_api_key: Incorrect API key provided"
+func (e *ProviderError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s: %d %s: %s", e.Provider, e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s: %d %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// StreamError is an error event sent in the middle of a stream, after the
+// provider had already answered 200
+type StreamError struct {
+	Provider string
+	Code     string // e.g. "overloaded_error", if the provider sent one
+	Message  string
+}
+
+// Error reads like "anthropic: stream error overloaded_error: Overloaded"
+func (e *StreamError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%s: stream error %s: %s", e.Provider, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s: stream error: %s", e.Provider, e.Message)
+}
+
+// maxErrorMessage keeps an HTML error page from a proxy from flooding the logs
+const maxErrorMessage = 300
+
+var retryInPattern = regexp.MustCompile(`(?i)try again in ([0-9.]+m?s)`)
+
+// httpError turns a non-2xx provider response into a readable error,
+// pulling the message out of the JSON error shapes providers commonly use
+func httpError(cfg ModelConfig, resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	var body struct {
+		Message string          `json:"message"`
+		Detail  string          `json:"detail"`
+		Error   json.RawMessage `json:"error"`
+		Errors  []struct {
+			Message string `json:"message"`
+		} `json:"errors"` // Cloudflare's envelope
+	}
+	message := strings.TrimSpace(string(data))
+	code := ""
+	if json.Unmarshal(data, &body) == nil {
+		// OpenAI sends code and type, Anthropic type, Gemini status; a
+		// numeric code just repeats the HTTP status
+		var nested struct {
+			Message string      `json:"message"`
+			Code    interface{} `json:"code"`
+			Type    string      `json:"type"`
+			Status  string      `json:"status"`
+		}
+		var flat string
+		switch {
+		case json.Unmarshal(body.Error, &nested) == nil && nested.Message != "":
+			message = nested.Message
+			code, _ = nested.Code.(string)
+			if code == "" {
+				code = nested.Status
+			}
+			if code == "" {
+				code = nested.Type
+			}
+		case json.Unmarshal(body.Error, &flat) == nil && flat != "":
+			message = flat
+		case body.Message != "":
+			message = body.Message
+		case body.Detail != "":
+			message = body.Detail
+		case len(body.Errors) > 0 && body.Errors[0].Message != "":
+			message = body.Errors[0].Message
+		}
+	}
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	// Some providers quote a rejected key back
+	if resp.Request != nil {
+		message = redactRequest(resp.Request).scrub(message)
+	}
+	if len(message) > maxErrorMessage {
+		message = message[:maxErrorMessage] + "..."
+	}
+
+	err := &ProviderError{Provider: cfg.Provider, StatusCode: resp.StatusCode, Code: code, Message: message}
+	if seconds, parseErr := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64); parseErr == nil {
+		err.RetryAfter = time.Duration(seconds * float64(time.Second))
+	} else if match := retryInPattern.FindStringSubmatch(message); match != nil {
+		err.RetryAfter, _ = time.ParseDuration(match[1])
+	}
+	return err
+}
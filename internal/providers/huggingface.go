@@ -0,0 +1,189 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	Register("huggingface", huggingFace{})
+}
+
+// HuggingFace structures
+type HuggingFaceRequest struct {
+	Inputs     string                `json:"inputs"`
+	Parameters HuggingFaceParameters `json:"parameters"`
+	Options    HuggingFaceOptions    `json:"options"`
+}
+
+type HuggingFaceParameters struct {
+	MaxNewTokens int     `json:"max_new_tokens"`
+	Temperature  float64 `json:"temperature"`
+}
+
+type HuggingFaceOptions struct {
+	UseCache     bool `json:"use_cache"`
+	WaitForModel bool `json:"wait_for_model"`
+}
+
+type HuggingFaceResponse struct {
+	GeneratedText string `json:"generated_text"`
+}
+
+// Text Generation Inference stream events
+type TGIStreamResponse struct {
+	Token struct {
+		Text    string `json:"text"`
+		Special bool   `json:"special"`
+	} `json:"token"`
+	GeneratedText *string `json:"generated_text"` // Set on the final event only
+}
+
+type huggingFace struct{}
+
+func (huggingFace) APIKeyEnv() string { return "HUGGINGFACE_API_KEY" }
+
+func (huggingFace) Validate(cfg ModelConfig) error {
+	if cfg.Streaming && cfg.Endpoint == "" {
+		return errors.New("streaming HuggingFace models require the TGI server endpoint")
+	}
+	return nil
+}
+
+// Stream calls the router's OpenAI-compatible chat completions API. A TGI
+// server is streamed from directly, and an explicitly configured endpoint is
+// assumed to be a legacy text-generation endpoint that answers all at once.
+func (p huggingFace) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	switch {
+	case cfg.Streaming:
+		return p.streamTGI(ctx, cfg, prompt, onToken)
+	case cfg.Endpoint != "":
+		return p.generateLegacy(ctx, cfg, prompt)
+	}
+	return streamChatCompletions(ctx, cfg, prompt, onToken, chatCompletionsRequest{
+		URL:     "https://router.huggingface.co/v1/chat/completions",
+		Headers: bearer(cfg.APIKey),
+	})
+}
+
+// generateLegacy calls a classic text-generation endpoint, which returns the
+// whole response at once
+func (huggingFace) generateLegacy(ctx context.Context, cfg ModelConfig, prompt string) (string, error) {
+	prompt = cfg.withSystemPrompt(prompt)
+	reqBody := HuggingFaceRequest{
+		Inputs: prompt,
+		Parameters: HuggingFaceParameters{
+			MaxNewTokens: cfg.maxTokensOr(100),
+			Temperature:  cfg.temperatureOr(0.7),
+		},
+		Options: HuggingFaceOptions{
+			UseCache:     false,
+			WaitForModel: true,
+		},
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req, err := http.NewRequestWithContext(ctx, "POST", cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	defer closeOnCancel(ctx, resp.Body)()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	var hfResp []HuggingFaceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&hfResp); err != nil {
+		return "", err
+	}
+
+	if len(hfResp) > 0 {
+		content := hfResp[0].GeneratedText
+
+		// Remove the prompt from the response if it's included
+		content = strings.TrimPrefix(content, prompt)
+		content = strings.TrimSpace(content)
+
+		return content, nil
+	}
+
+	return "", fmt.Errorf("no response from HuggingFace")
+}
+
+// streamTGI streams from a Text Generation Inference server's
+// /generate_stream endpoint. The final event's generated_text excludes
+// special tokens, so it is preferred over the concatenated tokens for
+// checking.
+func (huggingFace) streamTGI(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	reqBody := HuggingFaceRequest{
+		Inputs: cfg.withSystemPrompt(prompt),
+		Parameters: HuggingFaceParameters{
+			MaxNewTokens: cfg.maxTokensOr(100),
+			Temperature:  cfg.temperatureOr(0.7),
+		},
+	}
+
+	body, _ := json.Marshal(reqBody)
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(cfg.Endpoint, "/")+"/generate_stream", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	ctx, stopWatching := watchStream(ctx, cfg, resp)
+	defer stopWatching()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	var fullResponse strings.Builder
+	scanner := newStreamScanner(resp.Body)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		var event TGIStreamResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			continue
+		}
+
+		if !event.Token.Special {
+			fullResponse.WriteString(event.Token.Text)
+			onToken(event.Token.Text)
+		}
+
+		if event.GeneratedText != nil {
+			return streamResult(ctx, *event.GeneratedText, nil)
+		}
+	}
+
+	return streamResult(ctx, fullResponse.String(), scanner.Err())
+}
@@ -0,0 +1,115 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("llamacpp", llamaCpp{})
+}
+
+// llama.cpp server structures
+type LlamaCppRequest struct {
+	Prompt      string   `json:"prompt"`
+	NPredict    int      `json:"n_predict"`
+	Stream      bool     `json:"stream"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type LlamaCppStreamResponse struct {
+	Content string `json:"content"`
+	Stop    bool   `json:"stop"`
+}
+
+const (
+	llamaCppLoadRetries    = 3
+	llamaCppLoadRetryDelay = 2 * time.Second
+)
+
+type llamaCpp struct{}
+
+func (llamaCpp) Validate(cfg ModelConfig) error {
+	if cfg.Endpoint == "" {
+		return fmt.Errorf("the %s provider requires an endpoint", cfg.Provider)
+	}
+	return nil
+}
+
+// Stream calls llama-server's native /completion endpoint. The server answers
+// 503 while the model is still loading, so that is retried a few times within
+// the round's context.
+func (llamaCpp) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	body, err := withOptions(cfg, LlamaCppRequest{
+		Prompt:      cfg.withSystemPrompt(prompt),
+		NPredict:    cfg.maxTokensOr(64),
+		Stream:      true,
+		Temperature: cfg.Temperature,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var resp *http.Response
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimRight(cfg.Endpoint, "/")+"/completion", bytes.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if cfg.APIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+		}
+
+		resp, err = clientFor(cfg).Do(req)
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode != http.StatusServiceUnavailable || attempt == llamaCppLoadRetries {
+			break
+		}
+		resp.Body.Close()
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(llamaCppLoadRetryDelay):
+		}
+	}
+	defer resp.Body.Close()
+	ctx, stopWatching := watchStream(ctx, cfg, resp)
+	defer stopWatching()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	var fullResponse strings.Builder
+	scanner := newStreamScanner(resp.Body)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var streamResp LlamaCppStreamResponse
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &streamResp); err != nil {
+			continue
+		}
+
+		fullResponse.WriteString(streamResp.Content)
+		onToken(streamResp.Content)
+
+		if streamResp.Stop {
+			break
+		}
+	}
+
+	return streamResult(ctx, fullResponse.String(), scanner.Err())
+}
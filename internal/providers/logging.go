@@ -0,0 +1,194 @@
+package providers
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// maxLoggedBody caps how much of a request body, or a failed response's
+// body, is logged
+const maxLoggedBody = 2048
+
+var requestLogging atomic.Bool
+
+// SetRequestLogging turns debug logging of every provider request on or off
+func SetRequestLogging(on bool) {
+	requestLogging.Store(on)
+}
+
+// Every place a credential can travel in a provider request. All logging of
+// requests goes through redactRequest, so a new provider that authenticates
+// one of these ways can't leak its key into the logs.
+var (
+	secretHeaders = map[string]bool{
+		"Authorization":       true,
+		"Proxy-Authorization": true,
+		"X-Api-Key":           true,
+		"X-Goog-Api-Key":      true,
+		"Api-Key":             true,
+	}
+	secretParams = map[string]bool{
+		"key": true, "api_key": true, "apikey": true, "token": true, "access_token": true,
+		"assertion": true, "client_secret": true, "refresh_token": true,
+	}
+)
+
+// redact masks a secret down to its last 4 characters, keeping an auth scheme
+// such as "Bearer" readable
+func redact(secret string) string {
+	scheme := ""
+	if i := strings.IndexByte(secret, ' '); i != -1 {
+		scheme, secret = secret[:i+1], secret[i+1:]
+	}
+	if len(secret) <= 8 {
+		return scheme + "****"
+	}
+	return scheme + "****" + secret[len(secret)-4:]
+}
+
+// redactedRequest is a request as it may be logged, along with the secrets
+// it carried so they can be scrubbed from anything else logged with it
+type redactedRequest struct {
+	URL     string
+	Headers string
+	secrets []string
+}
+
+func redactRequest(req *http.Request) redactedRequest {
+	var r redactedRequest
+
+	u := *req.URL
+	query := u.Query()
+	for name, values := range query {
+		if secretParams[strings.ToLower(name)] {
+			for i, value := range values {
+				r.secrets = append(r.secrets, value)
+				values[i] = redact(value)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+	if password, ok := u.User.Password(); ok {
+		r.secrets = append(r.secrets, password)
+	}
+	r.URL = strings.ReplaceAll(u.Redacted(), "%2A", "*")
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var headers []string
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			if secretHeaders[http.CanonicalHeaderKey(name)] {
+				r.secrets = append(r.secrets, value)
+				if i := strings.IndexByte(value, ' '); i != -1 {
+					r.secrets = append(r.secrets, value[i+1:])
+				}
+				value = redact(value)
+			}
+			headers = append(headers, name+": "+value)
+		}
+	}
+	r.Headers = strings.Join(headers, "; ")
+	return r
+}
+
+// scrub masks the request's secrets wherever they turn up in text, such as a
+// form body or an error message that echoes the key back
+func (r redactedRequest) scrub(text string) string {
+	for _, secret := range r.secrets {
+		if len(secret) >= 4 {
+			text = strings.ReplaceAll(text, secret, redact(secret))
+		}
+	}
+	return text
+}
+
+// scrubForm masks secret fields of a form-encoded body, which may hold
+// credentials of its own rather than the request's headers
+func scrubForm(body string) string {
+	form, err := url.ParseQuery(body)
+	if err != nil {
+		return body
+	}
+	for name, values := range form {
+		if secretParams[strings.ToLower(name)] {
+			for i, value := range values {
+				values[i] = redact(value)
+			}
+		}
+	}
+	return form.Encode()
+}
+
+func truncateBody(body []byte) string {
+	if len(body) > maxLoggedBody {
+		return string(body[:maxLoggedBody]) + "..."
+	}
+	return string(body)
+}
+
+// loggingTransport logs requests and their outcome while request logging is
+// on, and otherwise stays out of the way
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+func (t loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if intercept := callFrom(req.Context()).Intercept; intercept != nil {
+		return intercept(req, t.roundTrip)
+	}
+	return t.roundTrip(req)
+}
+
+func (t loggingTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if !requestLogging.Load() {
+		return t.base.RoundTrip(req)
+	}
+
+	redacted := redactRequest(req)
+	body := ""
+	if req.GetBody != nil {
+		if copied, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(copied, maxLoggedBody+1))
+			copied.Close()
+			body = truncateBody(data)
+			if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+				body = scrubForm(body)
+			}
+		}
+	}
+	log.Printf("Provider request: %s %s [%s] %s\n", req.Method, redacted.URL, redacted.Headers, redacted.scrub(body))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		log.Printf("Provider request to %s failed after %v: %s\n", redacted.URL, elapsed, redacted.scrub(err.Error()))
+		return resp, err
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		log.Printf("Provider response from %s: %s after %v\n", redacted.URL, resp.Status, elapsed)
+		return resp, nil
+	}
+
+	// The caller still reads the whole body, so the logged part is put back
+	// in front of the rest
+	peeked, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedBody+1))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), resp.Body), resp.Body}
+	log.Printf("Provider response from %s: %s after %v: %s\n", redacted.URL, resp.Status, elapsed, redacted.scrub(truncateBody(peeked)))
+	return resp, nil
+}
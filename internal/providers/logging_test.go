@@ -0,0 +1,91 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoServer rejects every request with an error that quotes back whatever
+// credentials it was sent, the way some providers do with a bad key
+func echoServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var sent []string
+		for _, name := range []string{"Authorization", "X-Api-Key", "X-Goog-Api-Key", "Api-Key"} {
+			if value := r.Header.Get(name); value != "" {
+				sent = append(sent, value)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintf(w, `{"error": {"message": "Invalid credentials %s for %s with %s", "type": "invalid_api_key"}}`,
+			strings.Join(sent, ", "), r.URL.String(), bytes.ReplaceAll(body, []byte(`"`), []byte(`'`)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestKeysNeverLogged makes a failing request through every provider with
+// request logging on, against a server that echoes the key back, and checks
+// the key appears in neither the logs nor the error the caller gets
+func TestKeysNeverLogged(t *testing.T) {
+	keyNeverLeaks(t, echoServer(t))
+}
+
+// TestKeysNeverLoggedUnreachable does the same against a server that is
+// gone, since a request that never arrives fails quoting its URL, which for
+// Gemini holds the key
+func TestKeysNeverLoggedUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	keyNeverLeaks(t, server)
+}
+
+func keyNeverLeaks(t *testing.T, server *httptest.Server) {
+	redirect(t, server)
+
+	const key = "sk-live-0123456789abcdef"
+	vertexTokens.mu.Lock()
+	vertexTokens.token, vertexTokens.expires = key, time.Now().Add(time.Hour)
+	vertexTokens.mu.Unlock()
+
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	SetRequestLogging(true)
+	t.Cleanup(func() { SetRequestLogging(false) })
+
+	for _, name := range Names() {
+		if name == "mock" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			logged.Reset()
+			provider, _ := Lookup(name)
+			cfg := testConfig(name, server.URL)
+			cfg.APIKey = key
+			_, err := provider.Stream(context.Background(), cfg, "What has keys but can't open locks?", func(string) {})
+			if err == nil {
+				t.Fatal("request succeeded")
+			}
+			if !strings.Contains(logged.String(), "Provider request") {
+				t.Fatalf("request not logged: %q", logged.String())
+			}
+			if strings.Contains(logged.String(), key) {
+				t.Errorf("key in the logs:\n%s", logged.String())
+			}
+			if strings.Contains(err.Error(), key) {
+				t.Errorf("key in the error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package providers
+
+import "context"
+
+func init() {
+	Register("mistral", mistral{})
+}
+
+type mistral struct{}
+
+func (mistral) APIKeyEnv() string { return "MISTRAL_API_KEY" }
+
+func (mistral) SupportsStructuredAnswers(ModelConfig) bool { return true }
+
+func (mistral) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	return streamChatCompletions(ctx, cfg, prompt, onToken, chatCompletionsRequest{
+		URL:      "https://api.mistral.ai/v1/chat/completions",
+		Headers:  bearer(cfg.APIKey),
+		JSONMode: true,
+	})
+}
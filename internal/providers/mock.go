@@ -0,0 +1,88 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("mock", mock{})
+}
+
+// Mock behaviors, selected with the model's "model" field
+const (
+	mockAlwaysCorrect = "always-correct"
+	mockAlwaysWrong   = "always-wrong"
+	mockSlow          = "slow"
+	mockError         = "error"
+)
+
+const (
+	mockTokenDelay   = 20 * time.Millisecond
+	defaultMockDelay = 5 * time.Second
+)
+
+// mockWrongAnswers are the always-wrong guesses, tried in order until one
+// isn't the answer
+var mockWrongAnswers = []string{"a teapot", "the moon", "a penguin"}
+
+// mock plays without a network so the whole game can run offline. It answers
+// with the riddle's answer from the Call, or a canned wrong one, streamed a
+// character at a time.
+type mock struct{}
+
+func (mock) Offline() bool { return true }
+
+func (mock) Validate(cfg ModelConfig) error {
+	switch cfg.Model {
+	case mockAlwaysCorrect, mockAlwaysWrong, mockSlow, mockError:
+		return nil
+	}
+	return fmt.Errorf("mock model must be %q, %q, %q or %q", mockAlwaysCorrect, mockAlwaysWrong, mockSlow, mockError)
+}
+
+func (mock) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	answer := callFrom(ctx).Answer
+
+	switch cfg.Model {
+	case mockError:
+		return "", &ProviderError{Provider: cfg.Provider, StatusCode: http.StatusServiceUnavailable, Code: "mock_error", Message: "the mock provider always fails"}
+	case mockAlwaysWrong:
+		answer = mockWrongAnswers[0]
+		for _, wrong := range mockWrongAnswers {
+			if !strings.EqualFold(wrong, callFrom(ctx).Answer) {
+				answer = wrong
+				break
+			}
+		}
+	case mockSlow:
+		// Slow to start, as a cold or overloaded model would be
+		delay := defaultMockDelay
+		if ms, ok := cfg.Options["delayMs"].(float64); ok {
+			delay = time.Duration(ms) * time.Millisecond
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	if answer == "" {
+		answer = "I don't know"
+	}
+
+	var fullResponse strings.Builder
+	for _, char := range answer {
+		select {
+		case <-ctx.Done():
+			return streamResult(ctx, fullResponse.String(), nil)
+		case <-time.After(mockTokenDelay):
+		}
+		fullResponse.WriteRune(char)
+		onToken(string(char))
+	}
+	return fullResponse.String(), nil
+}
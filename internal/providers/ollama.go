@@ -0,0 +1,247 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	Register("ollama", ollama{})
+}
+
+// Ollama structures
+type OllamaRequest struct {
+	Model     string         `json:"model"`
+	Prompt    string         `json:"prompt"`
+	Stream    bool           `json:"stream"`
+	KeepAlive string         `json:"keep_alive,omitempty"`
+	Format    string         `json:"format,omitempty"` // "json" for structured answers
+	Options   *OllamaOptions `json:"options,omitempty"`
+}
+
+type OllamaStreamResponse struct {
+	Response string `json:"response"`
+	Done     bool   `json:"done"`
+	OllamaEvalCounts
+}
+
+// OllamaEvalCounts are the token counts on the final chunk of a response
+type OllamaEvalCounts struct {
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (c OllamaEvalCounts) usage() Usage {
+	return Usage{PromptTokens: c.PromptEvalCount, CompletionTokens: c.EvalCount}
+}
+
+type OllamaChatRequest struct {
+	Model     string         `json:"model"`
+	Messages  []ChatMessage  `json:"messages"`
+	Stream    bool           `json:"stream"`
+	KeepAlive string         `json:"keep_alive,omitempty"`
+	Format    string         `json:"format,omitempty"`
+	Options   *OllamaOptions `json:"options,omitempty"`
+}
+
+// OllamaOptions are the model parameters the game sets itself; others can be
+// added through the model's options
+type OllamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+}
+
+func ollamaOptions(cfg ModelConfig) *OllamaOptions {
+	if cfg.Temperature == nil && cfg.MaxTokens == nil {
+		return nil
+	}
+	return &OllamaOptions{Temperature: cfg.Temperature, NumPredict: cfg.MaxTokens}
+}
+
+type OllamaChatStreamResponse struct {
+	Message ChatMessage `json:"message"`
+	Done    bool        `json:"done"`
+	OllamaEvalCounts
+}
+
+const defaultOllamaKeepAlive = "10m"
+
+func ollamaKeepAlive(cfg ModelConfig) string {
+	if cfg.KeepAlive != "" {
+		return cfg.KeepAlive
+	}
+	return defaultOllamaKeepAlive
+}
+
+func ollamaFormat(cfg ModelConfig) string {
+	if cfg.StructuredAnswers {
+		return "json"
+	}
+	return ""
+}
+
+// OllamaEndpoint is the server a model is served from, defaulting to a local
+// Ollama
+func OllamaEndpoint(cfg ModelConfig) string {
+	if cfg.Endpoint != "" {
+		return cfg.Endpoint
+	}
+	return "http://localhost:11434"
+}
+
+type ollama struct{}
+
+func (ollama) SupportsStructuredAnswers(ModelConfig) bool { return true }
+
+// Stream sends the conversation to /api/chat when the caller provides one,
+// so clues and "that was wrong" feedback arrive as proper turns, and the
+// flattened prompt to /api/generate otherwise
+func (p ollama) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	if messages := callFrom(ctx).Messages; len(messages) > 0 {
+		return p.streamChat(ctx, cfg, messages, onToken)
+	}
+
+	reqBody := OllamaRequest{
+		Model:     cfg.Model,
+		Prompt:    cfg.withSystemPrompt(prompt),
+		Stream:    true,
+		KeepAlive: ollamaKeepAlive(cfg),
+		Format:    ollamaFormat(cfg),
+		Options:   ollamaOptions(cfg),
+	}
+
+	body, err := withOllamaOptions(cfg, reqBody)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", OllamaEndpoint(cfg)+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	ctx, stopWatching := watchStream(ctx, cfg, resp)
+	defer stopWatching()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	var fullResponse strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+
+	for {
+		var streamResp OllamaStreamResponse
+		if err := decoder.Decode(&streamResp); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return streamResult(ctx, fullResponse.String(), err)
+		}
+
+		fullResponse.WriteString(streamResp.Response)
+		onToken(streamResp.Response)
+
+		if streamResp.Done {
+			reportUsage(ctx, streamResp.usage())
+			break
+		}
+	}
+
+	return streamResult(ctx, fullResponse.String(), nil)
+}
+
+func (ollama) streamChat(ctx context.Context, cfg ModelConfig, messages []ChatMessage, onToken func(string)) (string, error) {
+	if cfg.SystemPrompt != "" {
+		messages = append([]ChatMessage{{Role: "system", Content: cfg.SystemPrompt}}, messages...)
+	}
+	reqBody := OllamaChatRequest{
+		Model:     cfg.Model,
+		Messages:  messages,
+		Stream:    true,
+		KeepAlive: ollamaKeepAlive(cfg),
+		Format:    ollamaFormat(cfg),
+		Options:   ollamaOptions(cfg),
+	}
+
+	body, err := withOllamaOptions(cfg, reqBody)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", OllamaEndpoint(cfg)+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	ctx, stopWatching := watchStream(ctx, cfg, resp)
+	defer stopWatching()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	var fullResponse strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+
+	for {
+		var streamResp OllamaChatStreamResponse
+		if err := decoder.Decode(&streamResp); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return streamResult(ctx, fullResponse.String(), err)
+		}
+
+		fullResponse.WriteString(streamResp.Message.Content)
+		onToken(streamResp.Message.Content)
+
+		if streamResp.Done {
+			reportUsage(ctx, streamResp.usage())
+			break
+		}
+	}
+
+	return streamResult(ctx, fullResponse.String(), nil)
+}
+
+// PreloadOllama loads a model into memory without generating anything, which
+// is what Ollama does with an empty prompt
+func PreloadOllama(ctx context.Context, cfg ModelConfig) error {
+	body, _ := json.Marshal(OllamaRequest{
+		Model:     cfg.Model,
+		KeepAlive: ollamaKeepAlive(cfg),
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", OllamaEndpoint(cfg)+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return httpError(cfg, resp)
+	}
+	return nil
+}
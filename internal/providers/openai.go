@@ -0,0 +1,166 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("openai", openAI{})
+}
+
+type openAI struct{}
+
+func (openAI) APIKeyEnv() string { return "OPENAI_API_KEY" }
+
+func (openAI) SupportsStructuredAnswers(ModelConfig) bool { return true }
+
+// Stream streams a chat completion from OpenAI. Reasoning models are called
+// without streaming instead, see generateReasoning.
+func (p openAI) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	if IsOpenAIReasoningModel(cfg) {
+		return p.generateReasoning(ctx, cfg, prompt)
+	}
+	return streamChatCompletions(ctx, cfg, prompt, onToken, chatCompletionsRequest{
+		URL:          openAIURL(cfg),
+		Headers:      bearer(cfg.APIKey),
+		IncludeUsage: true,
+		JSONMode:     true,
+	})
+}
+
+// openAIURL is the chat-completions endpoint; Endpoint lets an openai model
+// go through a proxy
+func openAIURL(cfg ModelConfig) string {
+	if cfg.Endpoint != "" {
+		return chatCompletionsURL(cfg.Endpoint)
+	}
+	return "https://api.openai.com/v1/chat/completions"
+}
+
+// OpenAI reasoning (o-series) structures
+// Reasoning models take no temperature, and their token limit covers the
+// hidden reasoning as well as the answer
+type OpenAIReasoningRequest struct {
+	Model               string                `json:"model"`
+	Messages            []OpenAIMessage       `json:"messages"`
+	ReasoningEffort     string                `json:"reasoning_effort,omitempty"`
+	MaxCompletionTokens *int                  `json:"max_completion_tokens,omitempty"`
+	ResponseFormat      *OpenAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type OpenAICompletionResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *OpenAIUsage `json:"usage"`
+}
+
+// reasoningMessages are systemMessages for a reasoning model, which takes
+// standing instructions as a developer message rather than a system one
+func reasoningMessages(cfg ModelConfig, prompt string) []OpenAIMessage {
+	messages := cfg.systemMessages(prompt)
+	if len(messages) > 1 {
+		messages[0].Role = "developer"
+	}
+	return messages
+}
+
+const reasoningHeartbeatInterval = 5 * time.Second
+
+// IsOpenAIReasoningModel reports whether an openai model is an o-series
+// reasoning model, either configured as one or recognized by name
+func IsOpenAIReasoningModel(cfg ModelConfig) bool {
+	if cfg.Provider != "openai" {
+		return false
+	}
+	if cfg.Reasoning {
+		return true
+	}
+	for _, prefix := range []string{"o1", "o3", "o4"} {
+		if strings.HasPrefix(cfg.Model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// generateReasoning makes a non-streaming call to a reasoning model. Nothing
+// visible arrives while the model thinks, so "thinking" status heartbeats
+// keep the client from looking frozen. Only the final answer is returned.
+func (openAI) generateReasoning(ctx context.Context, cfg ModelConfig, prompt string) (string, error) {
+	reqBody := OpenAIReasoningRequest{
+		Model:               cfg.Model,
+		Messages:            reasoningMessages(cfg, prompt),
+		ReasoningEffort:     cfg.ReasoningEffort,
+		MaxCompletionTokens: cfg.MaxTokens,
+		ResponseFormat:      jsonResponseFormat(cfg),
+	}
+
+	// This call doesn't stream, whatever the options say
+	options := make(map[string]interface{}, len(cfg.Options))
+	for key, value := range cfg.Options {
+		if key != "stream" {
+			options[key] = value
+		}
+	}
+	cfg.Options = options
+
+	body, err := withOptions(cfg, reqBody)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", openAIURL(cfg), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+
+	stopHeartbeat := make(chan struct{})
+	defer close(stopHeartbeat)
+	go func() {
+		ticker := time.NewTicker(reasoningHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopHeartbeat:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				status(ctx, "thinking")
+			}
+		}
+	}()
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	var completion OpenAICompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return streamResult(ctx, "", err)
+	}
+	if completion.Usage != nil {
+		reportUsage(ctx, completion.Usage.usage())
+	}
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("no response from %s", cfg.Model)
+	}
+	return completion.Choices[0].Message.Content, finishError(completion.Choices[0].FinishReason)
+}
@@ -0,0 +1,32 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+)
+
+func init() {
+	Register("openai-compatible", openAICompatible{})
+}
+
+// openAICompatible serves vLLM, LM Studio, llamafile, LiteLLM and other
+// servers that speak the OpenAI protocol at cfg.Endpoint. The API key is
+// optional since many local servers don't check one.
+type openAICompatible struct{}
+
+func (openAICompatible) APIKeyEnv() string { return "OPENAI_COMPATIBLE_API_KEY" }
+
+func (openAICompatible) Validate(cfg ModelConfig) error {
+	if cfg.Endpoint == "" {
+		return fmt.Errorf("the %s provider requires an endpoint", cfg.Provider)
+	}
+	return nil
+}
+
+func (openAICompatible) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	call := chatCompletionsRequest{URL: chatCompletionsURL(cfg.Endpoint)}
+	if cfg.APIKey != "" {
+		call.Headers = bearer(cfg.APIKey)
+	}
+	return streamChatCompletions(ctx, cfg, prompt, onToken, call)
+}
@@ -0,0 +1,26 @@
+package providers
+
+import "context"
+
+func init() {
+	Register("openrouter", openRouter{})
+}
+
+type openRouter struct{}
+
+func (openRouter) APIKeyEnv() string { return "OPENROUTER_API_KEY" }
+
+// Stream calls OpenRouter, which routes to many upstream models. cfg.Model is
+// passed through as-is, e.g. "anthropic/claude-3.5-sonnet". Its
+// ": OPENROUTER PROCESSING" keep-alive comments are skipped like any other
+// non-data SSE line.
+func (openRouter) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	return streamChatCompletions(ctx, cfg, prompt, onToken, chatCompletionsRequest{
+		URL: "https://openrouter.ai/api/v1/chat/completions",
+		Headers: map[string]string{
+			"Authorization": "Bearer " + cfg.APIKey,
+			"HTTP-Referer":  "https://github.com/tahcohcat/turingroulette",
+			"X-Title":       "Turing Roulette",
+		},
+	})
+}
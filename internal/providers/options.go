@@ -0,0 +1,130 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// reservedOptions are request fields the game sets itself. Overriding them
+// would replace the riddle or the model, so they can't be passed as options.
+var reservedOptions = []string{"model", "messages", "prompt"}
+
+// validateOptions rejects options that would break the game
+func validateOptions(options map[string]interface{}) error {
+	for _, key := range reservedOptions {
+		if _, ok := options[key]; ok {
+			return fmt.Errorf("option %q is set by the game and can't be overridden", key)
+		}
+	}
+	if stream, ok := options["stream"]; ok && stream != true {
+		return fmt.Errorf("option \"stream\" must be true, guesses are streamed to players")
+	}
+	return nil
+}
+
+// withOptions marshals a request body with the model's options merged in at
+// the top level. Options replace fields of the same name and keys the
+// request type doesn't know are passed through untouched.
+func withOptions(cfg ModelConfig, reqBody interface{}) ([]byte, error) {
+	if len(cfg.Options) == 0 {
+		return json.Marshal(reqBody)
+	}
+
+	fields, err := toFields(reqBody)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range cfg.Options {
+		fields[key] = value
+	}
+	return json.Marshal(fields)
+}
+
+// ollamaTopLevel are the Ollama request fields that sit beside "options";
+// every other option is a model parameter such as num_ctx or seed and goes
+// inside it
+var ollamaTopLevel = map[string]bool{
+	"format": true, "keep_alive": true, "think": true, "raw": true,
+	"template": true, "system": true, "tools": true, "stream": true,
+}
+
+// geminiTopLevel are the Gemini request fields that sit beside
+// "generationConfig", in both of the spellings Gemini accepts
+var geminiTopLevel = map[string]bool{
+	"contents": true, "tools": true, "safetySettings": true, "safety_settings": true,
+	"toolConfig": true, "tool_config": true, "cachedContent": true, "cached_content": true,
+	"systemInstruction": true, "system_instruction": true,
+}
+
+// replicateTopLevel are the Replicate prediction fields that sit beside
+// "input"
+var replicateTopLevel = map[string]bool{
+	"version": true, "stream": true, "webhook": true, "webhook_events_filter": true,
+}
+
+// withOllamaOptions is withOptions in Ollama's shape, where sampling and
+// context parameters are nested under "options"
+func withOllamaOptions(cfg ModelConfig, reqBody interface{}) ([]byte, error) {
+	return withNestedOptions(cfg, reqBody, "options", ollamaTopLevel)
+}
+
+// withGeminiOptions is withOptions in Gemini's shape, where sampling
+// parameters such as topP are nested under "generationConfig"
+func withGeminiOptions(cfg ModelConfig, reqBody interface{}) ([]byte, error) {
+	return withNestedOptions(cfg, reqBody, "generationConfig", geminiTopLevel)
+}
+
+// withReplicateOptions is withOptions in Replicate's shape, where the model's
+// parameters are nested under "input"
+func withReplicateOptions(cfg ModelConfig, reqBody interface{}) ([]byte, error) {
+	return withNestedOptions(cfg, reqBody, "input", replicateTopLevel)
+}
+
+// withNestedOptions merges options into the object under nested, except for
+// the topLevel fields. An option named nested is merged into it as well.
+func withNestedOptions(cfg ModelConfig, reqBody interface{}, nested string, topLevel map[string]bool) ([]byte, error) {
+	if len(cfg.Options) == 0 {
+		return json.Marshal(reqBody)
+	}
+
+	fields, err := toFields(reqBody)
+	if err != nil {
+		return nil, err
+	}
+	params, _ := fields[nested].(map[string]interface{})
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+	for key, value := range cfg.Options {
+		if key == nested {
+			// Already in the provider's shape
+			if inner, ok := value.(map[string]interface{}); ok {
+				for k, v := range inner {
+					params[k] = v
+				}
+				continue
+			}
+		}
+		if topLevel[key] {
+			fields[key] = value
+		} else {
+			params[key] = value
+		}
+	}
+	if len(params) > 0 {
+		fields[nested] = params
+	}
+	return json.Marshal(fields)
+}
+
+func toFields(reqBody interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,31 @@
+package providers
+
+import (
+	"context"
+	"regexp"
+)
+
+func init() {
+	Register("perplexity", perplexity{})
+}
+
+var citationPattern = regexp.MustCompile(`\s*\[\d+(?:\s*,\s*\d+)*\]`)
+
+type perplexity struct{}
+
+func (perplexity) APIKeyEnv() string { return "PERPLEXITY_API_KEY" }
+
+// Stream calls Perplexity's OpenAI-compatible endpoint. Sonar models put
+// [1]-style citation markers inline, which are stripped from the answer so
+// "echo[1]" is checked as "echo".
+func (perplexity) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	response, err := streamChatCompletions(ctx, cfg, prompt, onToken, chatCompletionsRequest{
+		URL:     "https://api.perplexity.ai/chat/completions",
+		Headers: bearer(cfg.APIKey),
+	})
+	return stripCitations(response), err
+}
+
+func stripCitations(text string) string {
+	return citationPattern.ReplaceAllString(text, "")
+}
@@ -0,0 +1,192 @@
+// Package providers holds the model backends the game asks for guesses. Each
+// provider lives in its own file and registers itself under the name used in
+// a model's "provider" config field.
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Provider sends a prompt to a model. Answer text is passed to onToken as it
+// streams in, and the full answer is returned once the model is done. A
+// provider that can't stream returns the answer without calling onToken.
+type Provider interface {
+	Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error)
+}
+
+// APIKeyEnver is implemented by providers whose API key can be set from an
+// environment variable, overriding the one in config.json
+type APIKeyEnver interface {
+	APIKeyEnv() string
+}
+
+// Validator is implemented by providers with config fields they can't work
+// without, so a bad config fails at startup instead of on every call
+type Validator interface {
+	Validate(cfg ModelConfig) error
+}
+
+// StructuredAnswerer is implemented by providers that can hold a model to a
+// JSON reply when its StructuredAnswers is set
+type StructuredAnswerer interface {
+	SupportsStructuredAnswers(cfg ModelConfig) bool
+}
+
+// Offliner is implemented by providers that answer without making any HTTP
+// request, so a call to them has no connection or first byte to time
+type Offliner interface {
+	Offline() bool
+}
+
+var (
+	registryMux sync.RWMutex
+	registry    = make(map[string]Provider)
+)
+
+// Register makes a provider available under name. It panics if the name is
+// already taken, since two providers silently shadowing each other would be
+// hard to spot.
+func Register(name string, provider Provider) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("providers: %q registered twice", name))
+	}
+	registry[name] = provider
+}
+
+// Lookup returns the provider registered under name
+func Lookup(name string) (Provider, bool) {
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+	provider, ok := registry[name]
+	return provider, ok
+}
+
+// Names lists the registered providers in sorted order
+func Names() []string {
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// APIKeyEnv returns the environment variable holding the named provider's
+// API key, or "" if it doesn't declare one
+func APIKeyEnv(name string) string {
+	if provider, ok := Lookup(name); ok {
+		if enver, ok := provider.(APIKeyEnver); ok {
+			return enver.APIKeyEnv()
+		}
+	}
+	return ""
+}
+
+// SupportsStructuredAnswers reports whether a model's provider can be asked
+// for its answer as JSON
+func SupportsStructuredAnswers(cfg ModelConfig) bool {
+	if provider, ok := Lookup(cfg.Provider); ok {
+		if answerer, ok := provider.(StructuredAnswerer); ok {
+			return answerer.SupportsStructuredAnswers(cfg)
+		}
+	}
+	return false
+}
+
+// IsOffline reports whether the named provider answers without making any
+// HTTP request
+func IsOffline(name string) bool {
+	if provider, ok := Lookup(name); ok {
+		if offliner, ok := provider.(Offliner); ok {
+			return offliner.Offline()
+		}
+	}
+	return false
+}
+
+// Validate checks a model config's options, proxy and its provider's
+// requirements. Unknown providers pass; they fail when the model is called.
+func Validate(cfg ModelConfig) error {
+	if err := validateOptions(cfg.Options); err != nil {
+		return err
+	}
+	if err := validateProxy(cfg.Proxy); err != nil {
+		return err
+	}
+	if cfg.StructuredAnswers && !SupportsStructuredAnswers(cfg) {
+		if _, ok := Lookup(cfg.Provider); ok {
+			return fmt.Errorf("structuredAnswers isn't supported by the %s provider", cfg.Provider)
+		}
+	}
+	if cfg.StructuredAnswers && cfg.ReasoningMode {
+		return fmt.Errorf("structuredAnswers and reasoningMode can't both be set")
+	}
+	if provider, ok := Lookup(cfg.Provider); ok {
+		if validator, ok := provider.(Validator); ok {
+			return validator.Validate(cfg)
+		}
+	}
+	return nil
+}
+
+// Call carries the optional parts of a call that not every provider uses
+type Call struct {
+	Messages   []ChatMessage       // The conversation so far ending with this round's turn; providers without chat support use the prompt
+	OnThinking func(text string)   // Reasoning text, never part of the answer
+	OnStatus   func(status string) // Progress while nothing visible is streaming, e.g. "thinking"
+	OnUsage    func(usage Usage)   // Token counts, for providers that report them
+	Answer     string              // The riddle's answer, only for the mock provider to play with
+	Intercept  Interceptor         // Stands between the provider and the network, for fault injection
+}
+
+// Interceptor sees each HTTP request a provider makes during a call. It
+// either passes the request on with send, perhaps tampering with the
+// response, or answers in the network's place.
+type Interceptor func(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error)
+
+// Usage is the token count a provider reports for one call
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+type callKey struct{}
+
+// WithCall attaches call to ctx for the provider to pick up
+func WithCall(ctx context.Context, call Call) context.Context {
+	return context.WithValue(ctx, callKey{}, call)
+}
+
+func callFrom(ctx context.Context) Call {
+	call, _ := ctx.Value(callKey{}).(Call)
+	return call
+}
+
+// thinking forwards reasoning text to the caller, if it wants it
+func thinking(ctx context.Context, text string) {
+	if call := callFrom(ctx); call.OnThinking != nil && text != "" {
+		call.OnThinking(text)
+	}
+}
+
+// status reports progress to the caller, if it wants it
+func status(ctx context.Context, text string) {
+	if call := callFrom(ctx); call.OnStatus != nil {
+		call.OnStatus(text)
+	}
+}
+
+// reportUsage passes the call's token counts to the caller, if it wants them
+func reportUsage(ctx context.Context, usage Usage) {
+	if call := callFrom(ctx); call.OnUsage != nil {
+		call.OnUsage(usage)
+	}
+}
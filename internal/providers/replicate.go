@@ -0,0 +1,173 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func init() {
+	Register("replicate", replicate{})
+}
+
+// Replicate structures
+type ReplicatePredictionRequest struct {
+	Version string                 `json:"version,omitempty"`
+	Input   map[string]interface{} `json:"input"`
+	Stream  bool                   `json:"stream"`
+}
+
+type ReplicatePrediction struct {
+	ID   string `json:"id"`
+	URLs struct {
+		Get    string `json:"get"`
+		Stream string `json:"stream"`
+		Cancel string `json:"cancel"`
+	} `json:"urls"`
+}
+
+type replicate struct{}
+
+func (replicate) APIKeyEnv() string { return "REPLICATE_API_TOKEN" }
+
+// Stream creates a prediction and follows its SSE stream. cfg.Model
+// is "owner/name" for official models or "owner/name:version" for a pinned
+// version. A prediction abandoned by cancellation or timeout is cancelled on
+// Replicate too, so it doesn't keep running and billing.
+func (replicate) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	reqBody := ReplicatePredictionRequest{
+		Input:  map[string]interface{}{"prompt": cfg.withSystemPrompt(prompt)},
+		Stream: true,
+	}
+	// Unset, the model's own defaults apply, since they vary from model to
+	// model
+	if cfg.Temperature != nil {
+		reqBody.Input["temperature"] = *cfg.Temperature
+	}
+	if cfg.MaxTokens != nil {
+		reqBody.Input["max_tokens"] = *cfg.MaxTokens
+	}
+	url := "https://api.replicate.com/v1/models/" + cfg.Model + "/predictions"
+	if _, version, ok := strings.Cut(cfg.Model, ":"); ok {
+		reqBody.Version = version
+		url = "https://api.replicate.com/v1/predictions"
+	}
+
+	body, err := withReplicateOptions(cfg, reqBody)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return "", httpError(cfg, resp)
+	}
+	var prediction ReplicatePrediction
+	err = json.NewDecoder(resp.Body).Decode(&prediction)
+	resp.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	if prediction.URLs.Stream == "" {
+		return "", fmt.Errorf("replicate prediction %s has no stream url", prediction.ID)
+	}
+
+	response, err := followReplicateStream(ctx, cfg, prediction.URLs.Stream, onToken)
+	if ctx.Err() != nil && prediction.URLs.Cancel != "" {
+		go cancelReplicatePrediction(cfg, prediction)
+	}
+	return response, err
+}
+
+// followReplicateStream passes on "output" events until "done"
+func followReplicateStream(ctx context.Context, cfg ModelConfig, streamURL string, onToken func(string)) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", streamURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-store")
+	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	ctx, stopWatching := watchStream(ctx, cfg, resp)
+	defer stopWatching()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", httpError(cfg, resp)
+	}
+
+	var fullResponse strings.Builder
+	scanner := newStreamScanner(resp.Body)
+
+	// An event's data may span several data lines and ends at a blank line
+	event := ""
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			continue
+		case strings.HasPrefix(line, "data:"):
+			value := strings.TrimPrefix(line, "data:")
+			data = append(data, strings.TrimPrefix(value, " "))
+			continue
+		case line != "":
+			continue
+		}
+
+		content := strings.Join(data, "\n")
+		data = nil
+		switch event {
+		case "output":
+			fullResponse.WriteString(content)
+			onToken(content)
+		case "error":
+			return fullResponse.String(), fmt.Errorf("replicate prediction failed: %s", content)
+		case "done":
+			return streamResult(ctx, fullResponse.String(), nil)
+		}
+	}
+
+	return streamResult(ctx, fullResponse.String(), scanner.Err())
+}
+
+// cancelReplicatePrediction stops an abandoned prediction. It gets its own
+// short deadline since the round's context is already done.
+func cancelReplicatePrediction(cfg ModelConfig, prediction ReplicatePrediction) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", prediction.URLs.Cancel, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+
+	resp, err := clientFor(cfg).Do(req)
+	if err != nil {
+		log.Printf("Error cancelling replicate prediction %s: %v\n", prediction.ID, err)
+		return
+	}
+	resp.Body.Close()
+}
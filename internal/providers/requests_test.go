@@ -0,0 +1,143 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden request bodies in testdata/requests")
+
+// requestBody makes a call through the named provider and returns the body
+// of the first request it sent, indented with sorted keys. The provider is
+// refused, so nothing past the first request is sent.
+func requestBody(t *testing.T, name string, configure func(*ModelConfig)) []byte {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		first []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		if first == nil && len(data) > 0 {
+			first = data
+		}
+		mu.Unlock()
+		http.Error(w, `{"error": "refused"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+	redirect(t, server)
+
+	vertexTokens.mu.Lock()
+	vertexTokens.token, vertexTokens.expires = "vertex-token", time.Now().Add(time.Hour)
+	vertexTokens.mu.Unlock()
+
+	provider, _ := Lookup(name)
+	cfg := testConfig(name, server.URL)
+	configure(&cfg)
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("config invalid: %v", err)
+	}
+	provider.Stream(context.Background(), cfg, "What has keys but can't open locks?", func(string) {})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if first == nil {
+		t.Fatal("no request body sent")
+	}
+	var fields interface{}
+	if err := json.Unmarshal(first, &fields); err != nil {
+		t.Fatalf("request body isn't JSON: %v\n%s", err, first)
+	}
+	indented, _ := json.MarshalIndent(fields, "", "  ")
+	return append(indented, '\n')
+}
+
+// checkGolden compares got with testdata/requests/name.json, or rewrites it
+// with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "requests", name+".json")
+	if *update {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("request body differs from %s:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// TestRequestSampling checks each provider's serialized request with no
+// temperature or token limit set, where the provider's defaults apply, and
+// with both set
+func TestRequestSampling(t *testing.T) {
+	temperature, maxTokens := 0.2, 64
+	variants := map[string]func(*ModelConfig){
+		"defaults": func(*ModelConfig) {},
+		"sampling": func(cfg *ModelConfig) { cfg.Temperature, cfg.MaxTokens = &temperature, &maxTokens },
+	}
+	for _, name := range Names() {
+		if IsOffline(name) {
+			continue
+		}
+		for variant, configure := range variants {
+			t.Run(name+"-"+variant, func(t *testing.T) {
+				checkGolden(t, name+"-"+variant, requestBody(t, name, configure))
+			})
+		}
+	}
+}
+
+// TestRequestOptions checks each provider's serialized request with options
+// merged in: known fields replaced and unknown keys passed through untouched
+func TestRequestOptions(t *testing.T) {
+	options := map[string]interface{}{
+		"top_p":       0.9,
+		"seed":        42.0,
+		"stop":        []interface{}{"\n"},
+		"custom_flag": true,
+	}
+	for _, name := range Names() {
+		if IsOffline(name) {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			checkGolden(t, name+"-options", requestBody(t, name, func(cfg *ModelConfig) { cfg.Options = options }))
+		})
+	}
+}
+
+// TestRequestOptionsShaped passes options already in a provider's nested
+// shape, alongside fields that belong at the top level
+func TestRequestOptionsShaped(t *testing.T) {
+	temperature := 0.2
+	shaped := map[string]map[string]interface{}{
+		"google":    {"generationConfig": map[string]interface{}{"topK": 40.0}, "temperature": 0.5, "safetySettings": []interface{}{map[string]interface{}{"category": "HARM_CATEGORY_HARASSMENT", "threshold": "BLOCK_NONE"}}},
+		"ollama":    {"options": map[string]interface{}{"num_ctx": 4096.0}, "temperature": 0.5, "format": "json"},
+		"replicate": {"input": map[string]interface{}{"top_k": 40.0}, "temperature": 0.5, "webhook": "https://example.com/hook"},
+	}
+	for name, options := range shaped {
+		t.Run(name, func(t *testing.T) {
+			checkGolden(t, name+"-shaped", requestBody(t, name, func(cfg *ModelConfig) {
+				cfg.Temperature, cfg.Options = &temperature, options
+			}))
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package providers
+
+import "strings"
+
+// thinkSplitter separates <think>...</think> reasoning from answer text in a
+// token stream. Tags can be split across chunks, so a possible partial tag at
+// the end of a chunk is held back until the next one arrives.
+type thinkSplitter struct {
+	inThink bool
+	pending string
+}
+
+// Split consumes a chunk and returns the answer and reasoning text it completes
+func (s *thinkSplitter) Split(chunk string) (answer string, thinking string) {
+	buf := s.pending + chunk
+	s.pending = ""
+
+	var answerOut, thinkingOut strings.Builder
+	emit := func(text string) {
+		if s.inThink {
+			thinkingOut.WriteString(text)
+		} else {
+			answerOut.WriteString(text)
+		}
+	}
+
+	for buf != "" {
+		tag := "<think>"
+		if s.inThink {
+			tag = "</think>"
+		}
+
+		if i := strings.Index(buf, tag); i >= 0 {
+			emit(buf[:i])
+			buf = buf[i+len(tag):]
+			s.inThink = !s.inThink
+			continue
+		}
+
+		// Hold back the longest suffix that could be the start of the tag
+		hold := 0
+		for n := len(tag) - 1; n > 0; n-- {
+			if strings.HasSuffix(buf, tag[:n]) {
+				hold = n
+				break
+			}
+		}
+		emit(buf[:len(buf)-hold])
+		s.pending = buf[len(buf)-hold:]
+		break
+	}
+	return answerOut.String(), thinkingOut.String()
+}
+
+// Flush returns whatever was held back once the stream has ended
+func (s *thinkSplitter) Flush() (answer string, thinking string) {
+	pending := s.pending
+	s.pending = ""
+	if s.inThink {
+		return "", pending
+	}
+	return pending, ""
+}
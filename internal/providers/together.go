@@ -0,0 +1,20 @@
+package providers
+
+import "context"
+
+func init() {
+	Register("together", together{})
+}
+
+type together struct{}
+
+func (together) APIKeyEnv() string { return "TOGETHER_API_KEY" }
+
+// Stream calls Together AI's OpenAI-compatible endpoint for hosted
+// open-weight models
+func (together) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	return streamChatCompletions(ctx, cfg, prompt, onToken, chatCompletionsRequest{
+		URL:     "https://api.together.xyz/v1/chat/completions",
+		Headers: bearer(cfg.APIKey),
+	})
+}
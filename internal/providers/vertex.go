@@ -0,0 +1,251 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+func init() {
+	Register("vertex", vertex{})
+}
+
+type vertex struct{}
+
+func (vertex) Validate(cfg ModelConfig) error {
+	if cfg.Project == "" || cfg.Region == "" {
+		return errors.New("the vertex provider requires a project and region")
+	}
+	return nil
+}
+
+// Stream streams Gemini through Vertex AI, authenticating with an OAuth token
+// from application default credentials instead of an API key
+func (vertex) Stream(ctx context.Context, cfg ModelConfig, prompt string, onToken func(string)) (string, error) {
+	token, err := vertexTokens.Token(ctx, clientFor(cfg))
+	if err != nil {
+		return "", fmt.Errorf("vertex credentials: %w", err)
+	}
+
+	reqBody := GeminiRequest{
+		SystemInstruction: geminiSystemInstruction(cfg),
+		Contents: []GeminiContent{
+			{
+				Role: "user",
+				Parts: []GeminiPart{
+					{Text: prompt},
+				},
+			},
+		},
+		GenerationConfig: geminiGenerationConfig(cfg),
+	}
+
+	body, err := withGeminiOptions(cfg, reqBody)
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/google/models/%s:streamGenerateContent?alt=sse",
+		cfg.Region, cfg.Project, cfg.Region, cfg.Model)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return streamGeminiSSE(ctx, cfg, req, onToken)
+}
+
+// adcTokenSource hands out OAuth access tokens from application default
+// credentials, caching each token across games until shortly before it
+// expires. Credentials are looked up the same way Google's libraries do:
+// GOOGLE_APPLICATION_CREDENTIALS, then gcloud's well-known file, then the GCE
+// metadata server.
+type adcTokenSource struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+var vertexTokens = &adcTokenSource{}
+
+// Token returns a valid access token, fetching a new one through client if
+// needed
+func (s *adcTokenSource) Token(ctx context.Context, client scrubbedClient) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.expires) > time.Minute {
+		return s.token, nil
+	}
+
+	token, expiresIn, err := fetchADCToken(ctx, client)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	s.expires = time.Now().Add(expiresIn)
+	return s.token, nil
+}
+
+type adcCredentials struct {
+	Type         string `json:"type"` // "service_account" or "authorized_user"
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func fetchADCToken(ctx context.Context, client scrubbedClient) (string, time.Duration, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			wellKnown := filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+			if _, err := os.Stat(wellKnown); err == nil {
+				path = wellKnown
+			}
+		}
+	}
+	if path == "" {
+		return fetchMetadataToken(ctx)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", 0, err
+	}
+	var creds adcCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return "", 0, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	var form url.Values
+	tokenURI := "https://oauth2.googleapis.com/token"
+	switch creds.Type {
+	case "service_account":
+		if creds.TokenURI != "" {
+			tokenURI = creds.TokenURI
+		}
+		assertion, err := signServiceAccountJWT(creds, tokenURI)
+		if err != nil {
+			return "", 0, err
+		}
+		form = url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+	case "authorized_user":
+		form = url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {creds.ClientID},
+			"client_secret": {creds.ClientSecret},
+			"refresh_token": {creds.RefreshToken},
+		}
+	default:
+		return "", 0, fmt.Errorf("unsupported credentials type %q in %s", creds.Type, path)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doTokenRequest(client, req)
+}
+
+func fetchMetadataToken(ctx context.Context) (string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		"http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token?scopes="+cloudPlatformScope, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	// The metadata server is link-local, so never proxied
+	token, expiresIn, err := doTokenRequest(scrubbedClient{httpClient}, req)
+	if err != nil {
+		return "", 0, fmt.Errorf("no application default credentials found: %w", err)
+	}
+	return token, expiresIn, nil
+}
+
+func doTokenRequest(client scrubbedClient, req *http.Request) (string, time.Duration, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, httpError(ModelConfig{Provider: "vertex"}, resp)
+	}
+
+	var token oauthTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", 0, err
+	}
+	if token.AccessToken == "" {
+		return "", 0, errors.New("token response had no access token")
+	}
+	return token.AccessToken, time.Duration(token.ExpiresIn) * time.Second, nil
+}
+
+// signServiceAccountJWT builds the RS256-signed assertion exchanged for an
+// access token in the service account flow
+func signServiceAccountJWT(creds adcCredentials, audience string) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", errors.New("service account private key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("parsing service account private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("service account private key is not RSA")
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   creds.ClientEmail,
+		"scope": cloudPlatformScope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	encode := base64.RawURLEncoding.EncodeToString
+	signingInput := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + encode(signature), nil
+}